@@ -5,6 +5,7 @@ import (
 	"agent/internal/authentication"
 	"agent/internal/config"
 	"agent/internal/hub"
+	"agent/internal/janitor"
 	"agent/internal/temporal/activities"
 	"agent/internal/temporal/workflows"
 	"context"
@@ -21,7 +22,8 @@ import (
 
 func main() {
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	// Load config from file
 	cfg, err := config.NewConfig(ctx, "")
@@ -73,19 +75,12 @@ func main() {
 	// Create worker
 	w := worker.New(c, hubConfig.Queue, workerOptions)
 
-	// Register workflows
-	w.RegisterWorkflowWithOptions(workflows.HTTPBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameHTTP})
-	w.RegisterWorkflowWithOptions(workflows.FTPBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameFTP})
-	w.RegisterWorkflowWithOptions(workflows.SFTPBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameSFTP})
-	w.RegisterWorkflowWithOptions(workflows.WebDAVBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameWebDAV})
-	w.RegisterWorkflowWithOptions(workflows.GitBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameGit})
-	w.RegisterWorkflowWithOptions(workflows.MySQLBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameMySQL})
-	w.RegisterWorkflowWithOptions(workflows.PostgreSQLBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNamePostgreSQL})
-	w.RegisterWorkflowWithOptions(workflows.MSSQLBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameMSSQL})
-	w.RegisterWorkflowWithOptions(workflows.RedisBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameRedis})
-	w.RegisterWorkflowWithOptions(workflows.AWSS3BackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameAWSS3})
-	w.RegisterWorkflowWithOptions(workflows.AWSDynamoDBBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameAWSDynamoDB})
-	w.RegisterWorkflowWithOptions(workflows.ScriptBackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameScript})
+	// Register the generic backup workflow, plus each provider's legacy workflow name as an alias
+	w.RegisterWorkflowWithOptions(workflows.BackupWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameBackup})
+	for _, spec := range workflows.Providers() {
+		w.RegisterWorkflowWithOptions(workflows.BackupWorkflow, workflow.RegisterOptions{Name: spec.WorkflowName})
+	}
+	w.RegisterWorkflowWithOptions(workflows.RestoreWorkflow, workflow.RegisterOptions{Name: names.WorkflowNameRestore})
 
 	// Create activities instance with dependency injection
 	acts := activities.NewActivities(cfg, authService, *hubConfig, c)
@@ -93,7 +88,12 @@ func main() {
 	// Register shared activities
 	w.RegisterActivityWithOptions(acts.BackupRequestActivity, activity.RegisterOptions{Name: names.ActivityNameBackupRequest})
 	w.RegisterActivityWithOptions(acts.BackupUploadActivity, activity.RegisterOptions{Name: names.ActivityNameBackupUpload})
+	w.RegisterActivityWithOptions(acts.BackupUploadCompleteActivity, activity.RegisterOptions{Name: names.ActivityNameBackupUploadComplete})
+	w.RegisterActivityWithOptions(acts.BackupStreamActivity, activity.RegisterOptions{Name: names.ActivityNameBackupStream})
+	w.RegisterActivityWithOptions(acts.TempSpaceReserveActivity, activity.RegisterOptions{Name: names.ActivityNameTempSpaceReserve})
+	w.RegisterActivityWithOptions(acts.TempSpaceReleaseActivity, activity.RegisterOptions{Name: names.ActivityNameTempSpaceRelease})
 	w.RegisterActivityWithOptions(acts.BackupConfirmActivity, activity.RegisterOptions{Name: names.ActivityNameBackupConfirm})
+	w.RegisterActivityWithOptions(acts.BackupStateSaveActivity, activity.RegisterOptions{Name: names.ActivityNameBackupStateSave})
 	w.RegisterActivityWithOptions(acts.FileCompressionActivity, activity.RegisterOptions{Name: names.ActivityNameCompressFile})
 	w.RegisterActivityWithOptions(acts.FileEncryptionActivity, activity.RegisterOptions{Name: names.ActivityNameEncryptFile})
 	w.RegisterActivityWithOptions(acts.GetJobActivity, activity.RegisterOptions{Name: names.ActivityNameGetJob})
@@ -101,20 +101,21 @@ func main() {
 	w.RegisterActivityWithOptions(acts.FileCleanupActivity, activity.RegisterOptions{Name: names.ActivityNameFileCleanup})
 	w.RegisterActivityWithOptions(acts.CreateTempDirActivity, activity.RegisterOptions{Name: names.ActivityNameCreateTempDir})
 	w.RegisterActivityWithOptions(acts.RemoveFileActivity, activity.RegisterOptions{Name: names.ActivityNameRemoveFile})
+	w.RegisterActivityWithOptions(acts.RestoreDownloadActivity, activity.RegisterOptions{Name: names.ActivityNameRestoreDownload})
 
 	// Register provider-specific activities
-	w.RegisterActivityWithOptions(acts.DownloadActivity, activity.RegisterOptions{Name: names.ActivityNameDownload})
-	w.RegisterActivityWithOptions(acts.FTPDownloadActivity, activity.RegisterOptions{Name: names.ActivityNameFileTransferDownload})
-	w.RegisterActivityWithOptions(acts.SFTPDownloadActivity, activity.RegisterOptions{Name: names.ActivityNameSFTPDownload})
-	w.RegisterActivityWithOptions(acts.WebDAVDownloadActivity, activity.RegisterOptions{Name: names.ActivityNameWebDAVDownload})
-	w.RegisterActivityWithOptions(acts.GitDownloadActivity, activity.RegisterOptions{Name: names.ActivityNameGitDownload})
-	w.RegisterActivityWithOptions(acts.MySQLDumpActivity, activity.RegisterOptions{Name: names.ActivityNameMySQLDump})
-	w.RegisterActivityWithOptions(acts.PostgreSQLDumpActivity, activity.RegisterOptions{Name: names.ActivityNamePostgreSQLDump})
-	w.RegisterActivityWithOptions(acts.MSSQLDumpActivity, activity.RegisterOptions{Name: names.ActivityNameMSSQLDump})
-	w.RegisterActivityWithOptions(acts.RedisDumpActivity, activity.RegisterOptions{Name: names.ActivityNameRedisDump})
-	w.RegisterActivityWithOptions(acts.AWSS3DownloadActivity, activity.RegisterOptions{Name: names.ActivityNameAWSS3Download})
-	w.RegisterActivityWithOptions(acts.AWSDynamoDBDumpActivity, activity.RegisterOptions{Name: names.ActivityNameAWSDynamoDBDump})
-	w.RegisterActivityWithOptions(acts.ScriptRunActivity, activity.RegisterOptions{Name: names.ActivityNameScriptRun})
+	for _, spec := range workflows.Providers() {
+		w.RegisterActivityWithOptions(spec.Activity(acts), activity.RegisterOptions{Name: spec.ActivityName})
+		if spec.RestoreActivityName != "" {
+			w.RegisterActivityWithOptions(spec.RestoreActivity(acts), activity.RegisterOptions{Name: spec.RestoreActivityName})
+		}
+		if spec.VerifyActivityName != "" {
+			w.RegisterActivityWithOptions(spec.VerifyActivity(acts), activity.RegisterOptions{Name: spec.VerifyActivityName})
+		}
+	}
+
+	// Remove files left in TempDir by earlier runs, and keep doing so while the worker runs
+	go janitor.New(cfg.TempDir, cfg.Janitor, acts.TempFileInUse).Run(ctx)
 
 	log.Printf("Loaded %d jobs from config", len(cfg.Jobs))
 	for _, job := range cfg.Jobs {
@@ -4,16 +4,27 @@ import (
 	"agent/internal/job"
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	API     string     `mapstructure:"api"`
-	TempDir string     `mapstructure:"temp_dir"`
-	Auth    AuthConfig `mapstructure:"auth"`
-	Path    PathConfig `mapstructure:"path"`
-	Jobs    []job.Job  `mapstructure:"jobs"`
+	API     string `mapstructure:"api"`
+	TempDir string `mapstructure:"temp_dir"`
+	// StateDir keeps what jobs carry from one backup to the next, such as the binary log
+	// position of MySQL binlog jobs. Unlike TempDir it is never swept.
+	StateDir string        `mapstructure:"state_dir"`
+	Auth     AuthConfig    `mapstructure:"auth"`
+	Path     PathConfig    `mapstructure:"path"`
+	Jobs     []job.Job     `mapstructure:"jobs"`
+	Janitor  JanitorConfig `mapstructure:"janitor"`
+	Restore  RestoreConfig `mapstructure:"restore"`
+
+	// UploadConcurrency is the number of parts uploaded in parallel for multipart uploads
+	UploadConcurrency int `mapstructure:"upload_concurrency"`
+	// TempDirMaxBytes caps the space backups may use in TempDir; 0 means only free disk space limits it
+	TempDirMaxBytes int64 `mapstructure:"temp_dir_max_bytes"`
 }
 
 func NewConfig(_ context.Context, configPath string) (*Config, error) {
@@ -29,6 +40,8 @@ func NewConfig(_ context.Context, configPath string) (*Config, error) {
 
 	// Global defaults
 	v.SetDefault("temp_dir", "/tmp/agent")
+	v.SetDefault("state_dir", "/var/lib/agent")
+	v.SetDefault("upload_concurrency", 4)
 
 	// Auth defaults
 	v.SetDefault("auth.server", "")
@@ -37,6 +50,10 @@ func NewConfig(_ context.Context, configPath string) (*Config, error) {
 	v.SetDefault("auth.client_id", "")
 	v.SetDefault("auth.audience", "")
 
+	// Janitor defaults
+	v.SetDefault("janitor.max_age", "24h")
+	v.SetDefault("janitor.interval", "1h")
+
 	// Path defaults
 	v.SetDefault("path.git", "git")
 	v.SetDefault("path.mysql", "mysqldump")
@@ -61,15 +78,23 @@ func NewConfig(_ context.Context, configPath string) (*Config, error) {
 		Config      map[string]any        `mapstructure:"config"`
 		Encryption  job.EncryptionConfig  `mapstructure:"encryption"`
 		Compression job.CompressionConfig `mapstructure:"compression"`
+		Timeout     time.Duration         `mapstructure:"timeout"`
+		Streaming   bool                  `mapstructure:"streaming"`
 	}
 
 	// First pass: unmarshal with raw config maps
 	var raw struct {
-		API     string     `mapstructure:"api"`
-		TempDir string     `mapstructure:"temp_dir"`
-		Auth    AuthConfig `mapstructure:"auth"`
-		Path    PathConfig `mapstructure:"path"`
-		Jobs    []rawJob   `mapstructure:"jobs"`
+		API      string        `mapstructure:"api"`
+		TempDir  string        `mapstructure:"temp_dir"`
+		StateDir string        `mapstructure:"state_dir"`
+		Auth     AuthConfig    `mapstructure:"auth"`
+		Path     PathConfig    `mapstructure:"path"`
+		Jobs     []rawJob      `mapstructure:"jobs"`
+		Janitor  JanitorConfig `mapstructure:"janitor"`
+		Restore  RestoreConfig `mapstructure:"restore"`
+
+		UploadConcurrency int   `mapstructure:"upload_concurrency"`
+		TempDirMaxBytes   int64 `mapstructure:"temp_dir_max_bytes"`
 	}
 	if err := v.Unmarshal(&raw); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
@@ -77,11 +102,17 @@ func NewConfig(_ context.Context, configPath string) (*Config, error) {
 
 	// Second pass: convert raw config maps to typed configs
 	cfg := &Config{
-		API:     raw.API,
-		TempDir: raw.TempDir,
-		Auth:    raw.Auth,
-		Path:    raw.Path,
-		Jobs:    make([]job.Job, 0, len(raw.Jobs)),
+		API:      raw.API,
+		TempDir:  raw.TempDir,
+		StateDir: raw.StateDir,
+		Auth:     raw.Auth,
+		Path:     raw.Path,
+		Jobs:     make([]job.Job, 0, len(raw.Jobs)),
+		Janitor:  raw.Janitor,
+		Restore:  raw.Restore,
+
+		UploadConcurrency: raw.UploadConcurrency,
+		TempDirMaxBytes:   raw.TempDirMaxBytes,
 	}
 
 	for _, rj := range raw.Jobs {
@@ -95,6 +126,8 @@ func NewConfig(_ context.Context, configPath string) (*Config, error) {
 			Config:      typedCfg,
 			Encryption:  rj.Encryption,
 			Compression: rj.Compression,
+			Timeout:     rj.Timeout,
+			Streaming:   rj.Streaming,
 		})
 	}
 
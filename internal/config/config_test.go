@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -28,6 +29,9 @@ func TestNewConfig_Defaults(t *testing.T) {
 	// Verify defaults
 	assert.Equal(t, "http://localhost:8000", cfg.API)
 	assert.Equal(t, "/tmp/agent", cfg.TempDir)
+	assert.Equal(t, "/var/lib/agent", cfg.StateDir)
+	assert.Equal(t, 4, cfg.UploadConcurrency)
+	assert.Equal(t, int64(0), cfg.TempDirMaxBytes)
 
 	// Verify Auth defaults
 	assert.Equal(t, "", cfg.Auth.Server)
@@ -36,6 +40,10 @@ func TestNewConfig_Defaults(t *testing.T) {
 	assert.Equal(t, "", cfg.Auth.ClientID)
 	assert.Equal(t, "", cfg.Auth.Audience)
 
+	// Verify Janitor defaults
+	assert.Equal(t, 24*time.Hour, cfg.Janitor.MaxAge)
+	assert.Equal(t, time.Hour, cfg.Janitor.Interval)
+
 	// Verify Path defaults
 	assert.Equal(t, "git", cfg.Path.Git)
 	assert.Equal(t, "mysqldump", cfg.Path.MySQL)
@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// JanitorConfig controls the removal of temp files left behind by backups that did not clean up
+type JanitorConfig struct {
+	// MaxAge is how long a file may sit in TempDir untouched before it is removed; 0 disables the janitor
+	MaxAge time.Duration `mapstructure:"max_age"`
+	// Interval is the time between two sweeps after the one at startup
+	Interval time.Duration `mapstructure:"interval"`
+}
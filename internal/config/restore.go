@@ -0,0 +1,10 @@
+package config
+
+// RestoreConfig holds the private keys used to decrypt backups when they are restored. They
+// only live in the agent config and are never sent to the API.
+type RestoreConfig struct {
+	// KeyFiles are age identity files or armored OpenPGP private keys
+	KeyFiles []string `mapstructure:"key_files"`
+	// Passphrase unlocks encrypted OpenPGP private keys
+	Passphrase string `mapstructure:"passphrase"`
+}
@@ -1,6 +1,9 @@
 package internal
 
 const (
+	WorkflowNameBackup  = "backup"
+	WorkflowNameRestore = "restore"
+
 	WorkflowNameHTTP   = "http"
 	WorkflowNameFTP    = "ftp"
 	WorkflowNameSFTP   = "sftp"
@@ -17,12 +20,17 @@ const (
 	WorkflowNameAWSS3       = "aws.s3"
 	WorkflowNameAWSDynamoDB = "aws.dynamodb"
 
-	ActivityNameBackupRequest = "BackupRequestActivity"
-	ActivityNameBackupUpload  = "BackupUploadActivity"
-	ActivityNameBackupConfirm = "BackupConfirmActivity"
-	ActivityNameCompressFile  = "CompressFileActivity"
-	ActivityNameEncryptFile   = "EncryptFileActivity"
-	ActivityNameDownload      = "DownloadActivity"
+	ActivityNameBackupRequest        = "BackupRequestActivity"
+	ActivityNameBackupUpload         = "BackupUploadActivity"
+	ActivityNameBackupUploadComplete = "BackupUploadCompleteActivity"
+	ActivityNameBackupStream         = "BackupStreamActivity"
+	ActivityNameTempSpaceReserve     = "TempSpaceReserveActivity"
+	ActivityNameTempSpaceRelease     = "TempSpaceReleaseActivity"
+	ActivityNameBackupConfirm        = "BackupConfirmActivity"
+	ActivityNameBackupStateSave      = "BackupStateSaveActivity"
+	ActivityNameCompressFile         = "CompressFileActivity"
+	ActivityNameEncryptFile          = "EncryptFileActivity"
+	ActivityNameDownload             = "DownloadActivity"
 
 	ActivityNameMSSQLConnect         = "MSSQLConnectActivity"
 	ActivityNameMSSQLDump            = "MSSQLDumpActivity"
@@ -34,6 +42,18 @@ const (
 	ActivityNameFileTransferDownload = "FTPDownloadActivity"
 	ActivityNameSFTPDownload         = "SFTPDownloadActivity"
 
+	ActivityNameRestoreDownload    = "RestoreDownloadActivity"
+	ActivityNamePostgreSQLRestore  = "PostgreSQLRestoreActivity"
+	ActivityNameMySQLRestore       = "MySQLRestoreActivity"
+	ActivityNameMSSQLRestore       = "MSSQLRestoreActivity"
+	ActivityNameRedisRestore       = "RedisRestoreActivity"
+	ActivityNameAWSDynamoDBRestore = "AWSDynamoDBRestoreActivity"
+	ActivityNameAWSS3Restore       = "AWSS3RestoreActivity"
+
+	ActivityNamePostgreSQLVerify = "PostgreSQLVerifyActivity"
+	ActivityNameMySQLVerify      = "MySQLVerifyActivity"
+	ActivityNameRedisVerify      = "RedisVerifyActivity"
+
 	ActivityNameWebDAVDownload = "WebDAVDownloadActivity"
 	ActivityNameGitDownload    = "GitDownloadActivity"
 	ActivityNameScriptRun      = "ScriptRunActivity"
@@ -43,4 +63,7 @@ const (
 	ActivityNameFileCleanup   = "FileCleanupActivity"
 	ActivityNameCreateTempDir = "CreateTempDirActivity"
 	ActivityNameRemoveFile    = "RemoveFileActivity"
+
+	QueryNameProgress  = "progress"
+	SignalNameProgress = "progress"
 )
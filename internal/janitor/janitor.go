@@ -0,0 +1,146 @@
+package janitor
+
+import (
+	"agent/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Janitor removes the files that backups leave in TempDir when their workflow fails before
+// the cleanup step, or when the agent stops in the middle of a backup
+type Janitor struct {
+	dir      string
+	maxAge   time.Duration
+	interval time.Duration
+	// inUse reports whether a TempDir entry belongs to a backup that is still running
+	inUse func(name string) bool
+	now   func() time.Time
+}
+
+// Result lists what a sweep removed
+type Result struct {
+	Removed []string
+	Bytes   int64
+}
+
+// New returns a janitor for dir. inUse may be nil when no backups run in this process.
+func New(dir string, cfg config.JanitorConfig, inUse func(name string) bool) *Janitor {
+	return &Janitor{
+		dir:      dir,
+		maxAge:   cfg.MaxAge,
+		interval: cfg.Interval,
+		inUse:    inUse,
+		now:      time.Now,
+	}
+}
+
+// Run sweeps TempDir once and then every interval until ctx is done. It returns right away
+// when the janitor is disabled.
+func (j *Janitor) Run(ctx context.Context) {
+	if j.maxAge <= 0 {
+		log.Printf("Temp file janitor disabled")
+		return
+	}
+
+	j.sweep()
+	if j.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.sweep()
+		}
+	}
+}
+
+func (j *Janitor) sweep() {
+	res, err := j.Sweep()
+	if err != nil {
+		log.Printf("Temp file janitor failed: %v", err)
+	}
+	if len(res.Removed) > 0 {
+		log.Printf("Temp file janitor removed %d entries (%d bytes) from %s: %v",
+			len(res.Removed), res.Bytes, j.dir, res.Removed)
+	}
+}
+
+// Sweep removes the entries of TempDir that were last modified more than maxAge ago and do
+// not belong to a running backup. A directory is as old as the newest file in it, so a clone
+// or mirror that is still being written is kept.
+func (j *Janitor) Sweep() (Result, error) {
+	var res Result
+
+	entries, err := os.ReadDir(j.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return res, nil
+	}
+	if err != nil {
+		return res, fmt.Errorf("failed to read temp dir: %w", err)
+	}
+
+	cutoff := j.now().Add(-j.maxAge)
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if j.inUse != nil && j.inUse(name) {
+			continue
+		}
+
+		path := filepath.Join(j.dir, name)
+		modTime, size, err := stat(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if modTime.After(cutoff) {
+			continue
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %w", path, err))
+			continue
+		}
+		res.Removed = append(res.Removed, name)
+		res.Bytes += size
+	}
+
+	return res, errors.Join(errs...)
+}
+
+// stat returns the newest modification time and the total size of the files under path
+func stat(path string) (time.Time, int64, error) {
+	var newest time.Time
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		if !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	return newest, size, nil
+}
@@ -0,0 +1,60 @@
+package janitor
+
+import (
+	"agent/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path string, size int, age time.Duration) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o644))
+	mtime := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestSweep(t *testing.T) {
+	dir := t.TempDir()
+	day := 24 * time.Hour
+
+	writeFile(t, filepath.Join(dir, "old-job.sql"), 10, 2*day)
+	writeFile(t, filepath.Join(dir, "old-job.sql.gz"), 5, 2*day)
+	writeFile(t, filepath.Join(dir, "running-job.sql"), 10, 2*day)
+	writeFile(t, filepath.Join(dir, "fresh-job.sql"), 10, time.Hour)
+	// A clone with one recent file is still being written
+	writeFile(t, filepath.Join(dir, "clone-job-git-clone-1", "a"), 1, 2*day)
+	writeFile(t, filepath.Join(dir, "clone-job-git-clone-1", "b"), 1, time.Minute)
+	writeFile(t, filepath.Join(dir, "mirror-job-ftp-mirror-1", "sub", "c"), 3, 2*day)
+	old := time.Now().Add(-2 * day)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "mirror-job-ftp-mirror-1", "sub"), old, old))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "mirror-job-ftp-mirror-1"), old, old))
+
+	j := New(dir, config.JanitorConfig{MaxAge: day}, func(name string) bool {
+		return name == "running-job.sql"
+	})
+	res, err := j.Sweep()
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"old-job.sql", "old-job.sql.gz", "mirror-job-ftp-mirror-1"}, res.Removed)
+	assert.Equal(t, int64(18), res.Bytes)
+
+	left, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range left {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"running-job.sql", "fresh-job.sql", "clone-job-git-clone-1"}, names)
+}
+
+func TestSweep_MissingDir(t *testing.T) {
+	j := New(filepath.Join(t.TempDir(), "missing"), config.JanitorConfig{MaxAge: time.Hour}, nil)
+	res, err := j.Sweep()
+	require.NoError(t, err)
+	assert.Empty(t, res.Removed)
+}
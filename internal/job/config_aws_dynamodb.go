@@ -1,24 +1,73 @@
 package job
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+)
 
 const JobProviderAWSDynamoDB Provider = "aws.dynamodb"
 
+// DynamoDB backup methods
+const (
+	// DynamoDBMethodScan reads the table with Scan into a dump file, the default
+	DynamoDBMethodScan = "scan"
+	// DynamoDBMethodExportS3 has DynamoDB export the table to S3 with ExportTableToPointInTime,
+	// which needs point-in-time recovery enabled and reads no table capacity
+	DynamoDBMethodExportS3 = "export_s3"
+	// DynamoDBMethodOnDemand takes an on-demand backup with CreateBackup, kept by DynamoDB
+	DynamoDBMethodOnDemand = "on_demand"
+)
+
 type AWSDynamoDBConfig struct {
 	Region          string `json:"region"`
 	TableName       string `json:"table_name"`
 	AccessKeyID     string `json:"access_key_id"`
 	SecretAccessKey string `json:"secret_access_key"`
-	BackupMethod    string `json:"backup_method"`
-	S3Bucket        string `json:"s3_bucket"`
+	// Tables, TablePrefix and TablePattern back up several tables instead of TableName: the
+	// listed ones, or those ListTables returns whose name has the prefix or matches the
+	// regular expression
+	Tables       []string `json:"tables,omitempty"`
+	TablePrefix  string   `json:"table_prefix,omitempty"`
+	TablePattern string   `json:"table_pattern,omitempty"`
+	// BackupMethod is scan (default), export_s3 or on_demand
+	BackupMethod string `json:"backup_method"`
+	// S3Bucket and S3Prefix are where export_s3 writes the export
+	S3Bucket string `json:"s3_bucket"`
+	S3Prefix string `json:"s3_prefix,omitempty"`
+	// ExportDownload pulls the exported files into the backup; without it the backup only
+	// records where the export is
+	ExportDownload bool `json:"export_download,omitempty"`
+	// ScanSegments is how many segments of the table are scanned in parallel (default 1)
+	ScanSegments int `json:"scan_segments,omitempty"`
+	// ConsistentRead makes the scan strongly consistent, which consumes twice the capacity
+	ConsistentRead bool `json:"consistent_read,omitempty"`
+	// MaxReadCapacity bounds the read capacity units the scan consumes per second, 0 for no limit
+	MaxReadCapacity float64 `json:"max_read_capacity,omitempty"`
 }
 
+// DynamoDBMaxScanSegments is the TotalSegments limit of Scan
+const DynamoDBMaxScanSegments = 1000000
+
 func (c *AWSDynamoDBConfig) Validate() error {
 	if c.Region == "" {
 		return fmt.Errorf("region is required")
 	}
-	if c.TableName == "" {
-		return fmt.Errorf("table_name is required")
+	selectors := 0
+	for _, set := range []bool{c.TableName != "", len(c.Tables) > 0, c.TablePrefix != "", c.TablePattern != ""} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return fmt.Errorf("exactly one of table_name, tables, table_prefix and table_pattern is required")
+	}
+	if c.TablePattern != "" {
+		if _, err := regexp.Compile(c.TablePattern); err != nil {
+			return fmt.Errorf("invalid table_pattern: %w", err)
+		}
+	}
+	if c.MultiTable() && c.BackupMethod != "" && c.BackupMethod != DynamoDBMethodScan {
+		return fmt.Errorf("tables, table_prefix and table_pattern require the scan backup method")
 	}
 	if c.AccessKeyID == "" {
 		return fmt.Errorf("access_key_id is required")
@@ -26,10 +75,32 @@ func (c *AWSDynamoDBConfig) Validate() error {
 	if c.SecretAccessKey == "" {
 		return fmt.Errorf("secret_access_key is required")
 	}
-	if c.BackupMethod == "export_s3" && c.S3Bucket == "" {
-		return fmt.Errorf("s3_bucket is required for export_s3 backup method")
+	switch c.BackupMethod {
+	case "", DynamoDBMethodScan, DynamoDBMethodOnDemand:
+		if c.S3Prefix != "" || c.ExportDownload {
+			return fmt.Errorf("s3_prefix and export_download only apply to the export_s3 backup method")
+		}
+	case DynamoDBMethodExportS3:
+		if c.S3Bucket == "" {
+			return fmt.Errorf("s3_bucket is required for export_s3 backup method")
+		}
+	default:
+		return fmt.Errorf("unsupported backup_method %q", c.BackupMethod)
+	}
+	if c.ScanSegments < 0 || c.ScanSegments > DynamoDBMaxScanSegments {
+		return fmt.Errorf("scan_segments must be between 1 and %d", DynamoDBMaxScanSegments)
+	}
+	if c.MaxReadCapacity < 0 {
+		return fmt.Errorf("max_read_capacity must not be negative")
+	}
+	if c.BackupMethod != "" && c.BackupMethod != DynamoDBMethodScan &&
+		(c.ScanSegments != 0 || c.ConsistentRead || c.MaxReadCapacity != 0) {
+		return fmt.Errorf("scan_segments, consistent_read and max_read_capacity only apply to the scan backup method")
 	}
 	return nil
 }
 
+// MultiTable reports whether the job selects its tables instead of naming one
+func (c *AWSDynamoDBConfig) MultiTable() bool { return c.TableName == "" }
+
 func (c *AWSDynamoDBConfig) Type() Provider { return JobProviderAWSDynamoDB }
@@ -1,27 +1,81 @@
 package job
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 const JobProviderMSSQL Provider = "mssql"
 
+// MSSQL backup methods
+const (
+	// MSSQLMethodBackup takes a native backup with BACKUP DATABASE or BACKUP LOG, the default
+	MSSQLMethodBackup = "backup"
+	// MSSQLMethodBacpac exports the schema and data into a BACPAC with SqlPackage, which works
+	// for any server the agent can connect to
+	MSSQLMethodBacpac = "bacpac"
+)
+
+// MSSQL native backup types
+const (
+	MSSQLBackupFull         = "full"
+	MSSQLBackupDifferential = "differential"
+	MSSQLBackupLog          = "log"
+)
+
 type MSSQLConfig struct {
-	Host        string `json:"host"`
-	Port        int    `json:"port"`
-	Database    string `json:"database"`
-	Username    string `json:"username"`
-	Password    string `json:"password"`
-	Instance    string `json:"instance,omitempty"`
-	Encrypt     bool   `json:"encrypt,omitempty"`
-	TrustCert   bool   `json:"trust_cert,omitempty"`
-	ConnTimeout int    `json:"conn_timeout,omitempty"`
+	Host string `json:"host"`
+	// Port is required unless Instance names the instance, which SQL Server Browser resolves
+	Port     int    `json:"port"`
+	Database string `json:"database"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Instance string `json:"instance,omitempty"`
+	Encrypt  bool   `json:"encrypt,omitempty"`
+	// TrustCert skips the validation of the server certificate
+	TrustCert bool `json:"trust_cert,omitempty"`
+	// ConnTimeout is the login timeout in seconds
+	ConnTimeout int `json:"conn_timeout,omitempty"`
+	// Method is backup (default) or bacpac. The options below apply to native backups only.
+	Method string `json:"method,omitempty"`
+	// BackupType is full (default), differential or log
+	BackupType  string `json:"backup_type,omitempty"`
+	CopyOnly    bool   `json:"copy_only,omitempty"`
+	Compression bool   `json:"compression,omitempty"`
+	// BackupDir is the directory the server writes backups to, as a path on the server. LocalDir
+	// is the same directory on the agent, a mounted share for remote servers; it defaults to
+	// BackupDir. Without BackupDir and URL the server must run on the agent's machine.
+	BackupDir string `json:"backup_dir,omitempty"`
+	LocalDir  string `json:"local_dir,omitempty"`
+	// URL has the server write backups to an S3 compatible bucket with BACKUP TO URL, from
+	// where the agent downloads them
+	URL *MSSQLURLConfig `json:"url,omitempty"`
+	// Retention is how long the backend keeps the backups, so a full backup can be rolled
+	// forward with the differential and log backups taken within it
+	Retention time.Duration `json:"retention,omitempty"`
+}
+
+// MSSQLURLConfig is an S3 compatible bucket for BACKUP TO URL (SQL Server 2022 and later). The
+// server needs a credential for it, created with CREATE CREDENTIAL [s3://<host>:<port>/<bucket>]
+// WITH IDENTITY = 'S3 Access Key', SECRET = '<access key id>:<secret access key>'.
+type MSSQLURLConfig struct {
+	// Endpoint is the https URL of the S3 compatible service
+	Endpoint string `json:"endpoint"`
+	Bucket   string `json:"bucket"`
+	// Prefix is prepended to the object keys of the backups
+	Prefix          string `json:"prefix,omitempty"`
+	Region          string `json:"region,omitempty"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
 }
 
 func (c *MSSQLConfig) Validate() error {
 	if c.Host == "" {
 		return errors.New("host is required")
 	}
-	if c.Port == 0 {
-		return errors.New("port is required")
+	if c.Port == 0 && c.Instance == "" {
+		return errors.New("port or instance is required")
 	}
 	if c.Database == "" {
 		return errors.New("database is required")
@@ -32,7 +86,69 @@ func (c *MSSQLConfig) Validate() error {
 	if c.Password == "" {
 		return errors.New("password is required")
 	}
+	if c.ConnTimeout < 0 {
+		return errors.New("conn_timeout must not be negative")
+	}
+	if c.Retention < 0 {
+		return errors.New("retention must not be negative")
+	}
+	switch c.BackupType {
+	case "", MSSQLBackupFull, MSSQLBackupDifferential, MSSQLBackupLog:
+	default:
+		return fmt.Errorf("unsupported backup_type %q", c.BackupType)
+	}
+	switch c.Method {
+	case "", MSSQLMethodBackup:
+	case MSSQLMethodBacpac:
+		if (c.BackupType != "" && c.BackupType != MSSQLBackupFull) || c.CopyOnly || c.Compression ||
+			c.BackupDir != "" || c.LocalDir != "" || c.URL != nil {
+			return errors.New("bacpac exports take no native backup options")
+		}
+	default:
+		return fmt.Errorf("unsupported method %q", c.Method)
+	}
+	if c.CopyOnly && c.BackupType == MSSQLBackupDifferential {
+		return errors.New("copy_only does not apply to differential backups")
+	}
+	if c.LocalDir != "" && c.BackupDir == "" {
+		return errors.New("local_dir requires backup_dir")
+	}
+	if c.URL != nil {
+		if c.BackupDir != "" {
+			return errors.New("backup_dir and url are mutually exclusive")
+		}
+		if err := c.URL.Validate(); err != nil {
+			return fmt.Errorf("invalid url: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *MSSQLURLConfig) Validate() error {
+	if c.Endpoint == "" {
+		return errors.New("endpoint is required")
+	}
+	if c.Bucket == "" {
+		return errors.New("bucket is required")
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return errors.New("access_key_id and secret_access_key are required")
+	}
 	return nil
 }
 
 func (c *MSSQLConfig) Type() Provider { return JobProviderMSSQL }
+
+// BackupKind returns full or incremental when a backup type is set; differential and log
+// backups only restore on top of a full backup
+func (c *MSSQLConfig) BackupKind() string {
+	switch c.BackupType {
+	case MSSQLBackupFull:
+		return BackupKindFull
+	case MSSQLBackupDifferential, MSSQLBackupLog:
+		return BackupKindIncremental
+	}
+	return ""
+}
+
+func (c *MSSQLConfig) BackupRetention() time.Duration { return c.Retention }
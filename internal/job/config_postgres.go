@@ -1,21 +1,122 @@
 package job
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+)
 
 const JobProviderPostgreSQL Provider = "postgres"
 
+// PostgreSQL backup methods
+const (
+	// PostgreSQLMethodDump takes a logical backup with pg_dump, the default
+	PostgreSQLMethodDump = "dump"
+	// PostgreSQLMethodBaseBackup takes a physical backup of the cluster with pg_basebackup
+	PostgreSQLMethodBaseBackup = "basebackup"
+	// PostgreSQLMethodWAL archives the WAL written since the previous run from a replication slot
+	PostgreSQLMethodWAL = "wal"
+)
+
+// pg_dump output formats
+const (
+	PostgreSQLFormatPlain     = "plain"
+	PostgreSQLFormatCustom    = "custom"
+	PostgreSQLFormatDirectory = "directory"
+	PostgreSQLFormatTar       = "tar"
+)
+
 type PostgreSQLConfig struct {
 	ConnectionString string `json:"connection_string"`
-	Format           string `json:"format,omitempty"`
-	SchemaOnly       bool   `json:"schema_only,omitempty"`
-	DataOnly         bool   `json:"data_only,omitempty"`
+	// Method is dump (default), basebackup or wal. The other options apply to dumps only.
+	Method string `json:"method,omitempty"`
+	// Slot is the physical replication slot that keeps the WAL between a base backup and the
+	// WAL archive runs; it is created when missing. Required for wal.
+	Slot string `json:"slot,omitempty"`
+	// Retention is how long the backend keeps the WAL archives, so a base backup can be rolled
+	// forward to any point within it
+	Retention time.Duration `json:"retention,omitempty"`
+	// Format is the pg_dump format: plain (default), custom, directory or tar. Directory
+	// dumps are tarred and can be dumped in parallel with Jobs.
+	Format     string `json:"format,omitempty"`
+	SchemaOnly bool   `json:"schema_only,omitempty"`
+	DataOnly   bool   `json:"data_only,omitempty"`
+	// Tables, ExcludeTables, Schemas and ExcludeSchemas are pg_dump patterns
+	Tables         []string `json:"tables,omitempty"`
+	ExcludeTables  []string `json:"exclude_tables,omitempty"`
+	Schemas        []string `json:"schemas,omitempty"`
+	ExcludeSchemas []string `json:"exclude_schemas,omitempty"`
+	// Jobs is the number of tables dumped in parallel, directory format only
+	Jobs int `json:"jobs,omitempty"`
+	// Cluster backs up the whole server: the globals (roles, tablespaces) from pg_dumpall and a
+	// dump of each database in Format, selected by the Databases and ExcludeDatabases patterns
+	Cluster          bool     `json:"cluster,omitempty"`
+	Databases        []string `json:"databases,omitempty"`
+	ExcludeDatabases []string `json:"exclude_databases,omitempty"`
+	// Verify restores each dump into a scratch target to check that it is restorable
+	Verify *VerifyConfig `json:"verify,omitempty"`
 }
 
 func (c *PostgreSQLConfig) Validate() error {
 	if c.ConnectionString == "" {
 		return errors.New("connection_string is required")
 	}
+	switch c.Method {
+	case "", PostgreSQLMethodDump:
+	case PostgreSQLMethodBaseBackup:
+		if c.Cluster {
+			return errors.New("cluster requires the dump method")
+		}
+	case PostgreSQLMethodWAL:
+		if c.Slot == "" {
+			return errors.New("slot is required for the wal method")
+		}
+	default:
+		return fmt.Errorf("unsupported method %q", c.Method)
+	}
+	if c.Retention < 0 {
+		return errors.New("retention must not be negative")
+	}
+	switch c.Format {
+	case "", PostgreSQLFormatPlain, PostgreSQLFormatCustom, PostgreSQLFormatDirectory, PostgreSQLFormatTar:
+	default:
+		return fmt.Errorf("unsupported format %q", c.Format)
+	}
+	if c.SchemaOnly && c.DataOnly {
+		return errors.New("schema_only and data_only are mutually exclusive")
+	}
+	if c.Jobs < 0 {
+		return errors.New("jobs must not be negative")
+	}
+	if c.Jobs > 1 && c.Format != PostgreSQLFormatDirectory {
+		return errors.New("jobs requires the directory format")
+	}
+	if !c.Cluster && (len(c.Databases) > 0 || len(c.ExcludeDatabases) > 0) {
+		return errors.New("databases and exclude_databases require cluster")
+	}
+	for _, pattern := range slices.Concat(c.Databases, c.ExcludeDatabases) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid database pattern %q", pattern)
+		}
+	}
 	return nil
 }
 
 func (c *PostgreSQLConfig) Type() Provider { return JobProviderPostgreSQL }
+
+func (c *PostgreSQLConfig) VerifyConfig() *VerifyConfig { return c.Verify }
+
+// BackupKind returns incremental for WAL archives, which only replay on top of a base backup
+func (c *PostgreSQLConfig) BackupKind() string {
+	switch c.Method {
+	case PostgreSQLMethodWAL:
+		return BackupKindIncremental
+	case PostgreSQLMethodBaseBackup:
+		return BackupKindFull
+	}
+	return ""
+}
+
+func (c *PostgreSQLConfig) BackupRetention() time.Duration { return c.Retention }
@@ -2,24 +2,109 @@ package job
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/redis/go-redis/v9"
 )
 
 const JobProviderRedis Provider = "redis"
 
+// Redis backup methods
+const (
+	// RedisMethodJSON dumps each key with DUMP and PTTL into a JSON file, the default
+	RedisMethodJSON = "json"
+	// RedisMethodRDB receives a point in time RDB snapshot of all databases through the
+	// replication handshake, like a replica does
+	RedisMethodRDB = "rdb"
+)
+
+// Redis deployment modes, which decide how the connection string is read
+const (
+	// RedisModeStandalone connects to the server of a redis:// URL, the default
+	RedisModeStandalone = "standalone"
+	// RedisModeCluster discovers the masters of a cluster from the seed nodes of the URL, the
+	// host and any addr parameters, and dumps every shard
+	RedisModeCluster = "cluster"
+	// RedisModeSentinel asks the sentinels of the URL, the host and any addr parameters, for the
+	// current master of master_name. The user info of the URL is the sentinel's, the master's
+	// credentials are the username and password parameters.
+	RedisModeSentinel = "sentinel"
+)
+
 type RedisConfig struct {
 	ConnectionString string `json:"connection_string"`
+	// Mode is standalone (default), cluster or sentinel
+	Mode string `json:"mode,omitempty"`
+	// Method is json (default) or rdb
+	Method string `json:"method,omitempty"`
+	// Databases dumps these DB indexes instead of the one of the connection string,
+	// AllDatabases every DB that has keys. Clusters only have DB 0.
+	Databases    []int `json:"databases,omitempty"`
+	AllDatabases bool  `json:"all_databases,omitempty"`
+	// Match only dumps the keys matching this SCAN pattern
+	Match string `json:"match,omitempty"`
+	// Types only dumps keys of these types (string, list, set, zset, hash, stream, ...)
+	Types []string `json:"types,omitempty"`
+	// Verify restores each dump into a scratch target to check that it is restorable
+	Verify *VerifyConfig `json:"verify,omitempty"`
 }
 
 func (c *RedisConfig) Validate() error {
 	if c.ConnectionString == "" {
 		return errors.New("connection_string is required")
 	}
-	if _, err := redis.ParseURL(c.ConnectionString); err != nil {
-		return err
+	switch c.Mode {
+	case "", RedisModeStandalone:
+		if _, err := redis.ParseURL(c.ConnectionString); err != nil {
+			return err
+		}
+	case RedisModeCluster:
+		if _, err := redis.ParseClusterURL(c.ConnectionString); err != nil {
+			return err
+		}
+		if c.MultiDatabase() {
+			return errors.New("clusters only have DB 0, databases and all_databases do not apply")
+		}
+	case RedisModeSentinel:
+		opt, err := redis.ParseFailoverURL(c.ConnectionString)
+		if err != nil {
+			return err
+		}
+		if opt.MasterName == "" {
+			return errors.New("sentinel connection strings need a master_name parameter")
+		}
+	default:
+		return fmt.Errorf("unsupported mode %q", c.Mode)
+	}
+	if len(c.Databases) > 0 && c.AllDatabases {
+		return errors.New("databases and all_databases are mutually exclusive")
+	}
+	for _, db := range c.Databases {
+		if db < 0 {
+			return fmt.Errorf("invalid database index %d", db)
+		}
+	}
+	switch c.Method {
+	case "", RedisMethodJSON:
+	case RedisMethodRDB:
+		if c.Verify != nil {
+			return errors.New("rdb snapshots cannot be restore drilled")
+		}
+		if c.MultiDatabase() || c.Match != "" || len(c.Types) > 0 {
+			return errors.New("rdb snapshots always hold every key, databases, match and types do not apply")
+		}
+	default:
+		return fmt.Errorf("unsupported method %q", c.Method)
 	}
 	return nil
 }
 
+// MultiDatabase reports whether the job selects its DBs itself, in which case the dump records
+// the DB of each key and restores into the same DBs
+func (c *RedisConfig) MultiDatabase() bool {
+	return len(c.Databases) > 0 || c.AllDatabases
+}
+
 func (c *RedisConfig) Type() Provider { return JobProviderRedis }
+
+func (c *RedisConfig) VerifyConfig() *VerifyConfig { return c.Verify }
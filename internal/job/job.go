@@ -3,6 +3,7 @@ package job
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/go-viper/mapstructure/v2"
 )
@@ -41,6 +42,8 @@ type jobJSON struct {
 	Encryption  EncryptionConfig  `json:"encryption"`
 	Compression CompressionConfig `json:"compression"`
 	Script      *ScriptConfig     `json:"script,omitempty"`
+	Timeout     time.Duration     `json:"timeout,omitempty"`
+	Streaming   bool              `json:"streaming,omitempty"`
 }
 
 func (j *Job) MarshalJSON() ([]byte, error) {
@@ -51,6 +54,7 @@ func (j *Job) MarshalJSON() ([]byte, error) {
 	return json.Marshal(jobJSON{
 		ID: j.ID, Provider: j.Provider, Config: raw,
 		Encryption: j.Encryption, Compression: j.Compression, Script: j.Script,
+		Timeout: j.Timeout, Streaming: j.Streaming,
 	})
 }
 
@@ -73,6 +77,8 @@ func (j *Job) UnmarshalJSON(data []byte) error {
 	j.Encryption = tmp.Encryption
 	j.Compression = tmp.Compression
 	j.Script = tmp.Script
+	j.Timeout = tmp.Timeout
+	j.Streaming = tmp.Streaming
 	return nil
 }
 
@@ -89,7 +95,9 @@ func ConfigFromMap(provider Provider, m map[string]any) (Config, error) {
 	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		WeaklyTypedInput: true,
 		TagName:          "json",
-		Result:           cfg,
+		// Durations such as retention: 168h are written as strings
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     cfg,
 	})
 	if err != nil {
 		return nil, err
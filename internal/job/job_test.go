@@ -0,0 +1,38 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromMap(t *testing.T) {
+	cfg, err := ConfigFromMap(JobProviderPostgreSQL, map[string]any{
+		"connection_string": "postgres://backup@db/postgres",
+		"method":            "wal",
+		"slot":              "agent",
+		"retention":         "168h",
+		"jobs":              "4",
+	})
+	require.NoError(t, err)
+	pg, ok := cfg.(*PostgreSQLConfig)
+	require.True(t, ok)
+	assert.Equal(t, 168*time.Hour, pg.Retention)
+	assert.Equal(t, 4, pg.Jobs)
+
+	// Nanoseconds, as the API sends durations, still decode
+	cfg, err = ConfigFromMap(JobProviderMySQL, map[string]any{
+		"connection_string": "app@tcp(db)/shop",
+		"retention":         int64(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, cfg.(*MySQLConfig).Retention)
+
+	_, err = ConfigFromMap(JobProviderMSSQL, map[string]any{"retention": "a week"})
+	assert.Error(t, err)
+
+	_, err = ConfigFromMap("imap2", map[string]any{})
+	assert.ErrorContains(t, err, "unknown provider")
+}
@@ -1,5 +1,7 @@
 package job
 
+import "time"
+
 type Provider string
 
 func (p Provider) String() string { return string(p) }
@@ -10,10 +12,25 @@ type Config interface {
 	Type() Provider
 }
 
+// Kinds of backups reported to the API by the configs implementing Incremental
+const (
+	BackupKindFull        = "full"
+	BackupKindIncremental = "incremental"
+)
+
+// Incremental is implemented by the configs of jobs whose backups can build on earlier ones
+type Incremental interface {
+	// BackupKind returns the kind of the next backup, or "" for self-contained backups
+	BackupKind() string
+	// BackupRetention is how long the backend should keep the backups, 0 for its default
+	BackupRetention() time.Duration
+}
+
 type EncryptionConfig struct {
-	Enabled   bool   `json:"enabled"`
-	PublicKey string `json:"public_key"`
-	Algorithm string `json:"algorithm"`
+	Enabled    bool     `json:"enabled"`
+	PublicKey  string   `json:"public_key"`
+	Recipients []string `json:"recipients,omitempty"`
+	Algorithm  string   `json:"algorithm"`
 }
 
 type CompressionConfig struct {
@@ -29,4 +46,10 @@ type Job struct {
 	Encryption  EncryptionConfig  `mapstructure:"encryption" json:"encryption"`
 	Compression CompressionConfig `mapstructure:"compression" json:"compression"`
 	Script      *ScriptConfig     `mapstructure:"script" json:"script"`
+	// Timeout overrides the provider's default StartToCloseTimeout for download,
+	// compression, encryption and upload
+	Timeout time.Duration `mapstructure:"timeout" json:"timeout,omitempty"`
+	// Streaming pipes the provider output through compression and encryption straight
+	// into the upload instead of writing temp files, when the provider supports it
+	Streaming bool `mapstructure:"streaming" json:"streaming,omitempty"`
 }
@@ -0,0 +1,66 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+)
+
+// VerifyConfig enables a restore drill after each dump: the dump is restored into a scratch
+// target, the checks are run against it and the scratch target is dropped or flushed again.
+// A failed drill marks the backup as unverified, it never fails the backup.
+type VerifyConfig struct {
+	Enabled bool `json:"enabled"`
+	// ConnectionString points at the server holding the scratch target, defaulting to the job's
+	ConnectionString string `json:"connection_string,omitempty"`
+	// Database is the scratch database, which is dropped and recreated by every drill, or the
+	// Redis DB index, which is flushed. It must not hold anything worth keeping.
+	Database string `json:"database"`
+	// Checks are run against the restored data; a provider default is used when empty
+	Checks []VerifyCheck `json:"checks,omitempty"`
+}
+
+// VerifyCheck is a sanity check of the restored data. Query is a SQL query returning a single
+// number for PostgreSQL and MySQL jobs; Pattern is a key pattern whose keys are counted for
+// Redis jobs. A check without Min and Max only reports the value.
+type VerifyCheck struct {
+	Name    string `json:"name"`
+	Query   string `json:"query,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Min     *int64 `json:"min,omitempty"`
+	Max     *int64 `json:"max,omitempty"`
+}
+
+func (c *VerifyConfig) Validate() error {
+	if c.Database == "" {
+		return errors.New("database is required")
+	}
+	for i, check := range c.Checks {
+		if check.Name == "" {
+			return fmt.Errorf("checks[%d]: name is required", i)
+		}
+		if check.Query == "" && check.Pattern == "" {
+			return fmt.Errorf("check %q: query or pattern is required", check.Name)
+		}
+		if check.Min != nil && check.Max != nil && *check.Min > *check.Max {
+			return fmt.Errorf("check %q: min is greater than max", check.Name)
+		}
+	}
+	return nil
+}
+
+// Verifiable is implemented by the configs of providers whose dumps can be restore drilled
+type Verifiable interface {
+	VerifyConfig() *VerifyConfig
+}
+
+// VerifyConfigOf returns the enabled restore drill of a job, or nil
+func VerifyConfigOf(j *Job) *VerifyConfig {
+	v, ok := j.Config.(Verifiable)
+	if !ok {
+		return nil
+	}
+	if cfg := v.VerifyConfig(); cfg != nil && cfg.Enabled {
+		return cfg
+	}
+	return nil
+}
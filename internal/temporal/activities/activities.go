@@ -17,6 +17,9 @@ type Activities struct {
 	Hub            *config.HubConfig
 	TemporalClient client.Client
 	HTTPClient     *http.Client
+
+	// space holds the TempDir reservations of the backups running on this worker
+	space tempSpace
 }
 
 // NewActivities creates a new Activities instance with required dependencies
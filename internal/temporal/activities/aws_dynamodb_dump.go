@@ -2,13 +2,16 @@ package activities
 
 import (
 	"agent/internal/job"
+	"bufio"
 	"context"
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
@@ -16,12 +19,19 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"go.temporal.io/sdk/activity"
+	"golang.org/x/sync/errgroup"
 )
 
+// dynamoDBScanConcurrency bounds how many segments of a parallel scan are read at once
+const dynamoDBScanConcurrency = 32
+
 type AWSDynamoDBDumpActivityInput struct {
 	Job *job.Job `json:"job"`
 }
 
+// AWSDynamoDBDumpActivity backs up the table with the method of the job. Scans write the items
+// as DynamoDB JSON, one item per line, reading scan_segments segments of the table in parallel;
+// jobs of several tables are archived with the schema of each table.
 func (a *Activities) AWSDynamoDBDumpActivity(ctx context.Context, input AWSDynamoDBDumpActivityInput) (*DownloadActivityOutput, error) {
 	logger := activity.GetLogger(ctx)
 	logger.Info("AWSDynamoDBDumpActivity started", "jobId", input.Job.ID)
@@ -34,117 +44,205 @@ func (a *Activities) AWSDynamoDBDumpActivity(ctx context.Context, input AWSDynam
 		return nil, fmt.Errorf("invalid DynamoDB config: %w", err)
 	}
 
-	cfg, err := awsconfig.LoadDefaultConfig(ctx,
-		awsconfig.WithRegion(dynamoConfig.Region),
-		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(dynamoConfig.AccessKeyID, dynamoConfig.SecretAccessKey, "")),
-	)
+	client, err := newDynamoDBClient(ctx, dynamoConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load aws config: %w", err)
+		return nil, err
 	}
 
-	client := dynamodb.NewFromConfig(cfg)
+	switch {
+	case dynamoConfig.BackupMethod == job.DynamoDBMethodExportS3:
+		return a.dynamoDBExport(ctx, input.Job.ID, dynamoConfig, client)
+	case dynamoConfig.BackupMethod == job.DynamoDBMethodOnDemand:
+		return a.dynamoDBOnDemandBackup(ctx, input.Job.ID, dynamoConfig, client)
+	case dynamoConfig.MultiTable():
+		return a.dynamoDBDumpTables(ctx, input.Job.ID, dynamoConfig, client)
+	}
 
-	fileName := fmt.Sprintf("%s.json", input.Job.ID)
+	fileName := input.Job.ID + dynamoDBDumpExtension
 	filePath := filepath.Join(a.Config.TempDir, fileName)
 
+	// The scan writes nothing while max_read_capacity holds it back
+	progress := a.newProgressReporter(ctx, 0)
+	stop := progress.Keepalive()
+	items, err := dynamoDBScanTableFile(ctx, client, dynamoConfig, dynamoConfig.TableName, filePath, progress)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	progress.Done()
+
+	logger.Info("AWSDynamoDBDumpActivity completed", "filePath", filePath, "items", items)
+	return a.hashAndReturn(filePath, fileName, "application/x-ndjson")
+}
+
+// dynamoDBScanTableFile scans table into the DynamoDB JSON file filePath, also writing what it
+// writes to progress, and returns the number of items
+func dynamoDBScanTableFile(ctx context.Context, client *dynamodb.Client, cfg *job.AWSDynamoDBConfig, table, filePath string, progress io.Writer) (int64, error) {
 	file, err := os.Create(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+		return 0, fmt.Errorf("failed to create temp file: %w", err)
 	}
 	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	if _, err := file.WriteString("["); err != nil {
-		return nil, fmt.Errorf("failed to write start of json array: %w", err)
+	bw := bufio.NewWriter(file)
+	items, err := dynamoDBScanTable(ctx, client, cfg, table, io.MultiWriter(bw, progress))
+	if err != nil {
+		return 0, err
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write dump file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close dump file: %w", err)
+	}
+	return items, nil
+}
+
+// dynamoDBScanTable writes the items of table to w as DynamoDB JSON lines, scanning the
+// scan_segments segments of the table in parallel, and returns the number of items
+func dynamoDBScanTable(ctx context.Context, client *dynamodb.Client, cfg *job.AWSDynamoDBConfig, table string, w io.Writer) (int64, error) {
+	encoder := json.NewEncoder(w)
+	var mu sync.Mutex
+	var items int64
+	write := func(item map[string]types.AttributeValue) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := encoder.Encode(dynamoDBItemLine{Item: item}); err != nil {
+			return fmt.Errorf("failed to encode item: %w", err)
+		}
+		items++
+		return nil
+	}
+
+	var limiter *dynamoDBCapacityLimiter
+	if cfg.MaxReadCapacity > 0 {
+		limiter = &dynamoDBCapacityLimiter{perSecond: cfg.MaxReadCapacity}
+	}
+	segments := max(cfg.ScanSegments, 1)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(dynamoDBScanConcurrency)
+	for segment := range segments {
+		g.Go(func() error {
+			return dynamoDBScanSegment(gctx, client, cfg, table, segment, segments, limiter, write)
+		})
 	}
+	if err := g.Wait(); err != nil {
+		return 0, err
+	}
+	return items, nil
+}
 
-	paginator := dynamodb.NewScanPaginator(client, &dynamodb.ScanInput{
-		TableName: aws.String(dynamoConfig.TableName),
-	})
+// dynamoDBScanSegment scans one of total segments of the table and passes its items to write.
+// With a limiter, pages are limited to the items the per-second capacity can read and every page
+// first reserves the capacity the previous page of the segment consumed.
+func dynamoDBScanSegment(ctx context.Context, client *dynamodb.Client, cfg *job.AWSDynamoDBConfig, table string, segment, total int, limiter *dynamoDBCapacityLimiter, write func(map[string]types.AttributeValue) error) error {
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(table),
+		ConsistentRead:         aws.Bool(cfg.ConsistentRead),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	if total > 1 {
+		input.Segment = aws.Int32(int32(segment))
+		input.TotalSegments = aws.Int32(int32(total))
+	}
+	estimate := cfg.MaxReadCapacity
+	if limiter != nil {
+		input.Limit = aws.Int32(dynamoDBScanPageLimit(cfg))
+	}
 
-	firstItem := true
+	paginator := dynamodb.NewScanPaginator(client, input)
 	for paginator.HasMorePages() {
+		if err := limiter.reserve(ctx, estimate); err != nil {
+			return err
+		}
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan dynamodb table: %w", err)
+			return fmt.Errorf("failed to scan segment %d of dynamodb table %s: %w", segment, table, err)
+		}
+		if page.ConsumedCapacity != nil {
+			consumed := aws.ToFloat64(page.ConsumedCapacity.CapacityUnits)
+			limiter.settle(estimate, consumed)
+			estimate = consumed
 		}
 		for _, item := range page.Items {
-			if !firstItem {
-				if _, err := file.WriteString(","); err != nil {
-					return nil, fmt.Errorf("failed to write comma: %w", err)
-				}
-			}
-			simpleMap := make(map[string]any)
-			for k, v := range item {
-				simpleMap[k] = unmarshalAV(v)
-			}
-			if err := encoder.Encode(simpleMap); err != nil {
-				return nil, fmt.Errorf("failed to encode item: %w", err)
+			if err := write(item); err != nil {
+				return err
 			}
-			firstItem = false
 		}
 	}
+	return nil
+}
 
-	if _, err := file.WriteString("]"); err != nil {
-		return nil, fmt.Errorf("failed to write end of json array: %w", err)
+// dynamoDBScanPageLimit returns the Limit of the pages of a scan with max_read_capacity, the
+// number of items up to 4 KB a second of the capacity reads. An eventually consistent read
+// consumes half a unit per 4 KB.
+func dynamoDBScanPageLimit(cfg *job.AWSDynamoDBConfig) int32 {
+	items := cfg.MaxReadCapacity
+	if !cfg.ConsistentRead {
+		items *= 2
 	}
+	return int32(min(max(items, 1), math.MaxInt32))
+}
 
-	if _, err := file.Seek(0, 0); err != nil {
-		return nil, fmt.Errorf("failed to seek file: %w", err)
-	}
+// dynamoDBCapacityLimiter keeps the read capacity consumed by the segments of a scan at
+// perSecond on average. Each page reserves the capacity it is expected to consume before it is
+// read, which moves the start of the pages reserved after it back; once it is read the
+// reservation is corrected by what it actually consumed. A nil limiter does not limit.
+type dynamoDBCapacityLimiter struct {
+	perSecond float64
 
-	fi, err := file.Stat()
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat file: %w", err)
+	mu   sync.Mutex
+	next time.Time
+}
+
+// reserve takes units of capacity and blocks until the capacity reserved before is paid off
+func (l *dynamoDBCapacityLimiter) reserve(ctx context.Context, units float64) error {
+	if l == nil {
+		return nil
 	}
+	l.mu.Lock()
+	if now := time.Now(); l.next.Before(now) {
+		l.next = now
+	}
+	start := l.next
+	l.next = l.next.Add(l.duration(units))
+	l.mu.Unlock()
 
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return nil, fmt.Errorf("failed to calculate hash: %w", err)
+	d := time.Until(start)
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
 	}
+}
 
-	logger.Info("AWSDynamoDBDumpActivity completed", "filePath", filePath, "size", fi.Size())
+// settle corrects a reservation of reserved units to the units the page consumed
+func (l *dynamoDBCapacityLimiter) settle(reserved, consumed float64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.next = l.next.Add(l.duration(consumed - reserved))
+}
 
-	return &DownloadActivityOutput{
-		FilePath: filePath,
-		Size:     fi.Size(),
-		Checksum: fmt.Sprintf("%x", hash.Sum(nil)),
-		Name:     fileName,
-		MimeType: "application/json",
-	}, nil
+// duration returns the time units of capacity take at perSecond
+func (l *dynamoDBCapacityLimiter) duration(units float64) time.Duration {
+	return time.Duration(units / l.perSecond * float64(time.Second))
 }
 
-func unmarshalAV(av types.AttributeValue) any {
-	switch v := av.(type) {
-	case *types.AttributeValueMemberS:
-		return v.Value
-	case *types.AttributeValueMemberN:
-		return v.Value
-	case *types.AttributeValueMemberBOOL:
-		return v.Value
-	case *types.AttributeValueMemberNULL:
-		return nil
-	case *types.AttributeValueMemberM:
-		result := make(map[string]any)
-		for k, val := range v.Value {
-			result[k] = unmarshalAV(val)
-		}
-		return result
-	case *types.AttributeValueMemberL:
-		result := make([]any, len(v.Value))
-		for i, val := range v.Value {
-			result[i] = unmarshalAV(val)
-		}
-		return result
-	case *types.AttributeValueMemberBS:
-		return v.Value
-	case *types.AttributeValueMemberNS:
-		return v.Value
-	case *types.AttributeValueMemberSS:
-		return v.Value
-	case *types.AttributeValueMemberB:
-		return v.Value
-	default:
-		return nil
+// newDynamoDBClient creates a DynamoDB client for the job's region and credentials
+func newDynamoDBClient(ctx context.Context, dynamoConfig *job.AWSDynamoDBConfig) (*dynamodb.Client, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(dynamoConfig.Region),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(dynamoConfig.AccessKeyID, dynamoConfig.SecretAccessKey, "")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
 	}
+	return dynamodb.NewFromConfig(cfg), nil
 }
@@ -0,0 +1,277 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// dynamoDBPollInterval is how often the status of exports and on-demand backups is checked
+	dynamoDBPollInterval = 30 * time.Second
+	// dynamoDBExportExtension and dynamoDBBackupExtension name the records of exports left in S3
+	// and of on-demand backups, which the restore tells apart by name
+	dynamoDBExportExtension = ".export.json"
+	dynamoDBBackupExtension = ".backup.json"
+	// dynamoDBExportArchiveExtension names the archives of downloaded exports
+	dynamoDBExportArchiveExtension = ".export.zip"
+)
+
+// dynamoDBExportRecord is the backup of an export that stays in S3
+type dynamoDBExportRecord struct {
+	Table           string    `json:"table"`
+	ExportArn       string    `json:"export_arn"`
+	ExportTime      time.Time `json:"export_time"`
+	Format          string    `json:"format"`
+	S3Bucket        string    `json:"s3_bucket"`
+	S3Prefix        string    `json:"s3_prefix,omitempty"`
+	ManifestKey     string    `json:"manifest_key"`
+	ItemCount       int64     `json:"item_count"`
+	BilledSizeBytes int64     `json:"billed_size_bytes"`
+}
+
+// dynamoDBBackupRecord is the backup of an on-demand backup kept by DynamoDB
+type dynamoDBBackupRecord struct {
+	Table           string    `json:"table"`
+	BackupArn       string    `json:"backup_arn"`
+	BackupName      string    `json:"backup_name"`
+	BackupSizeBytes int64     `json:"backup_size_bytes"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// dynamoDBExport exports the table to S3 at the current time and waits for the export. The
+// client token is the workflow run, so a retried activity waits for the export it started.
+func (a *Activities) dynamoDBExport(ctx context.Context, jobId string, cfg *job.AWSDynamoDBConfig, client *dynamodb.Client) (*DownloadActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+
+	table, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(cfg.TableName)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table: %w", err)
+	}
+	input := &dynamodb.ExportTableToPointInTimeInput{
+		TableArn:     table.Table.TableArn,
+		S3Bucket:     aws.String(cfg.S3Bucket),
+		ExportFormat: types.ExportFormatDynamodbJson,
+		ClientToken:  aws.String(activity.GetInfo(ctx).WorkflowExecution.RunID),
+	}
+	if cfg.S3Prefix != "" {
+		input.S3Prefix = aws.String(cfg.S3Prefix)
+	}
+	started, err := client.ExportTableToPointInTime(ctx, input)
+	if err != nil {
+		var pitr *types.PointInTimeRecoveryUnavailableException
+		if errors.As(err, &pitr) {
+			return nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("point-in-time recovery is not enabled for table %s", cfg.TableName), "InvalidConfig", err)
+		}
+		return nil, fmt.Errorf("failed to start export: %w", err)
+	}
+	exportArn := aws.ToString(started.ExportDescription.ExportArn)
+	logger.Info("Waiting for DynamoDB export", "exportArn", exportArn)
+
+	progress := a.newProgressReporter(ctx, 0)
+	var desc *types.ExportDescription
+	for {
+		out, err := client.DescribeExport(ctx, &dynamodb.DescribeExportInput{ExportArn: aws.String(exportArn)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe export: %w", err)
+		}
+		desc = out.ExportDescription
+		if desc.ExportStatus == types.ExportStatusFailed {
+			return nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("export %s failed: %s: %s", exportArn, aws.ToString(desc.FailureCode), aws.ToString(desc.FailureMessage)),
+				"ExportFailed", nil)
+		}
+		if desc.ExportStatus == types.ExportStatusCompleted {
+			break
+		}
+		progress.Checkpoint()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(dynamoDBPollInterval):
+		}
+	}
+
+	record := dynamoDBExportRecord{
+		Table:           cfg.TableName,
+		ExportArn:       exportArn,
+		ExportTime:      aws.ToTime(desc.ExportTime),
+		Format:          string(desc.ExportFormat),
+		S3Bucket:        aws.ToString(desc.S3Bucket),
+		S3Prefix:        aws.ToString(desc.S3Prefix),
+		ManifestKey:     aws.ToString(desc.ExportManifest),
+		ItemCount:       aws.ToInt64(desc.ItemCount),
+		BilledSizeBytes: aws.ToInt64(desc.BilledSizeBytes),
+	}
+	logger.Info("DynamoDB export completed", "exportArn", exportArn, "items", record.ItemCount)
+
+	if cfg.ExportDownload {
+		return a.dynamoDBDownloadExport(ctx, jobId, cfg, record)
+	}
+	return a.writeDynamoDBRecord(jobId+dynamoDBExportExtension, record)
+}
+
+// dynamoDBDownloadExport zips the manifests and data files of a completed export
+func (a *Activities) dynamoDBDownloadExport(ctx context.Context, jobId string, cfg *job.AWSDynamoDBConfig, record dynamoDBExportRecord) (*DownloadActivityOutput, error) {
+	s3Config := &job.AWSS3Config{
+		Region:          cfg.Region,
+		Bucket:          record.S3Bucket,
+		Path:            dynamoDBExportDir(record.ManifestKey),
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+	}
+	client, err := newS3Client(ctx, s3Config)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []s3types.Object
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s3Config.Bucket),
+		Prefix: aws.String(s3Config.Path + "/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list export files: %w", err)
+		}
+		objects = append(objects, page.Contents...)
+	}
+	return a.s3DownloadAsZip(ctx, client, s3Config, jobId+dynamoDBExportArchiveExtension, objects)
+}
+
+// dynamoDBOnDemandBackup takes an on-demand backup of the table and waits until it is available
+func (a *Activities) dynamoDBOnDemandBackup(ctx context.Context, jobId string, cfg *job.AWSDynamoDBConfig, client *dynamodb.Client) (*DownloadActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+
+	name := fmt.Sprintf("%s-%s", jobId, time.Now().UTC().Format("20060102T150405Z"))
+	created, err := client.CreateBackup(ctx, &dynamodb.CreateBackupInput{
+		TableName:  aws.String(cfg.TableName),
+		BackupName: aws.String(name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
+	backupArn := aws.ToString(created.BackupDetails.BackupArn)
+	logger.Info("Waiting for DynamoDB backup", "backupArn", backupArn)
+
+	progress := a.newProgressReporter(ctx, 0)
+	details := created.BackupDetails
+	for details.BackupStatus != types.BackupStatusAvailable {
+		if details.BackupStatus == types.BackupStatusDeleted {
+			return nil, fmt.Errorf("backup %s was deleted before it became available", backupArn)
+		}
+		progress.Checkpoint()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(dynamoDBPollInterval):
+		}
+		out, err := client.DescribeBackup(ctx, &dynamodb.DescribeBackupInput{BackupArn: aws.String(backupArn)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe backup: %w", err)
+		}
+		details = out.BackupDescription.BackupDetails
+	}
+
+	logger.Info("DynamoDB backup completed", "backupArn", backupArn, "size", aws.ToInt64(details.BackupSizeBytes))
+	return a.writeDynamoDBRecord(jobId+dynamoDBBackupExtension, dynamoDBBackupRecord{
+		Table:           cfg.TableName,
+		BackupArn:       backupArn,
+		BackupName:      name,
+		BackupSizeBytes: aws.ToInt64(details.BackupSizeBytes),
+		CreatedAt:       aws.ToTime(details.BackupCreationDateTime),
+	})
+}
+
+// writeDynamoDBRecord writes the record of a backup that stays in AWS as the backup file
+func (a *Activities) writeDynamoDBRecord(name string, record any) (*DownloadActivityOutput, error) {
+	filePath := filepath.Join(a.Config.TempDir, name)
+	if err := writeJSONFile(filePath, record); err != nil {
+		return nil, fmt.Errorf("failed to write backup record: %w", err)
+	}
+	return a.hashAndReturn(filePath, name, "application/json")
+}
+
+// dynamoDBExportDir returns the directory of an export from the key of its manifest-summary.json:
+// <prefix>/AWSDynamoDB/<export id>
+func dynamoDBExportDir(manifestKey string) string {
+	return path.Dir(manifestKey)
+}
+
+// readDynamoDBRecord reads the record of a backup that stays in AWS
+func readDynamoDBRecord(filePath string, record any) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup record: %w", err)
+	}
+	if err := json.Unmarshal(data, record); err != nil {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("failed to decode backup record: %v", err), "InvalidBackup", err)
+	}
+	return nil
+}
+
+// isDynamoDBExport reports whether a backup file is the record or archive of an export
+func isDynamoDBExport(filePath string) bool {
+	return strings.HasSuffix(filePath, dynamoDBExportExtension) || strings.HasSuffix(filePath, dynamoDBExportArchiveExtension)
+}
+
+// dynamoDBRestoreBackup restores an on-demand backup into the new table table and waits until
+// the table is active
+func (a *Activities) dynamoDBRestoreBackup(ctx context.Context, filePath, table string, client *dynamodb.Client) (*RestoreActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+
+	var record dynamoDBBackupRecord
+	if err := readDynamoDBRecord(filePath, &record); err != nil {
+		return nil, err
+	}
+	_, err := client.RestoreTableFromBackup(ctx, &dynamodb.RestoreTableFromBackupInput{
+		BackupArn:       aws.String(record.BackupArn),
+		TargetTableName: aws.String(table),
+	})
+	if err != nil {
+		var inUse *types.TableAlreadyExistsException
+		if errors.As(err, &inUse) {
+			return nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("table %s already exists, backups restore into a new table", table), "InvalidRestoreTarget", err)
+		}
+		return nil, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	logger.Info("Waiting for DynamoDB table restore", "backupArn", record.BackupArn, "table", table)
+
+	progress := a.newProgressReporter(ctx, 0)
+	for {
+		out, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe table: %w", err)
+		}
+		if out.Table.TableStatus == types.TableStatusActive {
+			progress.Done()
+			logger.Info("DynamoDB table restored", "table", table, "items", aws.ToInt64(out.Table.ItemCount))
+			return &RestoreActivityOutput{Items: aws.ToInt64(out.Table.ItemCount)}, nil
+		}
+		progress.Checkpoint()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(dynamoDBPollInterval):
+		}
+	}
+}
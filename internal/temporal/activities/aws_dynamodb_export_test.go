@@ -0,0 +1,50 @@
+package activities
+
+import (
+	"agent/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamoDBExportDir(t *testing.T) {
+	assert.Equal(t, "backups/AWSDynamoDB/01700000000000-abcdef12",
+		dynamoDBExportDir("backups/AWSDynamoDB/01700000000000-abcdef12/manifest-summary.json"))
+	assert.Equal(t, "AWSDynamoDB/01700000000000-abcdef12",
+		dynamoDBExportDir("AWSDynamoDB/01700000000000-abcdef12/manifest-summary.json"))
+}
+
+func TestIsDynamoDBExport(t *testing.T) {
+	assert.True(t, isDynamoDBExport("/tmp/agent/job-1-restore-job-1.export.json"))
+	assert.True(t, isDynamoDBExport("/tmp/agent/job-1-restore-job-1.export.zip"))
+	assert.False(t, isDynamoDBExport("/tmp/agent/job-1-restore-job-1.backup.json"))
+	assert.False(t, isDynamoDBExport("/tmp/agent/job-1-restore-job-1.json"))
+}
+
+func TestDynamoDBRecord(t *testing.T) {
+	acts := &Activities{Config: &config.Config{TempDir: t.TempDir()}}
+	record := dynamoDBBackupRecord{
+		Table:           "orders",
+		BackupArn:       "arn:aws:dynamodb:eu-west-1:123456789012:table/orders/backup/01700000000000-abcdef12",
+		BackupName:      "job-1-20261017T120000Z",
+		BackupSizeBytes: 2048,
+		CreatedAt:       time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+	}
+
+	out, err := acts.writeDynamoDBRecord("job-1"+dynamoDBBackupExtension, record)
+	require.NoError(t, err)
+	assert.Equal(t, "job-1.backup.json", out.Name)
+	assert.Equal(t, "application/json", out.MimeType)
+
+	var read dynamoDBBackupRecord
+	require.NoError(t, readDynamoDBRecord(out.FilePath, &read))
+	assert.Equal(t, record, read)
+
+	bad := filepath.Join(t.TempDir(), "bad.backup.json")
+	require.NoError(t, os.WriteFile(bad, []byte("[1,2"), 0o644))
+	assert.ErrorContains(t, readDynamoDBRecord(bad, &read), "failed to decode backup record")
+}
@@ -0,0 +1,189 @@
+package activities
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.temporal.io/sdk/temporal"
+)
+
+// DynamoDB scan dumps are newline-delimited DynamoDB JSON, one {"Item":{...}} line per item
+// with every attribute typed ({"S":"a"}, {"N":"1"}, {"SS":["a"]}, ...), the format of the data
+// files of DynamoDB exports. Binary values are base64 encoded.
+const dynamoDBDumpExtension = ".ndjson"
+
+// dynamoDBItem is an item that encodes to and decodes from DynamoDB JSON
+type dynamoDBItem map[string]types.AttributeValue
+
+// dynamoDBItemLine is a line of a dump
+type dynamoDBItemLine struct {
+	Item dynamoDBItem `json:"Item"`
+}
+
+func (item dynamoDBItem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(item))
+	for k, v := range item {
+		typed, err := dynamoDBJSONValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %w", k, err)
+		}
+		m[k] = typed
+	}
+	return json.Marshal(m)
+}
+
+func (item *dynamoDBItem) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*item = make(dynamoDBItem, len(m))
+	for k, raw := range m {
+		av, err := parseDynamoDBJSONValue(raw)
+		if err != nil {
+			return fmt.Errorf("attribute %q: %w", k, err)
+		}
+		(*item)[k] = av
+	}
+	return nil
+}
+
+// dynamoDBJSONValue returns the DynamoDB JSON form of an attribute value, a single-key object
+// naming its type. []byte values are base64 encoded by encoding/json.
+func dynamoDBJSONValue(av types.AttributeValue) (map[string]any, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]any{"S": v.Value}, nil
+	case *types.AttributeValueMemberN:
+		return map[string]any{"N": v.Value}, nil
+	case *types.AttributeValueMemberB:
+		return map[string]any{"B": v.Value}, nil
+	case *types.AttributeValueMemberBOOL:
+		return map[string]any{"BOOL": v.Value}, nil
+	case *types.AttributeValueMemberNULL:
+		return map[string]any{"NULL": true}, nil
+	case *types.AttributeValueMemberSS:
+		return map[string]any{"SS": v.Value}, nil
+	case *types.AttributeValueMemberNS:
+		return map[string]any{"NS": v.Value}, nil
+	case *types.AttributeValueMemberBS:
+		return map[string]any{"BS": v.Value}, nil
+	case *types.AttributeValueMemberM:
+		m := make(map[string]any, len(v.Value))
+		for k, val := range v.Value {
+			typed, err := dynamoDBJSONValue(val)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = typed
+		}
+		return map[string]any{"M": m}, nil
+	case *types.AttributeValueMemberL:
+		l := make([]any, len(v.Value))
+		for i, val := range v.Value {
+			typed, err := dynamoDBJSONValue(val)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = typed
+		}
+		return map[string]any{"L": l}, nil
+	default:
+		return nil, fmt.Errorf("unsupported attribute value %T", av)
+	}
+}
+
+// parseDynamoDBJSONValue is the inverse of dynamoDBJSONValue
+func parseDynamoDBJSONValue(data []byte) (types.AttributeValue, error) {
+	var typed map[string]json.RawMessage
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, err
+	}
+	if len(typed) != 1 {
+		return nil, fmt.Errorf("expected one type, found %d", len(typed))
+	}
+	var t string
+	var raw json.RawMessage
+	for t, raw = range typed { // the only key
+	}
+	switch t {
+	case "S":
+		var v types.AttributeValueMemberS
+		return &v, json.Unmarshal(raw, &v.Value)
+	case "N":
+		var v types.AttributeValueMemberN
+		return &v, json.Unmarshal(raw, &v.Value)
+	case "B":
+		var v types.AttributeValueMemberB
+		return &v, json.Unmarshal(raw, &v.Value)
+	case "BOOL":
+		var v types.AttributeValueMemberBOOL
+		return &v, json.Unmarshal(raw, &v.Value)
+	case "NULL":
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case "SS":
+		var v types.AttributeValueMemberSS
+		return &v, json.Unmarshal(raw, &v.Value)
+	case "NS":
+		var v types.AttributeValueMemberNS
+		return &v, json.Unmarshal(raw, &v.Value)
+	case "BS":
+		var v types.AttributeValueMemberBS
+		return &v, json.Unmarshal(raw, &v.Value)
+	case "M":
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, err
+		}
+		v := &types.AttributeValueMemberM{Value: make(map[string]types.AttributeValue, len(m))}
+		for k, val := range m {
+			av, err := parseDynamoDBJSONValue(val)
+			if err != nil {
+				return nil, err
+			}
+			v.Value[k] = av
+		}
+		return v, nil
+	case "L":
+		var l []json.RawMessage
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return nil, err
+		}
+		v := &types.AttributeValueMemberL{Value: make([]types.AttributeValue, len(l))}
+		for i, val := range l {
+			av, err := parseDynamoDBJSONValue(val)
+			if err != nil {
+				return nil, err
+			}
+			v.Value[i] = av
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", t)
+	}
+}
+
+// readDynamoDBJSONItems calls fn with every item of a DynamoDB JSON dump. Malformed lines are
+// InvalidBackup errors.
+func readDynamoDBJSONItems(r io.Reader, fn func(dynamoDBItem) error) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for n := 1; ; n++ {
+		var line dynamoDBItemLine
+		if err := dec.Decode(&line); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("failed to decode item %d: %v", n, err), "InvalidBackup", err)
+		}
+		if len(line.Item) == 0 {
+			return temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("item %d has no attributes", n), "InvalidBackup", nil)
+		}
+		if err := fn(line.Item); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamoDBItemJSON(t *testing.T) {
+	item := dynamoDBItem{
+		"id":      &types.AttributeValueMemberN{Value: "12345678901234567890.5"},
+		"name":    &types.AttributeValueMemberS{Value: "widget"},
+		"blob":    &types.AttributeValueMemberB{Value: []byte{0, 1, 2, 0xff}},
+		"active":  &types.AttributeValueMemberBOOL{Value: true},
+		"deleted": &types.AttributeValueMemberNULL{Value: true},
+		"tags":    &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"sizes":   &types.AttributeValueMemberNS{Value: []string{"1", "2.5"}},
+		"chunks":  &types.AttributeValueMemberBS{Value: [][]byte{{1}, {2, 3}}},
+		"meta": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"count": &types.AttributeValueMemberN{Value: "3"},
+			"list": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+				&types.AttributeValueMemberS{Value: "x"},
+				&types.AttributeValueMemberNS{Value: []string{"7"}},
+			}},
+		}},
+	}
+
+	data, err := json.Marshal(dynamoDBItemLine{Item: item})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"id":{"N":"12345678901234567890.5"}`)
+	assert.Contains(t, string(data), `"tags":{"SS":["a","b"]}`)
+	assert.Contains(t, string(data), `"blob":{"B":"AAEC/w=="}`)
+
+	var line dynamoDBItemLine
+	require.NoError(t, json.Unmarshal(data, &line))
+	assert.Equal(t, item, line.Item)
+}
+
+func TestParseDynamoDBJSONValueErrors(t *testing.T) {
+	for _, data := range []string{`"plain"`, `{}`, `{"S":"a","N":"1"}`, `{"X":"a"}`, `{"N":1}`, `{"M":{"a":{"Q":1}}}`} {
+		_, err := parseDynamoDBJSONValue([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestReadDynamoDBJSONItems(t *testing.T) {
+	dump := `{"Item":{"id":{"S":"1"}}}
+{"Item":{"id":{"S":"2"},"n":{"N":"5"}}}
+`
+	var items []dynamoDBItem
+	require.NoError(t, readDynamoDBJSONItems(strings.NewReader(dump), func(item dynamoDBItem) error {
+		items = append(items, item)
+		return nil
+	}))
+	require.Len(t, items, 2)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "5"}, items[1]["n"])
+
+	err := readDynamoDBJSONItems(strings.NewReader(dump+`{"Item":{"id":{"S":`), func(dynamoDBItem) error { return nil })
+	assert.ErrorContains(t, err, "failed to decode item 3")
+	err = readDynamoDBJSONItems(strings.NewReader(`{"id":{"S":"1"}}`), func(dynamoDBItem) error { return nil })
+	assert.ErrorContains(t, err, "item 1 has no attributes")
+}
+
+func TestReadDynamoDBArrayItems(t *testing.T) {
+	dump := `[{"pk":"42","sk":"AAE=","n":"7","tags":["a"]}]`
+	keyTypes := map[string]types.ScalarAttributeType{"pk": types.ScalarAttributeTypeN, "sk": types.ScalarAttributeTypeB}
+
+	var items []map[string]types.AttributeValue
+	require.NoError(t, readDynamoDBArrayItems(strings.NewReader(dump), keyTypes, func(item map[string]types.AttributeValue) error {
+		items = append(items, item)
+		return nil
+	}))
+	require.Len(t, items, 1)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "42"}, items[0]["pk"])
+	assert.Equal(t, &types.AttributeValueMemberB{Value: []byte{0, 1}}, items[0]["sk"])
+	// Non-key numbers stay strings in the old format
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "7"}, items[0]["n"])
+
+	err := readDynamoDBArrayItems(strings.NewReader(`{"Item":{}}`), keyTypes, func(map[string]types.AttributeValue) error { return nil })
+	assert.ErrorContains(t, err, "not a JSON array")
+}
+
+func TestDynamoDBCapacityLimiter(t *testing.T) {
+	var unlimited *dynamoDBCapacityLimiter
+	require.NoError(t, unlimited.reserve(context.Background(), 1000))
+	unlimited.settle(1000, 10)
+
+	// The first reservation starts at once and delays the next one by its capacity
+	limiter := &dynamoDBCapacityLimiter{perSecond: 1000}
+	require.NoError(t, limiter.reserve(context.Background(), 100))
+	start := time.Now()
+	require.NoError(t, limiter.reserve(context.Background(), 0))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// Pages that consumed less than they reserved give the difference back
+	limiter = &dynamoDBCapacityLimiter{perSecond: 1000}
+	require.NoError(t, limiter.reserve(context.Background(), 10000))
+	limiter.settle(10000, 0)
+	start = time.Now()
+	require.NoError(t, limiter.reserve(context.Background(), 0))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// Concurrent segments queue up behind each other's reservations
+	limiter = &dynamoDBCapacityLimiter{perSecond: 1000}
+	start = time.Now()
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, limiter.reserve(context.Background(), 25))
+		}()
+	}
+	wg.Wait()
+	assert.GreaterOrEqual(t, time.Since(start), 70*time.Millisecond)
+
+	limiter.settle(0, 10000)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, limiter.reserve(ctx, 1), context.Canceled)
+}
+
+func TestDynamoDBScanPageLimit(t *testing.T) {
+	assert.Equal(t, int32(200), dynamoDBScanPageLimit(&job.AWSDynamoDBConfig{MaxReadCapacity: 100}))
+	assert.Equal(t, int32(100), dynamoDBScanPageLimit(&job.AWSDynamoDBConfig{MaxReadCapacity: 100, ConsistentRead: true}))
+	assert.Equal(t, int32(1), dynamoDBScanPageLimit(&job.AWSDynamoDBConfig{MaxReadCapacity: 0.25, ConsistentRead: true}))
+}
@@ -0,0 +1,256 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// dynamoDBBatchSize is the BatchWriteItem limit on items per request
+	dynamoDBBatchSize = 25
+	// dynamoDBMaxBatchRetries bounds how often unprocessed items of a batch are sent again
+	dynamoDBMaxBatchRetries = 8
+)
+
+// AWSDynamoDBRestoreActivity writes the items of a DynamoDB dump into the job's table or the
+// restore target with BatchWriteItem. The table must exist. DynamoDB JSON dumps restore every
+// attribute with its type; dumps of earlier versions kept numbers and binary values as strings,
+// so only their key attributes get their type back from the table. Archives of several tables
+// restore each table under its own name and create the missing ones from their schema.
+// On-demand backups are restored by DynamoDB into a new table; exports are imported with
+// DynamoDB ImportTable instead.
+func (a *Activities) AWSDynamoDBRestoreActivity(ctx context.Context, input RestoreActivityInput) (*RestoreActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("AWSDynamoDBRestoreActivity started", "jobId", input.Job.ID)
+
+	dynamoConfig, err := job.LoadAs[*job.AWSDynamoDBConfig](*input.Job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load DynamoDB config: %w", err)
+	}
+	if err := dynamoConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid DynamoDB config: %w", err)
+	}
+	table := dynamoConfig.TableName
+	if input.Target.TableName != "" {
+		table = input.Target.TableName
+	}
+
+	client, err := newDynamoDBClient(ctx, dynamoConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if isDynamoDBExport(input.FilePath) {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"exports to S3 are restored by importing them into a new table with DynamoDB ImportTable", "RestoreUnsupported", nil)
+	}
+	if strings.HasSuffix(input.FilePath, dynamoDBBackupExtension) {
+		return a.dynamoDBRestoreBackup(ctx, input.FilePath, table, client)
+	}
+	if isDynamoDBTablesArchive(input.FilePath) {
+		if input.Target.TableName != "" {
+			return nil, temporal.NewNonRetryableApplicationError(
+				"archives of several tables restore into their own tables, target.table_name does not apply", "InvalidRestoreTarget", nil)
+		}
+		restored, err := a.dynamoDBRestoreTables(ctx, input.FilePath, client)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("AWSDynamoDBRestoreActivity completed", "items", restored)
+		return &RestoreActivityOutput{Items: restored}, nil
+	}
+	if table == "" {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"the job selects several tables, target.table_name is required to restore a single table dump", "InvalidRestoreTarget", nil)
+	}
+
+	desc, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("table %s does not exist", table), "InvalidRestoreTarget", err)
+		}
+		return nil, fmt.Errorf("failed to describe table: %w", err)
+	}
+	keyTypes := make(map[string]types.ScalarAttributeType)
+	for _, def := range desc.Table.AttributeDefinitions {
+		keyTypes[aws.ToString(def.AttributeName)] = def.AttributeType
+	}
+
+	file, err := os.Open(input.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dump file: %w", err)
+	}
+	defer file.Close()
+
+	var total int64
+	if fi, err := file.Stat(); err == nil {
+		total = fi.Size()
+	}
+	progress := a.newProgressReporter(ctx, total)
+
+	writer := newDynamoDBBatchWriter(ctx, client, table)
+	r := io.TeeReader(file, progress)
+	if strings.HasSuffix(input.FilePath, dynamoDBDumpExtension) {
+		err = readDynamoDBJSONItems(r, func(item dynamoDBItem) error { return writer.Put(item) })
+	} else {
+		err = readDynamoDBArrayItems(r, keyTypes, writer.Put)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	progress.Done()
+
+	logger.Info("AWSDynamoDBRestoreActivity completed", "table", table, "items", writer.Written())
+	return &RestoreActivityOutput{Items: writer.Written()}, nil
+}
+
+// dynamoDBBatchWriter puts items into a table in batches of dynamoDBBatchSize
+type dynamoDBBatchWriter struct {
+	ctx     context.Context
+	client  *dynamodb.Client
+	table   string
+	batch   []types.WriteRequest
+	written int64
+}
+
+func newDynamoDBBatchWriter(ctx context.Context, client *dynamodb.Client, table string) *dynamoDBBatchWriter {
+	return &dynamoDBBatchWriter{ctx: ctx, client: client, table: table, batch: make([]types.WriteRequest, 0, dynamoDBBatchSize)}
+}
+
+// Put adds an item to the batch, writing the batch once it is full
+func (w *dynamoDBBatchWriter) Put(item map[string]types.AttributeValue) error {
+	w.batch = append(w.batch, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	if len(w.batch) == dynamoDBBatchSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush writes the items of the batch
+func (w *dynamoDBBatchWriter) Flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	if err := writeDynamoDBBatch(w.ctx, w.client, w.table, w.batch); err != nil {
+		return err
+	}
+	w.written += int64(len(w.batch))
+	w.batch = w.batch[:0]
+	return nil
+}
+
+// Written returns how many items were written
+func (w *dynamoDBBatchWriter) Written() int64 { return w.written }
+
+// readDynamoDBArrayItems calls fn with every item of a dump written before DynamoDB JSON, a
+// JSON array of untyped items. Numbers and binary values were dumped as strings, so key
+// attributes are converted back to the type keyTypes declares for them.
+func readDynamoDBArrayItems(r io.Reader, keyTypes map[string]types.ScalarAttributeType, fn func(map[string]types.AttributeValue) error) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return temporal.NewNonRetryableApplicationError("dump file is not a JSON array", "InvalidBackup", err)
+	}
+
+	for dec.More() {
+		var item map[string]any
+		if err := dec.Decode(&item); err != nil {
+			return temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("failed to decode item: %v", err), "InvalidBackup", err)
+		}
+		av := make(map[string]types.AttributeValue, len(item))
+		for k, v := range item {
+			av[k] = marshalAV(v)
+			if s, ok := v.(string); ok {
+				switch keyTypes[k] {
+				case types.ScalarAttributeTypeN:
+					av[k] = &types.AttributeValueMemberN{Value: s}
+				case types.ScalarAttributeTypeB:
+					// Binary values are dumped base64 encoded by encoding/json
+					if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+						av[k] = &types.AttributeValueMemberB{Value: b}
+					}
+				}
+			}
+		}
+		if err := fn(av); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDynamoDBBatch writes a batch of items, sending the items DynamoDB left unprocessed
+// again with exponential backoff
+func writeDynamoDBBatch(ctx context.Context, client *dynamodb.Client, table string, batch []types.WriteRequest) error {
+	requests := map[string][]types.WriteRequest{table: batch}
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		out, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: requests})
+		if err != nil {
+			return fmt.Errorf("failed to write items: %w", err)
+		}
+		if len(out.UnprocessedItems) == 0 {
+			return nil
+		}
+		if attempt >= dynamoDBMaxBatchRetries {
+			return fmt.Errorf("%d items still unprocessed after %d retries", len(out.UnprocessedItems[table]), attempt)
+		}
+		requests = out.UnprocessedItems
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
+
+// marshalAV converts a value decoded from a JSON array dump back to an attribute value as far
+// as the untyped format allows: sets come back as lists, binary values and numbers as strings.
+func marshalAV(v any) types.AttributeValue {
+	switch v := v.(type) {
+	case nil:
+		return &types.AttributeValueMemberNULL{Value: true}
+	case string:
+		return &types.AttributeValueMemberS{Value: v}
+	case bool:
+		return &types.AttributeValueMemberBOOL{Value: v}
+	case json.Number:
+		return &types.AttributeValueMemberN{Value: v.String()}
+	case map[string]any:
+		m := make(map[string]types.AttributeValue, len(v))
+		for k, val := range v {
+			m[k] = marshalAV(val)
+		}
+		return &types.AttributeValueMemberM{Value: m}
+	case []any:
+		l := make([]types.AttributeValue, len(v))
+		for i, val := range v {
+			l[i] = marshalAV(val)
+		}
+		return &types.AttributeValueMemberL{Value: l}
+	default:
+		return &types.AttributeValueMemberS{Value: fmt.Sprint(v)}
+	}
+}
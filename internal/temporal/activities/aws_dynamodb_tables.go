@@ -0,0 +1,370 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// dynamoDBTablesExtension names the archives of jobs of several tables
+	dynamoDBTablesExtension = ".tables.tar"
+	// dynamoDBManifestFile is the root file of an archive, each table has a directory with its
+	// dynamoDBSchemaFile and dynamoDBItemsFile
+	dynamoDBManifestFile = "manifest.json"
+	dynamoDBSchemaFile   = "table.json"
+	dynamoDBItemsFile    = "items" + dynamoDBDumpExtension
+	// dynamoDBTablePollInterval is how often a table created by a restore is checked
+	dynamoDBTablePollInterval = 5 * time.Second
+)
+
+// dynamoDBManifest describes an archive of several tables, it is written to its manifest.json
+type dynamoDBManifest struct {
+	Version   int                     `json:"version"`
+	Region    string                  `json:"region"`
+	CreatedAt time.Time               `json:"created_at"`
+	Tables    []dynamoDBManifestTable `json:"tables"`
+}
+
+type dynamoDBManifestTable struct {
+	Name string `json:"name"`
+	// Schema and Items are the files of the table relative to the archive root
+	Schema string `json:"schema"`
+	Items  string `json:"items"`
+	// ItemCount is how many items the scan wrote to Items
+	ItemCount int64 `json:"item_count"`
+}
+
+// dynamoDBTableSchema is the DescribeTable and DescribeTimeToLive output of a table, from
+// which a restore recreates it
+type dynamoDBTableSchema struct {
+	Table      *types.TableDescription      `json:"table"`
+	TimeToLive *types.TimeToLiveDescription `json:"time_to_live,omitempty"`
+}
+
+// dynamoDBDumpTables scans each table of the job into a temp dir, next to its schema, and tars
+// it into <job>.tables.tar together with a manifest
+func (a *Activities) dynamoDBDumpTables(ctx context.Context, jobId string, cfg *job.AWSDynamoDBConfig, client *dynamodb.Client) (*DownloadActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+
+	tables, err := dynamoDBTables(ctx, client, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"no table matches the tables of the job", "NoTables", nil)
+	}
+
+	tempDir, err := os.MkdirTemp(a.Config.TempDir, jobId+"-dynamodb-tables-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	manifest := &dynamoDBManifest{Version: 1, Region: cfg.Region, CreatedAt: time.Now().UTC()}
+	// The scans write nothing while max_read_capacity holds them back
+	progress := a.newProgressReporter(ctx, 0)
+	stop := progress.Keepalive()
+	defer stop()
+	for _, table := range tables {
+		if err := os.Mkdir(filepath.Join(tempDir, table), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create temp dir: %w", err)
+		}
+		entry := dynamoDBManifestTable{
+			Name:   table,
+			Schema: path.Join(table, dynamoDBSchemaFile),
+			Items:  path.Join(table, dynamoDBItemsFile),
+		}
+
+		schema, err := describeDynamoDBTable(ctx, client, table)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeJSONFile(filepath.Join(tempDir, entry.Schema), schema); err != nil {
+			return nil, fmt.Errorf("failed to write schema of table %s: %w", table, err)
+		}
+		entry.ItemCount, err = dynamoDBScanTableFile(ctx, client, cfg, table, filepath.Join(tempDir, entry.Items), progress)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("DynamoDB table dumped", "table", table, "items", entry.ItemCount)
+		manifest.Tables = append(manifest.Tables, entry)
+	}
+	if err := writeJSONFile(filepath.Join(tempDir, dynamoDBManifestFile), manifest); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	stop()
+	progress.Done()
+
+	archiveName := jobId + dynamoDBTablesExtension
+	archivePath := filepath.Join(a.Config.TempDir, archiveName)
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-cf", archivePath, "-C", tempDir, "."), archivePath); err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w, output: %s", err, string(output))
+	}
+
+	out, err := a.hashAndReturn(archivePath, archiveName, "application/x-tar")
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("AWSDynamoDBDumpActivity completed", "filePath", archivePath, "size", out.Size, "tables", len(tables))
+	return out, nil
+}
+
+// dynamoDBTables returns the tables of the job: its table_name or tables, or the tables with
+// table_prefix or matching table_pattern
+func dynamoDBTables(ctx context.Context, client *dynamodb.Client, cfg *job.AWSDynamoDBConfig) ([]string, error) {
+	switch {
+	case !cfg.MultiTable():
+		return []string{cfg.TableName}, nil
+	case len(cfg.Tables) > 0:
+		return cfg.Tables, nil
+	}
+
+	var names []string
+	paginator := dynamodb.NewListTablesPaginator(client, &dynamodb.ListTablesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		names = append(names, page.TableNames...)
+	}
+	return selectDynamoDBTables(names, cfg.TablePrefix, cfg.TablePattern)
+}
+
+// selectDynamoDBTables returns the sorted names with prefix, or matching the regular expression
+// pattern when it is set
+func selectDynamoDBTables(names []string, prefix, pattern string) ([]string, error) {
+	var re *regexp.Regexp
+	if pattern != "" {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid table_pattern: %w", err)
+		}
+	}
+	var selected []string
+	for _, name := range names {
+		if (re != nil && re.MatchString(name)) || (re == nil && strings.HasPrefix(name, prefix)) {
+			selected = append(selected, name)
+		}
+	}
+	slices.Sort(selected)
+	return selected, nil
+}
+
+// describeDynamoDBTable returns the schema of table
+func describeDynamoDBTable(ctx context.Context, client *dynamodb.Client, table string) (*dynamoDBTableSchema, error) {
+	desc, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", table, err)
+	}
+	ttl, err := client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(table)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe time to live of table %s: %w", table, err)
+	}
+	return &dynamoDBTableSchema{Table: desc.Table, TimeToLive: ttl.TimeToLiveDescription}, nil
+}
+
+// writeJSONFile writes v indented to filePath
+func writeJSONFile(filePath string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append(data, '\n'), 0o644)
+}
+
+// isDynamoDBTablesArchive reports whether a backup file is an archive of several tables by its
+// name, which keeps a gzip layer on download
+func isDynamoDBTablesArchive(filePath string) bool {
+	return strings.HasSuffix(filePath, dynamoDBTablesExtension) || strings.HasSuffix(filePath, dynamoDBTablesExtension+".gz")
+}
+
+// dynamoDBRestoreTables restores each table of an archive into the table of the same name,
+// which is created from its schema when missing, and returns the number of items restored
+func (a *Activities) dynamoDBRestoreTables(ctx context.Context, archivePath string, client *dynamodb.Client) (int64, error) {
+	logger := activity.GetLogger(ctx)
+
+	dir, err := os.MkdirTemp(filepath.Dir(archivePath), filepath.Base(archivePath)+"-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-xf", archivePath, "-C", dir), dir); err != nil {
+		return 0, fmt.Errorf("failed to extract archive: %w, output: %s", err, string(output))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, dynamoDBManifestFile))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest dynamoDBManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return 0, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("failed to decode manifest: %v", err), "InvalidBackup", err)
+	}
+
+	var total int64
+	for _, table := range manifest.Tables {
+		total += pathSize(filepath.Join(dir, filepath.FromSlash(table.Items)))
+	}
+	progress := a.newProgressReporter(ctx, total)
+
+	var restored int64
+	for _, table := range manifest.Tables {
+		var schema dynamoDBTableSchema
+		if err := readDynamoDBRecord(filepath.Join(dir, filepath.FromSlash(table.Schema)), &schema); err != nil {
+			return restored, err
+		}
+		if schema.Table == nil {
+			return restored, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("the schema of table %s is empty", table.Name), "InvalidBackup", nil)
+		}
+		if err := a.ensureDynamoDBTable(ctx, client, table.Name, &schema, progress); err != nil {
+			return restored, err
+		}
+
+		file, err := os.Open(filepath.Join(dir, filepath.FromSlash(table.Items)))
+		if err != nil {
+			return restored, fmt.Errorf("failed to open items of table %s: %w", table.Name, err)
+		}
+		writer := newDynamoDBBatchWriter(ctx, client, table.Name)
+		err = readDynamoDBJSONItems(io.TeeReader(file, progress), func(item dynamoDBItem) error { return writer.Put(item) })
+		file.Close()
+		if err == nil {
+			err = writer.Flush()
+		}
+		restored += writer.Written()
+		if err != nil {
+			return restored, fmt.Errorf("table %s: %w", table.Name, err)
+		}
+		if writer.Written() != table.ItemCount {
+			return restored, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("table %s has %d items, the manifest counts %d", table.Name, writer.Written(), table.ItemCount), "InvalidBackup", nil)
+		}
+		logger.Info("DynamoDB table restored", "table", table.Name, "items", writer.Written())
+	}
+	progress.Done()
+	return restored, nil
+}
+
+// ensureDynamoDBTable creates table from schema unless it exists, waits until it is active and
+// enables its time to live. Existing tables are written into as they are.
+func (a *Activities) ensureDynamoDBTable(ctx context.Context, client *dynamodb.Client, table string, schema *dynamoDBTableSchema, progress *progressReporter) error {
+	_, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err == nil {
+		return nil
+	}
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("failed to describe table %s: %w", table, err)
+	}
+
+	if _, err := client.CreateTable(ctx, dynamoDBCreateTableInput(table, schema.Table)); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+	for {
+		out, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		if err != nil {
+			return fmt.Errorf("failed to describe table %s: %w", table, err)
+		}
+		if out.Table.TableStatus == types.TableStatusActive {
+			break
+		}
+		progress.Checkpoint()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(dynamoDBTablePollInterval):
+		}
+	}
+
+	if ttl := schema.TimeToLive; ttl != nil && ttl.AttributeName != nil &&
+		(ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled || ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+		if _, err := client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(table),
+			TimeToLiveSpecification: &types.TimeToLiveSpecification{
+				AttributeName: ttl.AttributeName,
+				Enabled:       aws.Bool(true),
+			},
+		}); err != nil {
+			return fmt.Errorf("failed to enable time to live of table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// dynamoDBCreateTableInput returns the CreateTable request that recreates the table desc
+// describes as table: its keys, indexes, billing mode, stream and table class. Encryption,
+// tags, replicas and deletion protection are left to the defaults.
+func dynamoDBCreateTableInput(table string, desc *types.TableDescription) *dynamodb.CreateTableInput {
+	input := &dynamodb.CreateTableInput{
+		TableName:            aws.String(table),
+		AttributeDefinitions: desc.AttributeDefinitions,
+		KeySchema:            desc.KeySchema,
+		BillingMode:          types.BillingModeProvisioned,
+	}
+	onDemand := desc.BillingModeSummary != nil && desc.BillingModeSummary.BillingMode == types.BillingModePayPerRequest
+	if onDemand {
+		input.BillingMode = types.BillingModePayPerRequest
+	} else {
+		input.ProvisionedThroughput = dynamoDBThroughput(desc.ProvisionedThroughput)
+	}
+
+	for _, gsi := range desc.GlobalSecondaryIndexes {
+		index := types.GlobalSecondaryIndex{
+			IndexName:  gsi.IndexName,
+			KeySchema:  gsi.KeySchema,
+			Projection: gsi.Projection,
+		}
+		if !onDemand {
+			index.ProvisionedThroughput = dynamoDBThroughput(gsi.ProvisionedThroughput)
+		}
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, index)
+	}
+	for _, lsi := range desc.LocalSecondaryIndexes {
+		input.LocalSecondaryIndexes = append(input.LocalSecondaryIndexes, types.LocalSecondaryIndex{
+			IndexName:  lsi.IndexName,
+			KeySchema:  lsi.KeySchema,
+			Projection: lsi.Projection,
+		})
+	}
+	if stream := desc.StreamSpecification; stream != nil && aws.ToBool(stream.StreamEnabled) {
+		input.StreamSpecification = stream
+	}
+	if desc.TableClassSummary != nil {
+		input.TableClass = desc.TableClassSummary.TableClass
+	}
+	return input
+}
+
+// dynamoDBThroughput returns the provisioned throughput of a description, at least one unit
+// each as CreateTable requires
+func dynamoDBThroughput(desc *types.ProvisionedThroughputDescription) *types.ProvisionedThroughput {
+	var read, write int64
+	if desc != nil {
+		read, write = aws.ToInt64(desc.ReadCapacityUnits), aws.ToInt64(desc.WriteCapacityUnits)
+	}
+	return &types.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(max(read, 1)),
+		WriteCapacityUnits: aws.Int64(max(write, 1)),
+	}
+}
@@ -0,0 +1,120 @@
+package activities
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectDynamoDBTables(t *testing.T) {
+	names := []string{"prod-orders", "dev-orders", "prod-users", "prod_archive"}
+
+	selected, err := selectDynamoDBTables(names, "prod-", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod-orders", "prod-users"}, selected)
+
+	selected, err = selectDynamoDBTables(names, "", `-orders$`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev-orders", "prod-orders"}, selected)
+
+	selected, err = selectDynamoDBTables(names, "staging-", "")
+	require.NoError(t, err)
+	assert.Empty(t, selected)
+
+	_, err = selectDynamoDBTables(names, "", "(")
+	assert.Error(t, err)
+}
+
+func TestIsDynamoDBTablesArchive(t *testing.T) {
+	assert.True(t, isDynamoDBTablesArchive("/tmp/agent/job-1-restore-job-1.tables.tar"))
+	assert.True(t, isDynamoDBTablesArchive("/tmp/agent/job-1-restore-job-1.tables.tar.gz"))
+	assert.False(t, isDynamoDBTablesArchive("/tmp/agent/job-1-restore-job-1.ndjson"))
+}
+
+func dynamoDBTestTable() *types.TableDescription {
+	keys := []types.KeySchemaElement{
+		{AttributeName: aws.String("pk"), KeyType: types.KeyTypeHash},
+		{AttributeName: aws.String("sk"), KeyType: types.KeyTypeRange},
+	}
+	return &types.TableDescription{
+		TableName:        aws.String("orders"),
+		TableStatus:      types.TableStatusActive,
+		ItemCount:        aws.Int64(10),
+		CreationDateTime: aws.Time(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("pk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sk"), AttributeType: types.ScalarAttributeTypeN},
+			{AttributeName: aws.String("status"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: keys,
+		ProvisionedThroughput: &types.ProvisionedThroughputDescription{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(0),
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{{
+			IndexName:   aws.String("by-status"),
+			IndexStatus: types.IndexStatusActive,
+			KeySchema:   []types.KeySchemaElement{{AttributeName: aws.String("status"), KeyType: types.KeyTypeHash}},
+			Projection:  &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+			ProvisionedThroughput: &types.ProvisionedThroughputDescription{
+				ReadCapacityUnits:  aws.Int64(2),
+				WriteCapacityUnits: aws.Int64(3),
+			},
+		}},
+		LocalSecondaryIndexes: []types.LocalSecondaryIndexDescription{{
+			IndexName:  aws.String("by-pk-status"),
+			KeySchema:  []types.KeySchemaElement{keys[0], {AttributeName: aws.String("status"), KeyType: types.KeyTypeRange}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+		StreamSpecification: &types.StreamSpecification{StreamEnabled: aws.Bool(true), StreamViewType: types.StreamViewTypeNewImage},
+		TableClassSummary:   &types.TableClassSummary{TableClass: types.TableClassStandardInfrequentAccess},
+	}
+}
+
+func TestDynamoDBCreateTableInput(t *testing.T) {
+	desc := dynamoDBTestTable()
+
+	input := dynamoDBCreateTableInput("orders-restored", desc)
+	assert.Equal(t, "orders-restored", aws.ToString(input.TableName))
+	assert.Equal(t, desc.KeySchema, input.KeySchema)
+	assert.Equal(t, desc.AttributeDefinitions, input.AttributeDefinitions)
+	assert.Equal(t, types.BillingModeProvisioned, input.BillingMode)
+	assert.Equal(t, &types.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(5), WriteCapacityUnits: aws.Int64(1)}, input.ProvisionedThroughput)
+	require.Len(t, input.GlobalSecondaryIndexes, 1)
+	assert.Equal(t, "by-status", aws.ToString(input.GlobalSecondaryIndexes[0].IndexName))
+	assert.Equal(t, int64(3), aws.ToInt64(input.GlobalSecondaryIndexes[0].ProvisionedThroughput.WriteCapacityUnits))
+	require.Len(t, input.LocalSecondaryIndexes, 1)
+	assert.Equal(t, types.ProjectionTypeAll, input.LocalSecondaryIndexes[0].Projection.ProjectionType)
+	assert.Equal(t, types.StreamViewTypeNewImage, input.StreamSpecification.StreamViewType)
+	assert.Equal(t, types.TableClassStandardInfrequentAccess, input.TableClass)
+
+	desc.BillingModeSummary = &types.BillingModeSummary{BillingMode: types.BillingModePayPerRequest}
+	desc.StreamSpecification = &types.StreamSpecification{StreamEnabled: aws.Bool(false)}
+	input = dynamoDBCreateTableInput("orders", desc)
+	assert.Equal(t, types.BillingModePayPerRequest, input.BillingMode)
+	assert.Nil(t, input.ProvisionedThroughput)
+	assert.Nil(t, input.GlobalSecondaryIndexes[0].ProvisionedThroughput)
+	assert.Nil(t, input.StreamSpecification)
+}
+
+func TestDynamoDBTableSchemaJSON(t *testing.T) {
+	schema := dynamoDBTableSchema{
+		Table: dynamoDBTestTable(),
+		TimeToLive: &types.TimeToLiveDescription{
+			AttributeName:    aws.String("expires_at"),
+			TimeToLiveStatus: types.TimeToLiveStatusEnabled,
+		},
+	}
+	data, err := json.Marshal(schema)
+	require.NoError(t, err)
+
+	var read dynamoDBTableSchema
+	require.NoError(t, json.Unmarshal(data, &read))
+	assert.Equal(t, dynamoDBCreateTableInput("orders", schema.Table), dynamoDBCreateTableInput("orders", read.Table))
+	assert.Equal(t, schema.TimeToLive, read.TimeToLive)
+}
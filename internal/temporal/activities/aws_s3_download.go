@@ -4,6 +4,7 @@ import (
 	"agent/internal/job"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"go.temporal.io/sdk/activity"
 )
 
@@ -24,46 +26,70 @@ func (a *Activities) AWSS3DownloadActivity(ctx context.Context, input AWSS3Downl
 	logger := activity.GetLogger(ctx)
 	logger.Info("AWSS3DownloadActivity started", "jobId", input.Job.ID)
 
-	s3Config, err := job.LoadAs[*job.AWSS3Config](*input.Job)
+	s3Config, client, objects, err := s3ListJobObjects(ctx, input.Job)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS S3 config: %w", err)
+		return nil, err
 	}
-	if err := s3Config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid AWS S3 config: %w", err)
+
+	// Single file exact match
+	if key, ok := s3SingleObject(s3Config, objects); ok {
+		return a.s3DownloadSingleFile(ctx, client, s3Config, input.Job.ID, key)
 	}
 
-	cfg, err := awsconfig.LoadDefaultConfig(ctx,
-		awsconfig.WithRegion(s3Config.Region),
-		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(s3Config.AccessKeyID, s3Config.SecretAccessKey, "")),
-	)
+	return a.s3DownloadAsZip(ctx, client, s3Config, input.Job.ID+".zip", objects)
+}
+
+// s3ListJobObjects connects to the job's bucket and lists the objects under its path
+func s3ListJobObjects(ctx context.Context, j *job.Job) (*job.AWSS3Config, *s3.Client, []s3types.Object, error) {
+	s3Config, err := job.LoadAs[*job.AWSS3Config](*j)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load aws config: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to load AWS S3 config: %w", err)
 	}
-	if s3Config.Endpoint != "" {
-		cfg.BaseEndpoint = aws.String(s3Config.Endpoint)
+	if err := s3Config.Validate(); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid AWS S3 config: %w", err)
 	}
 
-	client := s3.NewFromConfig(cfg)
+	client, err := newS3Client(ctx, s3Config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	objects, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 		Bucket: aws.String(s3Config.Bucket),
 		Prefix: aws.String(s3Config.Path),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list objects: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to list objects: %w", err)
 	}
 	if len(objects.Contents) == 0 {
-		return nil, fmt.Errorf("no objects found at path: %s", s3Config.Path)
+		return nil, nil, nil, fmt.Errorf("no objects found at path: %s", s3Config.Path)
 	}
 
-	isDir := strings.HasSuffix(s3Config.Path, "/")
+	return s3Config, client, objects.Contents, nil
+}
 
-	// Single file exact match
-	if len(objects.Contents) == 1 && *objects.Contents[0].Key == s3Config.Path && !isDir {
-		return a.s3DownloadSingleFile(ctx, client, s3Config, input.Job.ID, *objects.Contents[0].Key)
+// newS3Client creates an S3 client for the job's region, credentials and endpoint
+func newS3Client(ctx context.Context, s3Config *job.AWSS3Config, optFns ...func(*s3.Options)) (*s3.Client, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(s3Config.Region),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(s3Config.AccessKeyID, s3Config.SecretAccessKey, "")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	if s3Config.Endpoint != "" {
+		cfg.BaseEndpoint = aws.String(s3Config.Endpoint)
 	}
+	return s3.NewFromConfig(cfg, optFns...), nil
+}
 
-	return a.s3DownloadAsZip(ctx, client, s3Config, input.Job.ID, objects.Contents)
+// s3SingleObject returns the key of the object when the job path names exactly one object
+func s3SingleObject(cfg *job.AWSS3Config, objects []s3types.Object) (string, bool) {
+	isDir := strings.HasSuffix(cfg.Path, "/")
+	if len(objects) == 1 && *objects[0].Key == cfg.Path && !isDir {
+		return *objects[0].Key, true
+	}
+	return "", false
 }
 
 func (a *Activities) s3DownloadSingleFile(ctx context.Context, client *s3.Client, cfg *job.AWSS3Config, jobID, key string) (*DownloadActivityOutput, error) {
@@ -77,12 +103,46 @@ func (a *Activities) s3DownloadSingleFile(ctx context.Context, client *s3.Client
 	defer file.Close()
 
 	downloader := manager.NewDownloader(client)
-	if _, err = downloader.Download(ctx, file, &s3.GetObjectInput{
+	stop := a.newProgressReporter(ctx, 0).Watch(tempFilePath)
+	_, err = downloader.Download(ctx, file, &s3.GetObjectInput{
 		Bucket: aws.String(cfg.Bucket),
 		Key:    aws.String(key),
-	}); err != nil {
+	})
+	stop()
+	if err != nil {
 		return nil, fmt.Errorf("failed to download object: %w", err)
 	}
 
 	return a.hashAndReturn(tempFilePath, fileName, "application/octet-stream")
 }
+
+// streamAWSS3 is the streaming source of AWS S3 jobs. A single object is copied as is, several
+// objects are written as a zip archive.
+func (a *Activities) streamAWSS3(ctx context.Context, j *job.Job, w io.Writer) (string, string, error) {
+	s3Config, client, objects, err := s3ListJobObjects(ctx, j)
+	if err != nil {
+		return "", "", err
+	}
+
+	key, ok := s3SingleObject(s3Config, objects)
+	if !ok {
+		if err := writeS3Zip(ctx, client, s3Config, objects, w, io.Discard); err != nil {
+			return "", "", err
+		}
+		return fmt.Sprintf("%s.zip", j.ID), "application/zip", nil
+	}
+
+	resp, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s3Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to download object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return "", "", fmt.Errorf("failed to download object: %w", err)
+	}
+	return filepath.Base(key), "application/octet-stream", nil
+}
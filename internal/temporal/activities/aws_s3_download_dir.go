@@ -16,8 +16,8 @@ import (
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
-func (a *Activities) s3DownloadAsZip(ctx context.Context, client *s3.Client, cfg *job.AWSS3Config, jobID string, objects []s3types.Object) (*DownloadActivityOutput, error) {
-	fileName := fmt.Sprintf("%s.zip", jobID)
+// s3DownloadAsZip downloads the objects into the zip archive fileName in TempDir
+func (a *Activities) s3DownloadAsZip(ctx context.Context, client *s3.Client, cfg *job.AWSS3Config, fileName string, objects []s3types.Object) (*DownloadActivityOutput, error) {
 	tempFilePath := filepath.Join(a.Config.TempDir, fileName)
 
 	zipFile, err := os.Create(tempFilePath)
@@ -26,7 +26,25 @@ func (a *Activities) s3DownloadAsZip(ctx context.Context, client *s3.Client, cfg
 	}
 	defer zipFile.Close()
 
-	zipWriter := zip.NewWriter(zipFile)
+	var total int64
+	for _, obj := range objects {
+		total += aws.ToInt64(obj.Size)
+	}
+	progress := a.newProgressReporter(ctx, total)
+
+	if err := writeS3Zip(ctx, client, cfg, objects, zipFile, progress); err != nil {
+		return nil, err
+	}
+	zipFile.Close()
+	progress.Done()
+
+	return a.hashAndReturn(tempFilePath, fileName, "application/zip")
+}
+
+// writeS3Zip writes the objects to w as a zip archive, named relative to the job path.
+// The bytes read from S3 are also written to progress.
+func writeS3Zip(ctx context.Context, client *s3.Client, cfg *job.AWSS3Config, objects []s3types.Object, w io.Writer, progress io.Writer) error {
+	zipWriter := zip.NewWriter(w)
 	defer zipWriter.Close()
 
 	for _, obj := range objects {
@@ -38,7 +56,7 @@ func (a *Activities) s3DownloadAsZip(ctx context.Context, client *s3.Client, cfg
 			Key:    obj.Key,
 		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to download object %s: %w", *obj.Key, err)
+			return fmt.Errorf("failed to download object %s: %w", *obj.Key, err)
 		}
 
 		relPath, err := filepath.Rel(cfg.Path, *obj.Key)
@@ -49,19 +67,16 @@ func (a *Activities) s3DownloadAsZip(ctx context.Context, client *s3.Client, cfg
 		f, err := zipWriter.Create(relPath)
 		if err != nil {
 			resp.Body.Close()
-			return nil, fmt.Errorf("failed to create zip entry for %s: %w", *obj.Key, err)
+			return fmt.Errorf("failed to create zip entry for %s: %w", *obj.Key, err)
 		}
-		if _, err := io.Copy(f, resp.Body); err != nil {
+		if _, err := io.Copy(f, io.TeeReader(resp.Body, progress)); err != nil {
 			resp.Body.Close()
-			return nil, fmt.Errorf("failed to write zip entry %s: %w", *obj.Key, err)
+			return fmt.Errorf("failed to write zip entry %s: %w", *obj.Key, err)
 		}
 		resp.Body.Close()
 	}
 
-	zipWriter.Close()
-	zipFile.Close()
-
-	return a.hashAndReturn(tempFilePath, fileName, "application/zip")
+	return zipWriter.Close()
 }
 
 // hashAndReturn computes SHA256 hash and returns DownloadActivityOutput
@@ -0,0 +1,107 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.temporal.io/sdk/activity"
+)
+
+// AWSS3RestoreActivity puts the objects of an S3 backup back into the job's bucket or the
+// restore target. A backup of several objects is a "<job>.zip" archive whose entries are named
+// relative to the job path; they are put under the target path. A backup of a single object is
+// put at the target path, which defaults to the key it was taken from, or into it when the
+// target path ends with a slash.
+func (a *Activities) AWSS3RestoreActivity(ctx context.Context, input RestoreActivityInput) (*RestoreActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("AWSS3RestoreActivity started", "jobId", input.Job.ID)
+
+	s3Config, err := job.LoadAs[*job.AWSS3Config](*input.Job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS S3 config: %w", err)
+	}
+	if err := s3Config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid AWS S3 config: %w", err)
+	}
+	bucket, prefix := s3Config.Bucket, s3Config.Path
+	if input.Target.Bucket != "" {
+		bucket = input.Target.Bucket
+	}
+	if input.Target.Path != "" {
+		prefix = input.Target.Path
+	}
+
+	client, err := newS3Client(ctx, s3Config)
+	if err != nil {
+		return nil, err
+	}
+	uploader := manager.NewUploader(client)
+	progress := a.newProgressReporter(ctx, 0)
+
+	put := func(key string, body io.Reader) error {
+		_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   io.TeeReader(body, progress),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to put object %s: %w", key, err)
+		}
+		return nil
+	}
+
+	if input.Name != fmt.Sprintf("%s.zip", input.Job.ID) {
+		file, err := os.Open(input.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open backup file: %w", err)
+		}
+		defer file.Close()
+		key := prefix
+		if key == "" || strings.HasSuffix(key, "/") {
+			key += input.Name
+		}
+		if err := put(key, file); err != nil {
+			return nil, err
+		}
+		progress.Done()
+		logger.Info("AWSS3RestoreActivity completed", "bucket", bucket, "key", key)
+		return &RestoreActivityOutput{Items: 1}, nil
+	}
+
+	archive, err := zip.OpenReader(input.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	defer archive.Close()
+
+	var restored int64
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zip entry %s: %w", f.Name, err)
+		}
+		// Entries may carry Windows separators when the backup was taken on Windows
+		err = put(path.Join(prefix, strings.ReplaceAll(f.Name, `\`, "/")), r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		restored++
+	}
+	progress.Done()
+
+	logger.Info("AWSS3RestoreActivity completed", "bucket", bucket, "prefix", prefix, "objects", restored)
+	return &RestoreActivityOutput{Items: restored}, nil
+}
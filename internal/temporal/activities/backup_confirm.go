@@ -15,6 +15,10 @@ type BackupConfirmActivityInput struct {
 	JobId    string `json:"job_id"`
 	BackupId string `json:"backup_id"`
 	Status   bool   `json:"status"`
+	Stage    string `json:"stage,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Verification is the result of the restore drill of a completed backup, when one ran
+	Verification *Verification `json:"verification,omitempty"`
 }
 
 type BackupConfirmActivityOutput struct {
@@ -42,6 +46,15 @@ func (a *Activities) BackupConfirmActivity(ctx context.Context, input BackupConf
 	reqBody := map[string]interface{}{
 		"status": input.Status,
 	}
+	if !input.Status {
+		reqBody["error"] = map[string]string{
+			"stage":   input.Stage,
+			"message": input.Error,
+		}
+	}
+	if input.Verification != nil {
+		reqBody["verification"] = input.Verification
+	}
 
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
@@ -79,6 +92,6 @@ func (a *Activities) BackupConfirmActivity(ctx context.Context, input BackupConf
 		Status: input.Status,
 	}
 
-	logger.Info("Backup confirmed", "status", result.Status)
+	logger.Info("Backup confirmed", "status", result.Status, "stage", input.Stage)
 	return result, nil
 }
@@ -46,9 +46,20 @@ func (a *Activities) BackupRequestActivity(ctx context.Context, input BackupRequ
 	logger.Info("Sending backup request", "url", url, "runId", runID)
 
 	// Create request body
-	reqBody := map[string]string{
+	reqBody := map[string]any{
 		"run_id": runID,
 	}
+	// WAL archives and other incrementals only restore on top of earlier backups
+	kind, err := a.backupKind(input.Job)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "" {
+		reqBody["kind"] = kind
+		if retention := input.Job.Config.(job.Incremental).BackupRetention(); retention > 0 {
+			reqBody["retention_seconds"] = int64(retention.Seconds())
+		}
+	}
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -0,0 +1,66 @@
+package activities
+
+import (
+	"agent/internal/config"
+	"agent/internal/job"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestBackupRequestActivity_ReportsIncrementalKind(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  job.Config
+		want map[string]any
+	}{
+		{"dump", &job.PostgreSQLConfig{ConnectionString: "postgres://db/app"}, map[string]any{}},
+		{"basebackup", &job.PostgreSQLConfig{ConnectionString: "postgres://db/app", Method: job.PostgreSQLMethodBaseBackup},
+			map[string]any{"kind": "full"}},
+		{"wal", &job.PostgreSQLConfig{ConnectionString: "postgres://db/app", Method: job.PostgreSQLMethodWAL,
+			Slot: "agent", Retention: 7 * 24 * time.Hour},
+			map[string]any{"kind": "incremental", "retention_seconds": float64(604800)}},
+		{"binlog without state", &job.MySQLConfig{ConnectionString: "app@tcp(db)/shop", Method: job.MySQLMethodBinlog},
+			map[string]any{"kind": "full"}},
+		{"binlog", &job.MySQLConfig{ConnectionString: "app@tcp(db)/shop", Method: job.MySQLMethodBinlog, Retention: 24 * time.Hour},
+			map[string]any{"kind": "incremental", "retention_seconds": float64(86400)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]any
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/workspaces/ws/jobs/job-1/request", r.URL.Path)
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id":"6f1c2f4e-8d43-4a55-9a7e-0b9f1d2c3e4f"}`))
+			}))
+			defer srv.Close()
+
+			testSuite := &testsuite.WorkflowTestSuite{}
+			env := testSuite.NewTestActivityEnvironment()
+			acts := &Activities{
+				Config:     &config.Config{API: srv.URL, StateDir: t.TempDir()},
+				Auth:       staticToken{},
+				Hub:        &config.HubConfig{Workspace: "ws"},
+				HTTPClient: srv.Client(),
+			}
+			env.RegisterActivity(acts.BackupRequestActivity)
+			if tt.name == "binlog" {
+				require.NoError(t, acts.saveBackupState("job-1", json.RawMessage(`{"file":"binlog.000002","position":4}`)))
+			}
+
+			_, err := env.ExecuteActivity(acts.BackupRequestActivity,
+				BackupRequestActivityInput{Job: &job.Job{ID: "job-1", Provider: tt.cfg.Type(), Config: tt.cfg}})
+			require.NoError(t, err)
+
+			delete(body, "run_id")
+			assert.Equal(t, tt.want, body)
+		})
+	}
+}
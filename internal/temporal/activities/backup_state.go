@@ -0,0 +1,106 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.temporal.io/sdk/activity"
+)
+
+type BackupStateSaveActivityInput struct {
+	JobId string `json:"job_id"`
+	// State is the DownloadActivityOutput State of the confirmed backup
+	State json.RawMessage `json:"state"`
+}
+
+// BackupStateSaveActivity saves the state an incremental backup continues from once the backup
+// it was taken with is confirmed, so a backup that failed to upload is taken again
+func (a *Activities) BackupStateSaveActivity(ctx context.Context, input BackupStateSaveActivityInput) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("BackupStateSaveActivity started", "jobId", input.JobId)
+
+	if err := a.saveBackupState(input.JobId, input.State); err != nil {
+		return err
+	}
+
+	logger.Info("BackupStateSaveActivity completed", "path", a.backupStatePath(input.JobId))
+	return nil
+}
+
+// backupStatePath returns the state file of a job in StateDir
+func (a *Activities) backupStatePath(jobId string) string {
+	return filepath.Join(a.Config.StateDir, jobId+".json")
+}
+
+// loadBackupState decodes the saved state of a job into v and reports whether there was one
+func (a *Activities) loadBackupState(jobId string, v any) (bool, error) {
+	data, err := os.ReadFile(a.backupStatePath(jobId))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read backup state: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to decode backup state: %w", err)
+	}
+	return true, nil
+}
+
+// saveBackupState replaces the state of a job. The state is written to a temp file first, so a
+// crash never leaves a partial state behind.
+func (a *Activities) saveBackupState(jobId string, state json.RawMessage) error {
+	if err := os.MkdirAll(a.Config.StateDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create state dir: %w", err)
+	}
+	file, err := os.CreateTemp(a.Config.StateDir, jobId+"-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(state); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(file.Name(), a.backupStatePath(jobId)); err != nil {
+		return fmt.Errorf("failed to save state file: %w", err)
+	}
+	return nil
+}
+
+// removeBackupState drops the state of a job, so its next backup is a full one
+func (a *Activities) removeBackupState(jobId string) error {
+	if err := os.Remove(a.backupStatePath(jobId)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove backup state: %w", err)
+	}
+	return nil
+}
+
+// backupKind returns the kind of the next backup of a job, "" for self-contained backups. Jobs
+// that continue from a saved state take a full backup while they have none.
+func (a *Activities) backupKind(j *job.Job) (string, error) {
+	inc, ok := j.Config.(job.Incremental)
+	if !ok {
+		return "", nil
+	}
+	if cfg, ok := j.Config.(*job.MySQLConfig); ok && cfg.Method == job.MySQLMethodBinlog {
+		var state mySQLBinlogState
+		found, err := a.loadBackupState(j.ID, &state)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return job.BackupKindFull, nil
+		}
+	}
+	return inc.BackupKind(), nil
+}
@@ -0,0 +1,346 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// streamPartSize is the part size of streamed uploads. Parts are buffered in memory, so
+	// up to UploadConcurrency+1 of them are held at once. With the S3 limit of 10,000 parts
+	// a streamed backup can be at most 320 GiB after compression.
+	streamPartSize = 32 << 20
+	// streamMaxParts is the S3 limit on the number of parts of a multipart upload
+	streamMaxParts = 10000
+	// streamURLBatch is how many part URLs are requested from the API at a time
+	streamURLBatch = 16
+	// streamURLRefreshes is how often the URL of a part is replaced after S3 rejected it
+	streamURLRefreshes = 3
+)
+
+// streamSource writes the backup of a job to w and returns its name and MIME type
+type streamSource func(a *Activities, ctx context.Context, j *job.Job, w io.Writer) (name, mimeType string, err error)
+
+// streamSources are the providers whose output can be streamed. The others write files with
+// external tools or need a seekable file and always use the file based pipeline.
+var streamSources = map[job.Provider]streamSource{
+	job.JobProviderHTTP:       (*Activities).streamHTTP,
+	job.JobProviderPostgreSQL: (*Activities).streamPostgreSQL,
+	job.JobProviderMySQL:      (*Activities).streamMySQL,
+	job.JobProviderAWSS3:      (*Activities).streamAWSS3,
+}
+
+// SupportsStreaming reports whether the backup of j can be streamed with BackupStreamActivity
+func SupportsStreaming(j *job.Job) bool {
+	// pg_dump writes the directory format into files only, cluster, base backups and WAL
+	// archives are archives of several files
+	if cfg, ok := j.Config.(*job.PostgreSQLConfig); ok && (cfg.Format == job.PostgreSQLFormatDirectory ||
+		cfg.Cluster || (cfg.Method != "" && cfg.Method != job.PostgreSQLMethodDump)) {
+		return false
+	}
+	// Binlog jobs save the position of each backup once it is confirmed, which streaming skips
+	if cfg, ok := j.Config.(*job.MySQLConfig); ok && cfg.Method == job.MySQLMethodBinlog {
+		return false
+	}
+	_, ok := streamSources[j.Provider]
+	return ok
+}
+
+type BackupStreamActivityInput struct {
+	Job      *job.Job `json:"job"`
+	JobId    string   `json:"job_id"`
+	BackupId string   `json:"backup_id"`
+}
+
+type BackupStreamActivityOutput struct {
+	UploadId string          `json:"upload_id"`
+	Parts    []CompletedPart `json:"parts"`
+	Size     int64           `json:"size"`
+	Checksum string          `json:"checksum"`
+	Name     string          `json:"name"`
+	MimeType string          `json:"mime_type"`
+}
+
+// BackupStreamActivity pipes the provider output through compression and encryption straight
+// into a multipart upload, so the backup is never written to disk. The size of the backup is
+// not known up front, so part URLs are requested from the API in batches as the upload goes.
+// A retry starts the backup again on a new multipart upload.
+func (a *Activities) BackupStreamActivity(ctx context.Context, input BackupStreamActivityInput) (*BackupStreamActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("BackupStreamActivity started", "jobId", input.JobId, "provider", input.Job.Provider)
+
+	source, ok := streamSources[input.Job.Provider]
+	if !ok {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("provider %s does not support streaming", input.Job.Provider), "StreamingUnsupported", nil)
+	}
+
+	sourceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	uploader := a.newStreamUploader(ctx, input.JobId, input.BackupId)
+	uploadDone := make(chan error, 1)
+	go func() {
+		err := uploader.upload(pr)
+		if err != nil {
+			// Stop the provider, its output has nowhere to go
+			pr.CloseWithError(err)
+			cancel()
+		}
+		uploadDone <- err
+	}()
+
+	// The chain is built from the upload backwards: compression → encryption → hash → upload
+	hash := sha256.New()
+	size := &countingWriter{}
+	var w io.Writer = io.MultiWriter(pw, hash, size)
+	var closers []io.Closer
+	var suffix, mimeType string
+
+	if input.Job.Encryption.Enabled {
+		enc, keys, err := lookupEncryptor(input.Job.Encryption.Algorithm,
+			append([]string{input.Job.Encryption.PublicKey}, input.Job.Encryption.Recipients...))
+		if err != nil {
+			pw.CloseWithError(err)
+			<-uploadDone
+			return nil, err
+		}
+		ew, err := enc.NewWriter(w, keys)
+		if err != nil {
+			pw.CloseWithError(err)
+			<-uploadDone
+			return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidEncryptionKey", err)
+		}
+		w = ew
+		closers = append([]io.Closer{ew}, closers...)
+		suffix, mimeType = enc.Suffix, enc.MimeType
+	}
+
+	if input.Job.Compression.Enabled {
+		comp, err := lookupCompressor(input.Job.Compression.Algorithm)
+		if err == nil {
+			err = comp.checkLevel(input.Job.Compression.Algorithm, input.Job.Compression.Level)
+		}
+		if err != nil {
+			pw.CloseWithError(err)
+			<-uploadDone
+			return nil, err
+		}
+		zw, err := comp.NewWriter(w, input.Job.Compression.Level)
+		if err != nil {
+			pw.CloseWithError(err)
+			<-uploadDone
+			return nil, fmt.Errorf("failed to create compression writer: %w", err)
+		}
+		w = zw
+		closers = append([]io.Closer{zw}, closers...)
+		suffix = comp.Suffix + suffix
+		if mimeType == "" {
+			mimeType = comp.MimeType
+		}
+	}
+
+	// Progress counts the provider output, so the activity heartbeats while a part fills up. While
+	// every part slot is busy uploading the provider blocks on the pipe and writes nothing, the
+	// keepalive heartbeats meanwhile.
+	progress := a.newProgressReporter(ctx, 0)
+	stop := progress.Keepalive()
+	defer stop()
+	name, sourceMimeType, err := source(a, sourceCtx, input.Job, io.MultiWriter(w, progress))
+	for _, c := range closers {
+		if err != nil {
+			break
+		}
+		err = c.Close()
+	}
+	pw.CloseWithError(err)
+	uploadErr := <-uploadDone
+
+	if err != nil {
+		// When the upload failed first the provider only saw its output closed
+		if uploadErr != nil && !errors.Is(uploadErr, err) {
+			return nil, uploadErr
+		}
+		return nil, fmt.Errorf("failed to stream backup: %w", err)
+	}
+	if uploadErr != nil {
+		return nil, uploadErr
+	}
+	progress.Done()
+
+	if mimeType == "" {
+		mimeType = sourceMimeType
+	}
+
+	logger.Info("BackupStreamActivity completed", "uploadId", uploader.uploadId, "size", size.n,
+		"parts", len(uploader.parts))
+
+	return &BackupStreamActivityOutput{
+		UploadId: uploader.uploadId,
+		Parts:    uploader.sortedParts(),
+		Size:     size.n,
+		Checksum: fmt.Sprintf("%x", hash.Sum(nil)),
+		Name:     name + suffix,
+		MimeType: mimeType,
+	}, nil
+}
+
+// streamUploader uploads a stream of unknown length as a multipart upload, requesting the
+// part URLs from the API as it needs them
+type streamUploader struct {
+	a        *Activities
+	ctx      context.Context
+	jobId    string
+	backupId string
+	client   *http.Client
+
+	mu       sync.Mutex
+	uploadId string
+	urls     map[int]string
+	parts    []CompletedPart
+}
+
+func (a *Activities) newStreamUploader(ctx context.Context, jobId, backupId string) *streamUploader {
+	return &streamUploader{
+		a:        a,
+		ctx:      ctx,
+		jobId:    jobId,
+		backupId: backupId,
+		client:   &http.Client{Timeout: 30 * time.Minute},
+		urls:     make(map[int]string),
+	}
+}
+
+// upload reads r in parts of streamPartSize and uploads them in parallel. An empty stream is
+// uploaded as a single empty part, since a multipart upload cannot be completed without parts.
+func (u *streamUploader) upload(r io.Reader) error {
+	concurrency := defaultUploadConcurrency
+	if u.a.Config != nil && u.a.Config.UploadConcurrency > 0 {
+		concurrency = u.a.Config.UploadConcurrency
+	}
+
+	g, gctx := errgroup.WithContext(u.ctx)
+	g.SetLimit(concurrency)
+
+	for partNumber := 1; ; partNumber++ {
+		buf := make([]byte, streamPartSize)
+		n, readErr := io.ReadFull(r, buf)
+		if readErr == io.EOF && partNumber > 1 {
+			break
+		}
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			g.Wait()
+			return readErr
+		}
+		if gctx.Err() != nil {
+			return g.Wait()
+		}
+		if partNumber > streamMaxParts {
+			g.Wait()
+			return temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("backup exceeds %d parts of %d bytes", streamMaxParts, streamPartSize), "StreamTooLarge", nil)
+		}
+
+		url, err := u.partURL(partNumber, false)
+		if err != nil {
+			g.Wait()
+			return err
+		}
+
+		part := UploadPart{PartNumber: partNumber, URL: url}
+		data := buf[:n]
+		g.Go(func() error { return u.uploadPart(gctx, part, data) })
+
+		// A short read is the end of the stream
+		if readErr != nil {
+			break
+		}
+	}
+
+	return g.Wait()
+}
+
+// uploadPart uploads one buffered part, replacing its URL when S3 rejects it
+func (u *streamUploader) uploadPart(ctx context.Context, part UploadPart, data []byte) error {
+	for refresh := 0; ; refresh++ {
+		etag, err := uploadPart(ctx, u.client, bytes.NewReader(data), part, 0, int64(len(data)), nil)
+		if err == nil {
+			u.mu.Lock()
+			u.parts = append(u.parts, CompletedPart{PartNumber: part.PartNumber, ETag: etag})
+			u.mu.Unlock()
+			return nil
+		}
+
+		var appErr *temporal.ApplicationError
+		if !errors.As(err, &appErr) || appErr.Type() != ErrorTypeUploadURLExpired || refresh >= streamURLRefreshes {
+			return err
+		}
+		activity.GetLogger(ctx).Warn("Upload URL rejected, requesting a new one", "part", part.PartNumber)
+		if part.URL, err = u.partURL(part.PartNumber, true); err != nil {
+			return err
+		}
+	}
+}
+
+// partURL returns the presigned URL of a part, requesting the next batch of URLs from the API
+// when it is not known yet, or only this part's URL when refresh is set
+func (u *streamUploader) partURL(partNumber int, refresh bool) (string, error) {
+	u.mu.Lock()
+	url, ok := u.urls[partNumber]
+	uploadId := u.uploadId
+	u.mu.Unlock()
+	if ok && !refresh {
+		return url, nil
+	}
+
+	count := streamURLBatch
+	if refresh {
+		count = 1
+	}
+	out, err := u.a.BackupUploadActivity(u.ctx, BackupUploadActivityInput{
+		JobId: u.jobId, BackupId: u.backupId,
+		Multipart: true, PartSize: streamPartSize, PartCount: count,
+		UploadId: uploadId, FirstPart: partNumber,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to request upload URLs: %w", err)
+	}
+	if out.UploadId == "" {
+		return "", temporal.NewNonRetryableApplicationError(
+			"the API did not start a multipart upload for the streamed backup", "StreamingUnsupported", nil)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.uploadId = out.UploadId
+	for _, p := range out.Parts {
+		u.urls[p.PartNumber] = p.URL
+	}
+	url, ok = u.urls[partNumber]
+	if !ok {
+		return "", fmt.Errorf("the API returned no upload URL for part %d", partNumber)
+	}
+	return url, nil
+}
+
+// sortedParts returns the uploaded parts ordered by part number
+func (u *streamUploader) sortedParts() []CompletedPart {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	parts := append([]CompletedPart(nil), u.parts...)
+	sortParts(parts)
+	return parts
+}
@@ -0,0 +1,157 @@
+package activities
+
+import (
+	"agent/internal/config"
+	"agent/internal/job"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+)
+
+type staticToken struct{}
+
+func (staticToken) Token() (string, error) { return "token", nil }
+
+// streamServer plays both the API handing out part URLs and S3 receiving the parts
+type streamServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []map[string]any
+	parts    map[int][]byte
+}
+
+func newStreamServer(t *testing.T) *streamServer {
+	s := &streamServer{parts: map[int][]byte{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if strings.HasSuffix(r.URL.Path, "/upload") {
+			var req map[string]any
+			require.NoError(t, json.Unmarshal(body, &req))
+			s.requests = append(s.requests, req)
+
+			first, count := int(req["first_part"].(float64)), int(req["part_count"].(float64))
+			out := BackupUploadActivityOutput{UploadId: "upload-1"}
+			for n := first; n < first+count; n++ {
+				out.Parts = append(out.Parts, UploadPart{PartNumber: n, URL: fmt.Sprintf("%s/part/%d", s.URL, n)})
+			}
+			json.NewEncoder(w).Encode(out)
+			return
+		}
+
+		n, _ := strconv.Atoi(filepath.Base(r.URL.Path))
+		s.parts[n] = body
+		w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", n))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *streamServer) uploaded() []byte {
+	var out []byte
+	for n := 1; n <= len(s.parts); n++ {
+		out = append(out, s.parts[n]...)
+	}
+	return out
+}
+
+// withStreamSource replaces the streaming source of the HTTP provider for the test
+func withStreamSource(t *testing.T, data []byte) {
+	orig := streamSources[job.JobProviderHTTP]
+	streamSources[job.JobProviderHTTP] = func(a *Activities, ctx context.Context, j *job.Job, w io.Writer) (string, string, error) {
+		_, err := w.Write(data)
+		return "backup.sql", "application/sql", err
+	}
+	t.Cleanup(func() { streamSources[job.JobProviderHTTP] = orig })
+}
+
+func newStreamActivities(srv *streamServer) *Activities {
+	return &Activities{
+		Config:     &config.Config{API: srv.URL, UploadConcurrency: 2},
+		Auth:       staticToken{},
+		Hub:        &config.HubConfig{Workspace: "ws"},
+		HTTPClient: srv.Client(),
+	}
+}
+
+func TestBackupStreamActivity_Parts(t *testing.T) {
+	srv := newStreamServer(t)
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), streamPartSize/16+1)
+	withStreamSource(t, data)
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	acts := newStreamActivities(srv)
+	env.RegisterActivity(acts.BackupStreamActivity)
+
+	val, err := env.ExecuteActivity(acts.BackupStreamActivity, BackupStreamActivityInput{
+		Job: &job.Job{ID: "job-1", Provider: job.JobProviderHTTP}, JobId: "job-1", BackupId: "backup-1",
+	})
+	require.NoError(t, err)
+
+	var res BackupStreamActivityOutput
+	require.NoError(t, val.Get(&res))
+
+	assert.Equal(t, "upload-1", res.UploadId)
+	assert.Equal(t, []CompletedPart{{PartNumber: 1, ETag: "\"etag-1\""}, {PartNumber: 2, ETag: "\"etag-2\""}}, res.Parts)
+	assert.Equal(t, int64(len(data)), res.Size)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(data)), res.Checksum)
+	assert.Equal(t, "backup.sql", res.Name)
+	assert.Equal(t, "application/sql", res.MimeType)
+
+	assert.Len(t, srv.parts[1], streamPartSize)
+	assert.Equal(t, data, srv.uploaded())
+	// Both parts come from the first batch of URLs
+	require.Len(t, srv.requests, 1)
+	assert.Equal(t, float64(1), srv.requests[0]["first_part"])
+}
+
+func TestBackupStreamActivity_Compressed(t *testing.T) {
+	srv := newStreamServer(t)
+
+	data := []byte(strings.Repeat("INSERT INTO t VALUES (1);\n", 1000))
+	withStreamSource(t, data)
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	acts := newStreamActivities(srv)
+	env.RegisterActivity(acts.BackupStreamActivity)
+
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderHTTP}
+	j.Compression.Enabled = true
+	val, err := env.ExecuteActivity(acts.BackupStreamActivity, BackupStreamActivityInput{Job: j, JobId: "job-1", BackupId: "backup-1"})
+	require.NoError(t, err)
+
+	var res BackupStreamActivityOutput
+	require.NoError(t, val.Get(&res))
+	assert.Equal(t, "backup.sql.gz", res.Name)
+	assert.Equal(t, "application/gzip", res.MimeType)
+
+	uploaded := srv.uploaded()
+	assert.Equal(t, int64(len(uploaded)), res.Size)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(uploaded)), res.Checksum)
+
+	zr, err := gzip.NewReader(bytes.NewReader(uploaded))
+	require.NoError(t, err)
+	plain, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, data, plain)
+}
@@ -19,11 +19,31 @@ type BackupUploadActivityInput struct {
 	Checksum string `json:"checksum"`
 	Name     string `json:"name"`
 	MimeType string `json:"mime_type"`
+	// Multipart asks the API for a multipart upload of PartCount parts of PartSize bytes
+	Multipart bool  `json:"multipart,omitempty"`
+	PartSize  int64 `json:"part_size,omitempty"`
+	PartCount int   `json:"part_count,omitempty"`
+	// UploadId asks for fresh part URLs of an existing multipart upload after the previous ones expired
+	UploadId string `json:"upload_id,omitempty"`
+	// FirstPart asks for the URLs of PartCount parts starting at this part number, for streamed
+	// uploads whose size is not known up front
+	FirstPart int `json:"first_part,omitempty"`
 }
 
 type BackupUploadActivityOutput struct {
 	UploadURL string    `json:"upload_url"`
 	ExpiresAt time.Time `json:"expires_at"`
+	// UploadId and Parts are only set when a multipart upload was requested and the API
+	// supports it; otherwise UploadURL is used for a single PUT
+	UploadId string       `json:"upload_id,omitempty"`
+	PartSize int64        `json:"part_size,omitempty"`
+	Parts    []UploadPart `json:"parts,omitempty"`
+}
+
+// UploadPart is a presigned URL for one part of a multipart upload
+type UploadPart struct {
+	PartNumber int    `json:"part_number"`
+	URL        string `json:"url"`
 }
 
 func (a *Activities) BackupUploadActivity(ctx context.Context, input BackupUploadActivityInput) (*BackupUploadActivityOutput, error) {
@@ -50,6 +70,17 @@ func (a *Activities) BackupUploadActivity(ctx context.Context, input BackupUploa
 		"name":      input.Name,
 		"mime_type": input.MimeType,
 	}
+	if input.Multipart {
+		fileMeta["multipart"] = true
+		fileMeta["part_size"] = input.PartSize
+		fileMeta["part_count"] = input.PartCount
+		if input.UploadId != "" {
+			fileMeta["upload_id"] = input.UploadId
+		}
+		if input.FirstPart > 0 {
+			fileMeta["first_part"] = input.FirstPart
+		}
+	}
 
 	bodyBytes, err := json.Marshal(fileMeta)
 	if err != nil {
@@ -89,6 +120,6 @@ func (a *Activities) BackupUploadActivity(ctx context.Context, input BackupUploa
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	logger.Info("Upload URL received", "expiresAt", result.ExpiresAt)
+	logger.Info("Upload URL received", "expiresAt", result.ExpiresAt, "uploadId", result.UploadId, "parts", len(result.Parts))
 	return &result, nil
 }
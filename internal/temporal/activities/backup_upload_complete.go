@@ -0,0 +1,95 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.temporal.io/sdk/activity"
+)
+
+type BackupUploadCompleteActivityInput struct {
+	JobId    string          `json:"job_id"`
+	BackupId string          `json:"backup_id"`
+	UploadId string          `json:"upload_id"`
+	Parts    []CompletedPart `json:"parts"`
+	// Size, Checksum, Name and MimeType describe streamed backups, which are only known
+	// once the upload has finished
+	Size     int64  `json:"size,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+}
+
+type BackupUploadCompleteActivityOutput struct {
+	Status bool `json:"status"`
+}
+
+// BackupUploadCompleteActivity asks the API to complete a multipart upload from the ETags of its parts
+func (a *Activities) BackupUploadCompleteActivity(ctx context.Context, input BackupUploadCompleteActivityInput) (*BackupUploadCompleteActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Debug("BackupUploadCompleteActivity called", "jobId", input.JobId, "backupId", input.BackupId, "uploadId", input.UploadId)
+
+	// Get auth token
+	token, err := a.Auth.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth token: %w", err)
+	}
+
+	// Build API URL
+	url := fmt.Sprintf("%s/v1/workspaces/%s/jobs/%s/backups/%s/upload/complete",
+		a.Config.API,
+		a.Hub.Workspace,
+		input.JobId,
+		input.BackupId)
+
+	// Create request body
+	reqBody := map[string]interface{}{
+		"upload_id": input.UploadId,
+		"parts":     input.Parts,
+	}
+	if input.Checksum != "" {
+		reqBody["size"] = input.Size
+		reqBody["checksum"] = input.Checksum
+		reqBody["name"] = input.Name
+		reqBody["mime_type"] = input.MimeType
+	}
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Execute request
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	logger.Info("Multipart upload completed", "uploadId", input.UploadId, "parts", len(input.Parts))
+	return &BackupUploadCompleteActivityOutput{Status: true}, nil
+}
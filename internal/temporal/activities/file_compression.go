@@ -1,14 +1,33 @@
 package activities
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	CompressionAlgorithmGzip = "gzip"
+	CompressionAlgorithmZstd = "zstd"
+	CompressionAlgorithmXZ   = "xz"
+	CompressionAlgorithmLZ4  = "lz4"
 )
 
 type FileCompressionActivityInput struct {
 	FilePath string `json:"file_path"`
+	Name     string `json:"name"`
 	Provider string `json:"provider"`
+	Level    int    `json:"level"`
 }
 
 type FileCompressionActivityOutput struct {
@@ -19,11 +38,194 @@ type FileCompressionActivityOutput struct {
 	MimeType string `json:"mime_type"`
 }
 
+// compressor describes how a compression algorithm names, encodes and decodes its output
+type compressor struct {
+	Suffix   string
+	MimeType string
+	// MaxLevel is the highest level NewWriter takes; levels start at 1, 0 is the default
+	MaxLevel  int
+	NewWriter func(w io.Writer, level int) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var compressors = map[string]compressor{
+	CompressionAlgorithmGzip: {
+		Suffix:   ".gz",
+		MimeType: "application/gzip",
+		MaxLevel: 9,
+		NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = gzip.DefaultCompression
+			}
+			return gzip.NewWriterLevel(w, level)
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	CompressionAlgorithmZstd: {
+		Suffix:   ".zst",
+		MimeType: "application/zstd",
+		MaxLevel: 22,
+		NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			encLevel := zstd.SpeedDefault
+			if level > 0 {
+				encLevel = zstd.EncoderLevelFromZstd(level)
+			}
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(encLevel))
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zr.IOReadCloser(), nil
+		},
+	},
+	CompressionAlgorithmXZ: {
+		Suffix:   ".xz",
+		MimeType: "application/x-xz",
+		MaxLevel: 9,
+		NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			cfg := xz.WriterConfig{}
+			// Mirror the xz(1) presets, where the level mostly selects the dictionary size
+			if level > 0 {
+				cfg.DictCap = xzDictCap(level)
+			}
+			return cfg.NewWriter(w)
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := xz.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(zr), nil
+		},
+	},
+	CompressionAlgorithmLZ4: {
+		Suffix:   ".lz4",
+		MimeType: "application/x-lz4",
+		MaxLevel: 9,
+		NewWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			zw := lz4.NewWriter(w)
+			if level > 0 {
+				if err := zw.Apply(lz4.CompressionLevelOption(lz4Level(level))); err != nil {
+					return nil, err
+				}
+			}
+			return zw, nil
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(lz4.NewReader(r)), nil
+		},
+	},
+}
+
+// xzDictCap maps an xz preset level (1-9) to its dictionary size
+func xzDictCap(level int) int {
+	level = min(max(level, 1), 9)
+	caps := []int{1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+	return caps[level-1]
+}
+
+// lz4Level maps a numeric level (1-9) to the lz4 high compression levels
+func lz4Level(level int) lz4.CompressionLevel {
+	level = min(max(level, 1), 9)
+	return lz4.Level1 << (level - 1)
+}
+
+// lookupCompressor returns the compressor for algorithm, defaulting to gzip
+func lookupCompressor(algorithm string) (compressor, error) {
+	if algorithm == "" {
+		algorithm = CompressionAlgorithmGzip
+	}
+	comp, ok := compressors[algorithm]
+	if !ok {
+		return compressor{}, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("unsupported compression algorithm: %s", algorithm), "UnsupportedCompression", nil)
+	}
+	return comp, nil
+}
+
+// checkLevel rejects levels outside the range of the algorithm before any file is written
+func (c compressor) checkLevel(algorithm string, level int) error {
+	if level < 0 || level > c.MaxLevel {
+		if algorithm == "" {
+			algorithm = CompressionAlgorithmGzip
+		}
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("invalid %s compression level %d, must be between 1 and %d", algorithm, level, c.MaxLevel),
+			"InvalidConfig", nil)
+	}
+	return nil
+}
+
 func (a *Activities) FileCompressionActivity(ctx context.Context, input FileCompressionActivityInput) (*FileCompressionActivityOutput, error) {
 	logger := activity.GetLogger(ctx)
-	logger.Debug("FileCompressionActivity called")
+	logger.Info("FileCompressionActivity started", "filePath", input.FilePath, "algorithm", input.Provider, "level", input.Level)
+
+	comp, err := lookupCompressor(input.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if err := comp.checkLevel(input.Provider, input.Level); err != nil {
+		return nil, err
+	}
+
+	src, err := os.Open(input.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer src.Close()
+
+	var total int64
+	if fi, err := src.Stat(); err == nil {
+		total = fi.Size()
+	}
+	progress := a.newProgressReporter(ctx, total)
+
+	outPath := input.FilePath + comp.Suffix
+	dst, err := os.Create(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compressed file: %w", err)
+	}
+	defer dst.Close()
+
+	hash := sha256.New()
+	zw, err := comp.NewWriter(io.MultiWriter(dst, hash), input.Level)
+	if err != nil {
+		os.Remove(outPath)
+		return nil, fmt.Errorf("failed to create compression writer: %w", err)
+	}
+
+	if _, err := io.Copy(zw, &contextReader{ctx: ctx, r: io.TeeReader(src, progress)}); err != nil {
+		zw.Close()
+		os.Remove(outPath)
+		return nil, fmt.Errorf("failed to compress file: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		os.Remove(outPath)
+		return nil, fmt.Errorf("failed to finish compression: %w", err)
+	}
+	progress.Done()
+
+	fi, err := dst.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat compressed file: %w", err)
+	}
+
+	name := input.Name
+	if name == "" {
+		name = filepath.Base(input.FilePath)
+	}
 
-	result := new(FileCompressionActivityOutput)
+	logger.Info("FileCompressionActivity completed", "filePath", outPath, "size", fi.Size())
 
-	return result, nil
+	return &FileCompressionActivityOutput{
+		FilePath: outPath,
+		Size:     fi.Size(),
+		Checksum: fmt.Sprintf("%x", hash.Sum(nil)),
+		Name:     name + comp.Suffix,
+		MimeType: comp.MimeType,
+	}, nil
 }
@@ -0,0 +1,104 @@
+package activities
+
+import (
+	"agent/internal/config"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestFileCompressionActivity(t *testing.T) {
+	payload := bytes.Repeat([]byte("saved backup payload\n"), 4096)
+
+	readers := map[string]func(r io.Reader) (io.Reader, error){
+		CompressionAlgorithmGzip: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		CompressionAlgorithmZstd: func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		CompressionAlgorithmXZ:   func(r io.Reader) (io.Reader, error) { return xz.NewReader(r) },
+		CompressionAlgorithmLZ4:  func(r io.Reader) (io.Reader, error) { return lz4.NewReader(r), nil },
+	}
+
+	for algorithm, newReader := range readers {
+		for _, level := range []int{0, 6} {
+			t.Run(algorithm, func(t *testing.T) {
+				testSuite := &testsuite.WorkflowTestSuite{}
+				env := testSuite.NewTestActivityEnvironment()
+
+				acts := &Activities{Config: &config.Config{TempDir: t.TempDir()}}
+				env.RegisterActivity(acts.FileCompressionActivity)
+
+				srcPath := filepath.Join(acts.Config.TempDir, "dump.sql")
+				require.NoError(t, os.WriteFile(srcPath, payload, 0o644))
+
+				val, err := env.ExecuteActivity(acts.FileCompressionActivity, FileCompressionActivityInput{
+					FilePath: srcPath, Name: "dump.sql", Provider: algorithm, Level: level,
+				})
+				require.NoError(t, err)
+
+				var res FileCompressionActivityOutput
+				require.NoError(t, val.Get(&res))
+
+				suffix := compressors[algorithm].Suffix
+				assert.Equal(t, srcPath+suffix, res.FilePath)
+				assert.Equal(t, "dump.sql"+suffix, res.Name)
+				assert.Equal(t, compressors[algorithm].MimeType, res.MimeType)
+				assert.Less(t, res.Size, int64(len(payload)))
+				assert.Len(t, res.Checksum, 64)
+
+				f, err := os.Open(res.FilePath)
+				require.NoError(t, err)
+				defer f.Close()
+
+				r, err := newReader(f)
+				require.NoError(t, err)
+				out, err := io.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, payload, out)
+			})
+		}
+	}
+}
+
+func TestFileCompressionActivity_UnknownAlgorithm(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+
+	acts := &Activities{Config: &config.Config{TempDir: t.TempDir()}}
+	env.RegisterActivity(acts.FileCompressionActivity)
+
+	_, err := env.ExecuteActivity(acts.FileCompressionActivity, FileCompressionActivityInput{
+		FilePath: filepath.Join(acts.Config.TempDir, "dump.sql"), Provider: "brotli",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported compression algorithm")
+
+	// Levels out of range are rejected before the source file, which does not exist, is opened
+	for _, tt := range []struct {
+		algorithm string
+		level     int
+	}{
+		{CompressionAlgorithmGzip, 15},
+		{"", 10},
+		{CompressionAlgorithmZstd, 23},
+		{CompressionAlgorithmXZ, -1},
+	} {
+		_, err := env.ExecuteActivity(acts.FileCompressionActivity, FileCompressionActivityInput{
+			FilePath: filepath.Join(acts.Config.TempDir, "dump.sql"), Provider: tt.algorithm, Level: tt.level,
+		})
+		var appErr *temporal.ApplicationError
+		require.ErrorAs(t, err, &appErr, "%s level %d", tt.algorithm, tt.level)
+		assert.Equal(t, "InvalidConfig", appErr.Type())
+		assert.True(t, appErr.NonRetryable())
+		assert.Contains(t, err.Error(), "compression level")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"agent/internal/job"
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
@@ -11,6 +12,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"go.temporal.io/sdk/activity"
 )
@@ -25,6 +27,9 @@ type DownloadActivityOutput struct {
 	Name     string `json:"name"`
 	MimeType string `json:"mime_type"`
 	FilePath string `json:"file_path"`
+	// State is what the next backup of an incremental job continues from, saved with
+	// BackupStateSaveActivity once this backup is confirmed
+	State json.RawMessage `json:"state,omitempty"`
 }
 
 func (a *Activities) DownloadActivity(ctx context.Context, input DownloadActivityInput) (*DownloadActivityOutput, error) {
@@ -39,13 +44,9 @@ func (a *Activities) DownloadActivity(ctx context.Context, input DownloadActivit
 		return nil, fmt.Errorf("invalid HTTP config: %w", err)
 	}
 
-	parsedURL, err := url.Parse(httpConfig.Endpoint)
+	filename, err := httpFileName(httpConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse endpoint URL: %w", err)
-	}
-	filename := path.Base(parsedURL.Path)
-	if filename == "" || filename == "." {
-		filename = "download"
+		return nil, err
 	}
 
 	if err := os.MkdirAll(a.Config.TempDir, 0o755); err != nil {
@@ -53,31 +54,8 @@ func (a *Activities) DownloadActivity(ctx context.Context, input DownloadActivit
 	}
 	tempFile := filepath.Join(a.Config.TempDir, fmt.Sprintf("%s-%s", input.Job.ID, filename))
 
-	method := httpConfig.Method
-	if method == "" {
-		method = "GET"
-	}
-
-	args := []string{"-s", "-S", "-L", "--fail", "-o", tempFile}
-
-	for k, vals := range httpConfig.Header {
-		for _, v := range vals {
-			args = append(args, "-H", fmt.Sprintf("%s: %s", k, v))
-		}
-	}
-
-	if method != "GET" {
-		args = append(args, "-X", method)
-	}
-
-	if httpConfig.Body != "" {
-		args = append(args, "-d", httpConfig.Body)
-	}
-
-	args = append(args, httpConfig.Endpoint)
-
-	cmd := exec.CommandContext(ctx, a.Config.Path.CURL, args...)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	cmd := exec.CommandContext(ctx, a.Config.Path.CURL, curlArgs(httpConfig, "-o", tempFile)...)
+	if output, err := a.runWithProgress(ctx, cmd, tempFile); err != nil {
 		return nil, fmt.Errorf("curl failed: %w, output: %s", err, string(output))
 	}
 
@@ -107,3 +85,67 @@ func (a *Activities) DownloadActivity(ctx context.Context, input DownloadActivit
 		MimeType: "application/octet-stream",
 	}, nil
 }
+
+// httpFileName returns the name of the backup from the last element of the endpoint path
+func httpFileName(cfg *job.HTTPConfig) (string, error) {
+	parsedURL, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse endpoint URL: %w", err)
+	}
+	filename := path.Base(parsedURL.Path)
+	if filename == "" || filename == "." {
+		filename = "download"
+	}
+	return filename, nil
+}
+
+// curlArgs returns the curl arguments for the request described by cfg
+func curlArgs(cfg *job.HTTPConfig, extra ...string) []string {
+	method := cfg.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	args := append([]string{"-s", "-S", "-L", "--fail"}, extra...)
+
+	for k, vals := range cfg.Header {
+		for _, v := range vals {
+			args = append(args, "-H", fmt.Sprintf("%s: %s", k, v))
+		}
+	}
+
+	if method != "GET" {
+		args = append(args, "-X", method)
+	}
+
+	if cfg.Body != "" {
+		args = append(args, "-d", cfg.Body)
+	}
+
+	return append(args, cfg.Endpoint)
+}
+
+// streamHTTP is the streaming source of HTTP jobs; curl writes the response to stdout
+func (a *Activities) streamHTTP(ctx context.Context, j *job.Job, w io.Writer) (string, string, error) {
+	cfg, err := job.LoadAs[*job.HTTPConfig](*j)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load HTTP config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return "", "", fmt.Errorf("invalid HTTP config: %w", err)
+	}
+	filename, err := httpFileName(cfg)
+	if err != nil {
+		return "", "", err
+	}
+
+	cmd := exec.CommandContext(ctx, a.Config.Path.CURL, curlArgs(cfg)...)
+	cmd.Stdout = w
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("curl failed: %w, output: %s", err, stderr.String())
+	}
+	return filename, "application/octet-stream", nil
+}
@@ -2,14 +2,31 @@ package activities
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	EncryptionAlgorithmAge     = "age"
+	EncryptionAlgorithmOpenPGP = "openpgp"
 )
 
 type FileEncryptionActivityInput struct {
-	FilePath string `json:"file_path"`
-	Provider string `json:"provider"`
-	Key      string `json:"key"`
+	FilePath string   `json:"file_path"`
+	Name     string   `json:"name"`
+	Provider string   `json:"provider"`
+	Key      string   `json:"key"`
+	Keys     []string `json:"keys,omitempty"`
 }
 
 type FileEncryptionActivityOutput struct {
@@ -20,11 +37,194 @@ type FileEncryptionActivityOutput struct {
 	MimeType string `json:"mime_type"`
 }
 
+// encryptor describes how an encryption algorithm names, encrypts and decrypts its output.
+// NewReader takes the contents of the private key files and the passphrase that unlocks them.
+type encryptor struct {
+	Suffix    string
+	MimeType  string
+	NewWriter func(w io.Writer, keys []string) (io.WriteCloser, error)
+	NewReader func(r io.Reader, keys []string, passphrase string) (io.Reader, error)
+}
+
+var encryptors = map[string]encryptor{
+	EncryptionAlgorithmAge: {
+		Suffix:    ".age",
+		MimeType:  "application/octet-stream",
+		NewWriter: newAgeWriter,
+		NewReader: newAgeReader,
+	},
+	EncryptionAlgorithmOpenPGP: {
+		Suffix:    ".gpg",
+		MimeType:  "application/pgp-encrypted",
+		NewWriter: newOpenPGPWriter,
+		NewReader: newOpenPGPReader,
+	},
+}
+
+// newAgeWriter encrypts to every X25519 recipient found in keys. Each key may hold
+// several recipients, one per line, in the same format as an age recipients file.
+func newAgeWriter(w io.Writer, keys []string) (io.WriteCloser, error) {
+	var recipients []age.Recipient
+	for _, key := range keys {
+		parsed, err := age.ParseRecipients(strings.NewReader(key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse age recipients: %w", err)
+		}
+		recipients = append(recipients, parsed...)
+	}
+	return age.Encrypt(w, recipients...)
+}
+
+// newOpenPGPWriter encrypts to every entity found in the armored public keys
+func newOpenPGPWriter(w io.Writer, keys []string) (io.WriteCloser, error) {
+	var entities openpgp.EntityList
+	for _, key := range keys {
+		parsed, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse OpenPGP public key: %w", err)
+		}
+		entities = append(entities, parsed...)
+	}
+	return openpgp.Encrypt(w, entities, nil, &openpgp.FileHints{IsBinary: true}, nil)
+}
+
+// newAgeReader decrypts with the X25519 identities found in keys. Keys that hold no age
+// identity, such as OpenPGP keys configured for other jobs, are skipped.
+func newAgeReader(r io.Reader, keys []string, _ string) (io.Reader, error) {
+	var identities []age.Identity
+	for _, key := range keys {
+		parsed, err := age.ParseIdentities(strings.NewReader(key))
+		if err != nil {
+			continue
+		}
+		identities = append(identities, parsed...)
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("no age identity found in the restore key files")
+	}
+	return age.Decrypt(r, identities...)
+}
+
+// newOpenPGPReader decrypts with the private keys found in keys, unlocking them with passphrase
+// when they are encrypted. Keys that are not armored OpenPGP keys are skipped.
+func newOpenPGPReader(r io.Reader, keys []string, passphrase string) (io.Reader, error) {
+	var entities openpgp.EntityList
+	for _, key := range keys {
+		parsed, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+		if err != nil {
+			continue
+		}
+		entities = append(entities, parsed...)
+	}
+	if len(entities) == 0 {
+		return nil, errors.New("no OpenPGP private key found in the restore key files")
+	}
+
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if symmetric || passphrase == "" {
+			return nil, errors.New("the OpenPGP private key is encrypted and no passphrase is configured")
+		}
+		for _, k := range keys {
+			if k.PrivateKey != nil && k.PrivateKey.Encrypted {
+				if err := k.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+					return nil, fmt.Errorf("failed to unlock OpenPGP private key: %w", err)
+				}
+			}
+		}
+		return nil, nil
+	}
+	md, err := openpgp.ReadMessage(r, entities, prompt, nil)
+	if err != nil {
+		return nil, err
+	}
+	return md.UnverifiedBody, nil
+}
+
+// lookupEncryptor returns the encryptor for algorithm and the non-empty keys to encrypt to
+func lookupEncryptor(algorithm string, candidates []string) (encryptor, []string, error) {
+	enc, ok := encryptors[algorithm]
+	if !ok {
+		return encryptor{}, nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("unsupported encryption algorithm: %s", algorithm), "UnsupportedEncryption", nil)
+	}
+
+	var keys []string
+	for _, key := range candidates {
+		if strings.TrimSpace(key) != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return encryptor{}, nil, temporal.NewNonRetryableApplicationError(
+			"encryption is enabled but no public key is configured", "MissingEncryptionKey", nil)
+	}
+	return enc, keys, nil
+}
+
 func (a *Activities) FileEncryptionActivity(ctx context.Context, input FileEncryptionActivityInput) (*FileEncryptionActivityOutput, error) {
 	logger := activity.GetLogger(ctx)
-	logger.Debug("FileEncryptionActivity called")
+	logger.Info("FileEncryptionActivity started", "filePath", input.FilePath, "algorithm", input.Provider)
+
+	enc, keys, err := lookupEncryptor(input.Provider, append([]string{input.Key}, input.Keys...))
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := os.Open(input.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer src.Close()
+
+	var total int64
+	if fi, err := src.Stat(); err == nil {
+		total = fi.Size()
+	}
+	progress := a.newProgressReporter(ctx, total)
+
+	outPath := input.FilePath + enc.Suffix
+	dst, err := os.Create(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encrypted file: %w", err)
+	}
+	defer dst.Close()
+
+	hash := sha256.New()
+	ew, err := enc.NewWriter(io.MultiWriter(dst, hash), keys)
+	if err != nil {
+		os.Remove(outPath)
+		// A key that cannot be parsed will not parse on retry either
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidEncryptionKey", err)
+	}
+
+	if _, err := io.Copy(ew, &contextReader{ctx: ctx, r: io.TeeReader(src, progress)}); err != nil {
+		ew.Close()
+		os.Remove(outPath)
+		return nil, fmt.Errorf("failed to encrypt file: %w", err)
+	}
+	if err := ew.Close(); err != nil {
+		os.Remove(outPath)
+		return nil, fmt.Errorf("failed to finish encryption: %w", err)
+	}
+	progress.Done()
+
+	fi, err := dst.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat encrypted file: %w", err)
+	}
+
+	name := input.Name
+	if name == "" {
+		name = filepath.Base(input.FilePath)
+	}
 
-	result := new(FileEncryptionActivityOutput)
+	logger.Info("FileEncryptionActivity completed", "filePath", outPath, "size", fi.Size(), "recipients", len(keys))
 
-	return result, nil
+	return &FileEncryptionActivityOutput{
+		FilePath: outPath,
+		Size:     fi.Size(),
+		Checksum: fmt.Sprintf("%x", hash.Sum(nil)),
+		Name:     name + enc.Suffix,
+		MimeType: enc.MimeType,
+	}, nil
 }
@@ -0,0 +1,97 @@
+package activities
+
+import (
+	"agent/internal/config"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func runEncryption(t *testing.T, input FileEncryptionActivityInput) (*FileEncryptionActivityOutput, error) {
+	t.Helper()
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+
+	acts := &Activities{Config: &config.Config{TempDir: t.TempDir()}}
+	env.RegisterActivity(acts.FileEncryptionActivity)
+
+	input.FilePath = filepath.Join(acts.Config.TempDir, "dump.sql")
+	require.NoError(t, os.WriteFile(input.FilePath, []byte("secret backup"), 0o644))
+
+	val, err := env.ExecuteActivity(acts.FileEncryptionActivity, input)
+	if err != nil {
+		return nil, err
+	}
+	var res FileEncryptionActivityOutput
+	require.NoError(t, val.Get(&res))
+	return &res, nil
+}
+
+func TestFileEncryptionActivity_Age(t *testing.T) {
+	ops, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	security, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	res, err := runEncryption(t, FileEncryptionActivityInput{
+		Name: "dump.sql", Provider: EncryptionAlgorithmAge,
+		Key: ops.Recipient().String(), Keys: []string{security.Recipient().String()},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "dump.sql.age", res.Name)
+
+	// Both recipients must be able to decrypt
+	for _, identity := range []age.Identity{ops, security} {
+		f, err := os.Open(res.FilePath)
+		require.NoError(t, err)
+		r, err := age.Decrypt(f, identity)
+		require.NoError(t, err)
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "secret backup", string(out))
+		f.Close()
+	}
+}
+
+func TestFileEncryptionActivity_OpenPGP(t *testing.T) {
+	entity, err := openpgp.NewEntity("ops", "", "ops@example.com", nil)
+	require.NoError(t, err)
+
+	var pub bytes.Buffer
+	w, err := armor.Encode(&pub, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	res, err := runEncryption(t, FileEncryptionActivityInput{
+		Name: "dump.sql", Provider: EncryptionAlgorithmOpenPGP, Key: pub.String(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "dump.sql.gpg", res.Name)
+	assert.Equal(t, "application/pgp-encrypted", res.MimeType)
+
+	f, err := os.Open(res.FilePath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	md, err := openpgp.ReadMessage(f, openpgp.EntityList{entity}, nil, nil)
+	require.NoError(t, err)
+	out, err := io.ReadAll(md.UnverifiedBody)
+	require.NoError(t, err)
+	assert.Equal(t, "secret backup", string(out))
+}
+
+func TestFileEncryptionActivity_MissingKey(t *testing.T) {
+	_, err := runEncryption(t, FileEncryptionActivityInput{Provider: EncryptionAlgorithmAge})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no public key")
+}
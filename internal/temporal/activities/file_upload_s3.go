@@ -9,16 +9,29 @@ import (
 	"time"
 
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 )
 
+// ErrorTypeUploadURLExpired is the application error type returned when the presigned upload URLs
+// have expired or were rejected by S3. Retrying the upload cannot succeed; the workflow has to
+// request new URLs with BackupUploadActivity instead.
+const ErrorTypeUploadURLExpired = "UploadURLExpired"
+
 type FileUploadS3ActivityInput struct {
 	FilePath  string    `json:"file_path"`
 	UploadURL string    `json:"upload_url"`
 	ExpiresAt time.Time `json:"expires_at"`
+	// UploadId, PartSize and Parts switch to a multipart upload
+	UploadId string       `json:"upload_id,omitempty"`
+	PartSize int64        `json:"part_size,omitempty"`
+	Parts    []UploadPart `json:"parts,omitempty"`
+	// Completed are parts of the multipart upload that were uploaded with earlier URLs
+	Completed []CompletedPart `json:"completed,omitempty"`
 }
 
 type FileUploadS3ActivityOutput struct {
-	Status bool `json:"status"`
+	Status bool            `json:"status"`
+	Parts  []CompletedPart `json:"parts,omitempty"`
 }
 
 func (a *Activities) FileUploadS3Activity(ctx context.Context, input FileUploadS3ActivityInput) (*FileUploadS3ActivityOutput, error) {
@@ -27,7 +40,7 @@ func (a *Activities) FileUploadS3Activity(ctx context.Context, input FileUploadS
 
 	// Check if upload URL has expired
 	if time.Now().After(input.ExpiresAt) {
-		return nil, fmt.Errorf("upload URL has expired")
+		return nil, uploadURLExpiredError("upload URL has expired", input.Completed)
 	}
 
 	// Open file
@@ -43,10 +56,21 @@ func (a *Activities) FileUploadS3Activity(ctx context.Context, input FileUploadS
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
+	if len(input.Parts) > 0 {
+		logger.Info("Uploading file to S3 in parts", "size", fileInfo.Size(), "parts", len(input.Parts))
+		parts, err := a.uploadMultipart(ctx, file, fileInfo.Size(), input)
+		if err != nil {
+			return nil, err
+		}
+		return &FileUploadS3ActivityOutput{Status: true, Parts: parts}, nil
+	}
+
 	logger.Info("Uploading file to S3", "size", fileInfo.Size())
 
+	progress := a.newProgressReporter(ctx, fileInfo.Size())
+
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "PUT", input.UploadURL, file)
+	req, err := http.NewRequestWithContext(ctx, "PUT", input.UploadURL, io.TeeReader(file, progress))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -65,9 +89,14 @@ func (a *Activities) FileUploadS3Activity(ctx context.Context, input FileUploadS
 	// Check status code
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusForbidden {
+			return nil, uploadURLExpiredError(fmt.Sprintf("upload URL was rejected: %s", string(body)), nil)
+		}
 		return nil, fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
+	progress.Done()
+
 	result := &FileUploadS3ActivityOutput{
 		Status: true,
 	}
@@ -75,3 +104,9 @@ func (a *Activities) FileUploadS3Activity(ctx context.Context, input FileUploadS
 	logger.Info("File uploaded successfully to S3")
 	return result, nil
 }
+
+// uploadURLExpiredError returns a non-retryable ErrorTypeUploadURLExpired error carrying the parts
+// that were already uploaded, so they are not uploaded again with the refreshed URLs
+func uploadURLExpiredError(msg string, completed []CompletedPart) error {
+	return temporal.NewNonRetryableApplicationError(msg, ErrorTypeUploadURLExpired, nil, completed)
+}
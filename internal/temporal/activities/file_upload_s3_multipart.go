@@ -0,0 +1,205 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// partUploadAttempts is how often a single part is tried before the activity fails
+	partUploadAttempts = 3
+	// defaultUploadConcurrency is used when upload_concurrency is not configured
+	defaultUploadConcurrency = 4
+)
+
+// CompletedPart is an uploaded part of a multipart upload
+type CompletedPart struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+// multipartCheckpoint is recorded as heartbeat details so a retried upload skips the parts
+// that were already uploaded by a previous attempt
+type multipartCheckpoint struct {
+	Progress
+	UploadId string          `json:"upload_id"`
+	Parts    []CompletedPart `json:"parts"`
+}
+
+// uploadMultipart uploads the parts of file in parallel to their presigned URLs and returns
+// the completed parts ordered by part number
+func (a *Activities) uploadMultipart(ctx context.Context, file *os.File, size int64, input FileUploadS3ActivityInput) ([]CompletedPart, error) {
+	logger := activity.GetLogger(ctx)
+
+	if input.PartSize <= 0 {
+		return nil, temporal.NewNonRetryableApplicationError("multipart upload has no part size", "InvalidMultipartUpload", nil)
+	}
+
+	// Every part is checked before the first one is uploaded, so an invalid part never leaves
+	// uploads running after the activity returned
+	for _, part := range input.Parts {
+		offset := int64(part.PartNumber-1) * input.PartSize
+		if part.PartNumber < 1 || offset >= size {
+			return nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("part %d starts beyond the end of the file", part.PartNumber), "InvalidMultipartUpload", nil)
+		}
+	}
+
+	var mu sync.Mutex
+	completed := make(map[int]CompletedPart)
+
+	for _, part := range input.Completed {
+		completed[part.PartNumber] = part
+	}
+
+	var checkpoint multipartCheckpoint
+	if activity.HasHeartbeatDetails(ctx) {
+		if err := activity.GetHeartbeatDetails(ctx, &checkpoint); err == nil && checkpoint.UploadId == input.UploadId {
+			for _, part := range checkpoint.Parts {
+				completed[part.PartNumber] = part
+			}
+		}
+	}
+
+	sortedParts := func() []CompletedPart {
+		parts := make([]CompletedPart, 0, len(completed))
+		for _, part := range completed {
+			parts = append(parts, part)
+		}
+		sortParts(parts)
+		return parts
+	}
+
+	progress := a.newProgressReporter(ctx, size)
+	progress.details = func(p Progress) any {
+		mu.Lock()
+		defer mu.Unlock()
+		return multipartCheckpoint{Progress: p, UploadId: input.UploadId, Parts: sortedParts()}
+	}
+
+	concurrency := defaultUploadConcurrency
+	if a.Config != nil && a.Config.UploadConcurrency > 0 {
+		concurrency = a.Config.UploadConcurrency
+	}
+	client := &http.Client{Timeout: 30 * time.Minute}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+
+	for _, part := range input.Parts {
+		offset := int64(part.PartNumber-1) * input.PartSize
+		length := min(input.PartSize, size-offset)
+
+		mu.Lock()
+		_, done := completed[part.PartNumber]
+		mu.Unlock()
+		if done {
+			progress.Add(length)
+			continue
+		}
+
+		g.Go(func() error {
+			etag, err := uploadPart(gctx, client, file, part, offset, length, progress)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			completed[part.PartNumber] = CompletedPart{PartNumber: part.PartNumber, ETag: etag}
+			mu.Unlock()
+			progress.Checkpoint()
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) && appErr.Type() == ErrorTypeUploadURLExpired {
+			mu.Lock()
+			defer mu.Unlock()
+			return nil, uploadURLExpiredError(appErr.Message(), sortedParts())
+		}
+		return nil, err
+	}
+	progress.Done()
+
+	logger.Info("Multipart upload finished", "uploadId", input.UploadId, "parts", len(completed),
+		"resumed", len(checkpoint.Parts))
+	return sortedParts(), nil
+}
+
+// uploadPart PUTs one section of the file, retrying transient failures, and returns its ETag
+func uploadPart(ctx context.Context, client *http.Client, file io.ReaderAt, part UploadPart, offset, length int64, progress *progressReporter) (string, error) {
+	var lastErr error
+	for attempt := 1; attempt <= partUploadAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(time.Duration(1<<(attempt-2)) * time.Second):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+
+		sent := &countingWriter{}
+		body := io.TeeReader(io.NewSectionReader(file, offset, length), io.MultiWriter(progress, sent))
+		req, err := http.NewRequestWithContext(ctx, "PUT", part.URL, body)
+		if err != nil {
+			return "", fmt.Errorf("failed to create request for part %d: %w", part.PartNumber, err)
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.ContentLength = length
+
+		resp, err := client.Do(req)
+		if err != nil {
+			progress.Add(-sent.n)
+			lastErr = fmt.Errorf("upload of part %d failed: %w", part.PartNumber, err)
+			continue
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			etag := resp.Header.Get("ETag")
+			if etag == "" {
+				return "", fmt.Errorf("upload of part %d returned no ETag", part.PartNumber)
+			}
+			return etag, nil
+		}
+
+		progress.Add(-sent.n)
+		if resp.StatusCode == http.StatusForbidden {
+			return "", uploadURLExpiredError(fmt.Sprintf("upload URL of part %d was rejected: %s", part.PartNumber, string(respBody)), nil)
+		}
+		lastErr = fmt.Errorf("upload of part %d failed with status %d: %s", part.PartNumber, resp.StatusCode, string(respBody))
+		// Client errors other than throttling will not succeed on another attempt
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusRequestTimeout {
+			break
+		}
+	}
+	return "", lastErr
+}
+
+// sortParts orders parts by part number, as required to complete a multipart upload
+func sortParts(parts []CompletedPart) {
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	c.n += int64(len(b))
+	return len(b), nil
+}
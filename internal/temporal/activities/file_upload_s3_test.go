@@ -0,0 +1,175 @@
+package activities
+
+import (
+	"agent/internal/config"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// partServer records the body of every part PUT to /part/<n>, failing the first attempt of failPart
+type partServer struct {
+	mu       sync.Mutex
+	bodies   map[int][]byte
+	attempts map[int]int
+	failPart int
+}
+
+func (s *partServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n, _ := strconv.Atoi(filepath.Base(r.URL.Path))
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[n]++
+	if n == s.failPart && s.attempts[n] == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	s.bodies[n] = body
+	w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", n))
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestFileUploadS3Activity_Multipart(t *testing.T) {
+	srv := &partServer{bodies: map[int][]byte{}, attempts: map[int]int{}, failPart: 2}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+
+	acts := &Activities{Config: &config.Config{TempDir: t.TempDir(), UploadConcurrency: 2}}
+	env.RegisterActivity(acts.FileUploadS3Activity)
+
+	// 3 parts of 4 bytes, the last one short
+	filePath := filepath.Join(acts.Config.TempDir, "backup.bin")
+	require.NoError(t, os.WriteFile(filePath, []byte("aaaabbbbcc"), 0o644))
+
+	// Part 1 was uploaded by a previous attempt of the activity
+	env.SetHeartbeatDetails(multipartCheckpoint{
+		UploadId: "upload-1",
+		Parts:    []CompletedPart{{PartNumber: 1, ETag: "\"etag-1\""}},
+	})
+
+	var parts []UploadPart
+	for n := 1; n <= 3; n++ {
+		parts = append(parts, UploadPart{PartNumber: n, URL: fmt.Sprintf("%s/part/%d", ts.URL, n)})
+	}
+
+	val, err := env.ExecuteActivity(acts.FileUploadS3Activity, FileUploadS3ActivityInput{
+		FilePath: filePath, ExpiresAt: time.Now().Add(time.Hour),
+		UploadId: "upload-1", PartSize: 4, Parts: parts,
+	})
+	require.NoError(t, err)
+
+	var res FileUploadS3ActivityOutput
+	require.NoError(t, val.Get(&res))
+
+	assert.Equal(t, []CompletedPart{
+		{PartNumber: 1, ETag: "\"etag-1\""},
+		{PartNumber: 2, ETag: "\"etag-2\""},
+		{PartNumber: 3, ETag: "\"etag-3\""},
+	}, res.Parts)
+
+	assert.NotContains(t, srv.bodies, 1, "part from checkpoint must not be uploaded again")
+	assert.Equal(t, "bbbb", string(srv.bodies[2]))
+	assert.Equal(t, "cc", string(srv.bodies[3]))
+	assert.Equal(t, 2, srv.attempts[2], "failed part is retried")
+}
+
+func TestFileUploadS3Activity_InvalidPart(t *testing.T) {
+	srv := &partServer{bodies: map[int][]byte{}, attempts: map[int]int{}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+
+	acts := &Activities{Config: &config.Config{TempDir: t.TempDir(), UploadConcurrency: 2}}
+	env.RegisterActivity(acts.FileUploadS3Activity)
+
+	filePath := filepath.Join(acts.Config.TempDir, "backup.bin")
+	require.NoError(t, os.WriteFile(filePath, []byte("aaaabbbb"), 0o644))
+
+	// Part 3 starts past the 8 bytes of the file, after a valid part 1
+	_, err := env.ExecuteActivity(acts.FileUploadS3Activity, FileUploadS3ActivityInput{
+		FilePath: filePath, ExpiresAt: time.Now().Add(time.Hour), UploadId: "upload-1", PartSize: 4,
+		Parts: []UploadPart{{PartNumber: 1, URL: ts.URL + "/part/1"}, {PartNumber: 3, URL: ts.URL + "/part/3"}},
+	})
+	require.Error(t, err)
+
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr))
+	assert.Equal(t, "InvalidMultipartUpload", appErr.Type())
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Empty(t, srv.attempts, "no part is uploaded when one is invalid")
+}
+
+func TestFileUploadS3Activity_RejectedURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if filepath.Base(r.URL.Path) == "2" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("ETag", "\"etag\"")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+
+	acts := &Activities{Config: &config.Config{TempDir: t.TempDir(), UploadConcurrency: 1}}
+	env.RegisterActivity(acts.FileUploadS3Activity)
+
+	filePath := filepath.Join(acts.Config.TempDir, "backup.bin")
+	require.NoError(t, os.WriteFile(filePath, []byte("aaaabbbb"), 0o644))
+
+	_, err := env.ExecuteActivity(acts.FileUploadS3Activity, FileUploadS3ActivityInput{
+		FilePath: filePath, ExpiresAt: time.Now().Add(time.Hour), UploadId: "upload-1", PartSize: 4,
+		Parts: []UploadPart{{PartNumber: 1, URL: ts.URL + "/part/1"}, {PartNumber: 2, URL: ts.URL + "/part/2"}},
+	})
+	require.Error(t, err)
+
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr))
+	assert.Equal(t, ErrorTypeUploadURLExpired, appErr.Type())
+
+	var completed []CompletedPart
+	require.NoError(t, appErr.Details(&completed))
+	assert.Equal(t, []CompletedPart{{PartNumber: 1, ETag: "\"etag\""}}, completed)
+}
+
+func TestFileUploadS3Activity_ExpiredURL(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+
+	acts := &Activities{Config: &config.Config{TempDir: t.TempDir()}}
+	env.RegisterActivity(acts.FileUploadS3Activity)
+
+	_, err := env.ExecuteActivity(acts.FileUploadS3Activity, FileUploadS3ActivityInput{
+		FilePath: "/nonexistent", UploadURL: "https://s3.example.com/upload", ExpiresAt: time.Now().Add(-time.Minute),
+	})
+
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr))
+	assert.Equal(t, ErrorTypeUploadURLExpired, appErr.Type())
+	assert.True(t, appErr.NonRetryable())
+}
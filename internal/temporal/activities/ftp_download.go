@@ -49,7 +49,7 @@ func (a *Activities) FTPDownloadActivity(ctx context.Context, input FTPDownloadA
 			dirName = "backup"
 		}
 
-		mirrorDir, err := os.MkdirTemp(a.Config.TempDir, "ftp-mirror-*")
+		mirrorDir, err := os.MkdirTemp(a.Config.TempDir, input.Job.ID+"-ftp-mirror-*")
 		if err != nil {
 			return nil, fmt.Errorf("failed to create mirror temp dir: %w", err)
 		}
@@ -64,13 +64,13 @@ func (a *Activities) FTPDownloadActivity(ctx context.Context, input FTPDownloadA
 			"open -u %s,%s %s; mirror %s %s; exit",
 			ftpConfig.Username, ftpConfig.Password, lftpURL, mirrorRemote, mirrorDir,
 		)
-		if out, err := exec.CommandContext(ctx, "lftp", "-c", lftpScript).CombinedOutput(); err != nil {
+		if out, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "lftp", "-c", lftpScript), mirrorDir); err != nil {
 			return nil, fmt.Errorf("lftp mirror failed: %w, output: %s", err, string(out))
 		}
 
 		archiveName := fmt.Sprintf("%s-%s.tar.gz", input.Job.ID, dirName)
 		tempFilePath = filepath.Join(a.Config.TempDir, archiveName)
-		if out, err := exec.CommandContext(ctx, "tar", "-czf", tempFilePath, "-C", mirrorDir, ".").CombinedOutput(); err != nil {
+		if out, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-czf", tempFilePath, "-C", mirrorDir, "."), tempFilePath); err != nil {
 			return nil, fmt.Errorf("failed to archive mirrored directory: %w, output: %s", err, string(out))
 		}
 
@@ -82,7 +82,7 @@ func (a *Activities) FTPDownloadActivity(ctx context.Context, input FTPDownloadA
 			"open -u %s,%s %s; get %s -o %s; exit",
 			ftpConfig.Username, ftpConfig.Password, lftpURL, remotePath, tempFilePath,
 		)
-		if out, err := exec.CommandContext(ctx, "lftp", "-c", lftpScript).CombinedOutput(); err != nil {
+		if out, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "lftp", "-c", lftpScript), tempFilePath); err != nil {
 			return nil, fmt.Errorf("transfer failed: %w, output: %s", err, string(out))
 		}
 
@@ -31,7 +31,7 @@ func (a *Activities) GitDownloadActivity(ctx context.Context, input GitDownloadA
 		return nil, fmt.Errorf("invalid Git config: %w", err)
 	}
 
-	cloneDir, err := os.MkdirTemp(a.Config.TempDir, "git-clone-*")
+	cloneDir, err := os.MkdirTemp(a.Config.TempDir, input.Job.ID+"-git-clone-*")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp clone dir: %w", err)
 	}
@@ -85,7 +85,7 @@ func (a *Activities) GitDownloadActivity(ctx context.Context, input GitDownloadA
 	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Env = env
 
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := a.runWithProgress(ctx, cmd, cloneDir); err != nil {
 		return nil, fmt.Errorf("git clone failed: %w, output: %s", err, string(output))
 	}
 
@@ -99,7 +99,7 @@ func (a *Activities) GitDownloadActivity(ctx context.Context, input GitDownloadA
 		tarArgs = []string{"-czf", tempFilePath, "-C", cloneDir, "--exclude=./.git", "."}
 	}
 	tarCmd := exec.CommandContext(ctx, "tar", tarArgs...)
-	if output, err := tarCmd.CombinedOutput(); err != nil {
+	if output, err := a.runWithProgress(ctx, tarCmd, tempFilePath); err != nil {
 		return nil, fmt.Errorf("failed to create archive: %w, output: %s", err, string(output))
 	}
 
@@ -0,0 +1,19 @@
+package activities
+
+import (
+	"context"
+	"io"
+)
+
+// contextReader stops a copy once the activity context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
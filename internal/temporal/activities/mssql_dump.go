@@ -3,12 +3,12 @@ package activities
 import (
 	"agent/internal/job"
 	"context"
-	"crypto/sha256"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"go.temporal.io/sdk/activity"
 )
@@ -17,6 +17,9 @@ type MSSQLDumpActivityInput struct {
 	Job *job.Job `json:"job"`
 }
 
+// MSSQLDumpActivity takes a native backup of the job's database and archives it as a tar.gz, or
+// exports it as a BACPAC. The server writes native backups to BackupDir, the URL bucket or, when
+// it runs on the agent's machine, a directory in TempDir; the agent then reads them from there.
 func (a *Activities) MSSQLDumpActivity(ctx context.Context, input MSSQLDumpActivityInput) (*DownloadActivityOutput, error) {
 	logger := activity.GetLogger(ctx)
 	logger.Info("MSSQLDumpActivity started", "jobId", input.Job.ID)
@@ -28,24 +31,140 @@ func (a *Activities) MSSQLDumpActivity(ctx context.Context, input MSSQLDumpActiv
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid MSSQL config: %w", err)
 	}
+	if cfg.Method == job.MSSQLMethodBacpac {
+		return a.mssqlExportBacpac(ctx, input.Job.ID, cfg)
+	}
 
-	tempDir, err := os.MkdirTemp("", "mssql-backup-*")
+	tempDir, err := os.MkdirTemp(a.Config.TempDir, input.Job.ID+"-mssql-backup-*")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
 	defer os.RemoveAll(tempDir)
+	// The server writes the backup itself, as the user SQL Server runs as
+	if cfg.BackupDir == "" && cfg.URL == nil {
+		if err := os.Chmod(tempDir, 0o777); err != nil {
+			return nil, fmt.Errorf("failed to chmod temp dir: %w", err)
+		}
+	}
+
+	backupFileName := fmt.Sprintf("backup-%s%s", input.Job.ID, mssqlBackupExt(cfg.BackupType))
+	loc := mssqlLocation(cfg, backupFileName, tempDir)
 
-	backupFileName := fmt.Sprintf("backup-%s.bak", input.Job.ID)
-	backupFilePath := filepath.Join(tempDir, backupFileName)
+	// NOINIT appends to an existing file, which a failed earlier run may have left behind
+	if err := loc.remove(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := loc.remove(context.WithoutCancel(ctx)); err != nil {
+			logger.Warn("Failed to remove backup file", "error", err)
+		}
+	}()
 
-	query := fmt.Sprintf("BACKUP DATABASE [%s] TO DISK = N'%s' WITH NOFORMAT, NOINIT, NAME = N'full-backup', SKIP, NOREWIND, NOUNLOAD, STATS = 10",
-		cfg.Database, backupFilePath)
+	query := mssqlBackupStatement(cfg, loc.Clause)
+	logger.Info("Executing backup", "type", cfg.BackupType, "copyOnly", cfg.CopyOnly)
 
-	args := []string{
-		"-S", fmt.Sprintf("%s,%d", cfg.Host, cfg.Port),
-		"-d", cfg.Database,
-		"-Q", query,
+	args := append(mssqlArgs(cfg), "-b", "-d", cfg.Database, "-Q", query)
+	output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "sqlcmd", args...), loc.Local)
+	if err != nil {
+		return nil, fmt.Errorf("mssql backup failed: %w, output: %s", err, string(output))
+	}
+	if err := loc.fetch(ctx, a); err != nil {
+		return nil, err
+	}
+
+	archiveName := fmt.Sprintf("%s.tar.gz", input.Job.ID)
+	archivePath := filepath.Join(a.Config.TempDir, archiveName)
+
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-czf", archivePath,
+		"-C", filepath.Dir(loc.Local), backupFileName), archivePath); err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w, output: %s", err, string(output))
+	}
+
+	out, err := a.hashAndReturn(archivePath, archiveName, "application/gzip")
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("MSSQLDumpActivity completed", "filePath", archivePath, "size", out.Size)
+	return out, nil
+}
+
+// mssqlExportBacpac exports the schema and data of the database into a BACPAC with SqlPackage,
+// which reads them over the connection and so works for remote servers
+func (a *Activities) mssqlExportBacpac(ctx context.Context, jobId string, cfg *job.MSSQLConfig) (*DownloadActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+
+	name := jobId + ".bacpac"
+	path := filepath.Join(a.Config.TempDir, name)
+
+	args := append([]string{"/Action:Export", "/TargetFile:" + path}, mssqlPackageArgs(cfg, "Source", cfg.Database)...)
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "sqlpackage", args...), path); err != nil {
+		return nil, fmt.Errorf("bacpac export failed: %w, output: %s", err, string(output))
+	}
+
+	out, err := a.hashAndReturn(path, name, "application/zip")
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("MSSQLDumpActivity completed", "filePath", path, "size", out.Size)
+	return out, nil
+}
+
+// mssqlBackupExt returns the conventional extension of a backup file of the backup type
+func mssqlBackupExt(backupType string) string {
+	switch backupType {
+	case job.MSSQLBackupDifferential:
+		return ".dif"
+	case job.MSSQLBackupLog:
+		return ".trn"
+	}
+	return ".bak"
+}
+
+// mssqlBackupStatement returns the BACKUP statement of the job's backup type, written to target,
+// a DISK = or URL = clause
+func mssqlBackupStatement(cfg *job.MSSQLConfig, target string) string {
+	backupType := cfg.BackupType
+	if backupType == "" {
+		backupType = job.MSSQLBackupFull
+	}
+	statement := "BACKUP DATABASE"
+	if backupType == job.MSSQLBackupLog {
+		statement = "BACKUP LOG"
+	}
+
+	var opts []string
+	if backupType == job.MSSQLBackupDifferential {
+		opts = append(opts, "DIFFERENTIAL")
+	}
+	if cfg.CopyOnly {
+		opts = append(opts, "COPY_ONLY")
+	}
+	if cfg.Compression {
+		opts = append(opts, "COMPRESSION")
+	}
+	opts = append(opts, "NOFORMAT", "NOINIT", fmt.Sprintf("NAME = N'%s-backup'", backupType),
+		"SKIP", "NOREWIND", "NOUNLOAD", "STATS = 10")
+
+	return fmt.Sprintf("%s [%s] TO %s WITH %s",
+		statement, mssqlQuoteName(cfg.Database), target, strings.Join(opts, ", "))
+}
+
+// mssqlServer returns the server name of the job as sqlcmd and SqlPackage take it:
+// host[\instance][,port]. Without a port the instance is resolved by SQL Server Browser.
+func mssqlServer(cfg *job.MSSQLConfig) string {
+	server := cfg.Host
+	if cfg.Instance != "" {
+		server += `\` + cfg.Instance
+	}
+	if cfg.Port != 0 {
+		server += "," + strconv.Itoa(cfg.Port)
 	}
+	return server
+}
+
+// mssqlArgs returns the sqlcmd arguments that connect to the server of the job
+func mssqlArgs(cfg *job.MSSQLConfig) []string {
+	args := []string{"-S", mssqlServer(cfg)}
 
 	if cfg.Username != "" {
 		args = append(args, "-U", cfg.Username)
@@ -61,42 +180,27 @@ func (a *Activities) MSSQLDumpActivity(ctx context.Context, input MSSQLDumpActiv
 	if cfg.TrustCert {
 		args = append(args, "-C")
 	}
-
-	output, err := exec.CommandContext(ctx, "sqlcmd", args...).CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("mssql backup failed: %w, output: %s", err, string(output))
-	}
-
-	archiveName := fmt.Sprintf("%s.tar.gz", input.Job.ID)
-	archivePath := filepath.Join(a.Config.TempDir, archiveName)
-
-	if err := exec.CommandContext(ctx, "tar", "-czf", archivePath, "-C", tempDir, backupFileName).Run(); err != nil {
-		return nil, fmt.Errorf("failed to create archive: %w", err)
+	if cfg.ConnTimeout > 0 {
+		args = append(args, "-l", strconv.Itoa(cfg.ConnTimeout))
 	}
+	return args
+}
 
-	file, err := os.Open(archivePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open archive: %w", err)
+// mssqlPackageArgs returns the SqlPackage arguments that connect to database on the server of
+// the job, side being Source for exports and Target for imports
+func mssqlPackageArgs(cfg *job.MSSQLConfig, side, database string) []string {
+	args := []string{
+		fmt.Sprintf("/%sServerName:%s", side, mssqlServer(cfg)),
+		fmt.Sprintf("/%sDatabaseName:%s", side, database),
+		fmt.Sprintf("/%sUser:%s", side, cfg.Username),
+		fmt.Sprintf("/%sPassword:%s", side, cfg.Password),
+		fmt.Sprintf("/%sEncryptConnection:%t", side, cfg.Encrypt),
 	}
-	defer file.Close()
-
-	fi, err := file.Stat()
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat file: %w", err)
+	if cfg.TrustCert {
+		args = append(args, fmt.Sprintf("/%sTrustServerCertificate:True", side))
 	}
-
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return nil, fmt.Errorf("failed to calculate hash: %w", err)
+	if cfg.ConnTimeout > 0 {
+		args = append(args, fmt.Sprintf("/%sTimeout:%d", side, cfg.ConnTimeout))
 	}
-
-	logger.Info("MSSQLDumpActivity completed", "filePath", archivePath, "size", fi.Size())
-
-	return &DownloadActivityOutput{
-		FilePath: archivePath,
-		Size:     fi.Size(),
-		Checksum: fmt.Sprintf("%x", hash.Sum(nil)),
-		Name:     archiveName,
-		MimeType: "application/gzip",
-	}, nil
+	return args
 }
@@ -0,0 +1,75 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMSSQLBackupStatement(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  job.MSSQLConfig
+		want string
+	}{
+		{
+			"full",
+			job.MSSQLConfig{Database: "app"},
+			"BACKUP DATABASE [app] TO DISK = N'/b/x.bak' WITH NOFORMAT, NOINIT, NAME = N'full-backup', SKIP, NOREWIND, NOUNLOAD, STATS = 10",
+		},
+		{
+			"differential compressed",
+			job.MSSQLConfig{Database: "app", BackupType: job.MSSQLBackupDifferential, Compression: true},
+			"BACKUP DATABASE [app] TO DISK = N'/b/x.bak' WITH DIFFERENTIAL, COMPRESSION, NOFORMAT, NOINIT, NAME = N'differential-backup', SKIP, NOREWIND, NOUNLOAD, STATS = 10",
+		},
+		{
+			"copy only log",
+			job.MSSQLConfig{Database: "a]b", BackupType: job.MSSQLBackupLog, CopyOnly: true},
+			"BACKUP LOG [a]]b] TO DISK = N'/b/x.bak' WITH COPY_ONLY, NOFORMAT, NOINIT, NAME = N'log-backup', SKIP, NOREWIND, NOUNLOAD, STATS = 10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, mssqlBackupStatement(&tt.cfg, "DISK = N'/b/x.bak'"))
+		})
+	}
+}
+
+func TestMSSQLServerArgs(t *testing.T) {
+	assert.Equal(t, "db,1433", mssqlServer(&job.MSSQLConfig{Host: "db", Port: 1433}))
+	assert.Equal(t, `db\SQLEXPRESS`, mssqlServer(&job.MSSQLConfig{Host: "db", Instance: "SQLEXPRESS"}))
+	assert.Equal(t, `db\SQLEXPRESS,1500`, mssqlServer(&job.MSSQLConfig{Host: "db", Instance: "SQLEXPRESS", Port: 1500}))
+
+	cfg := &job.MSSQLConfig{Host: "db", Port: 1433, Username: "sa", Password: "pw", TrustCert: true, ConnTimeout: 15}
+	assert.Equal(t, []string{"-S", "db,1433", "-U", "sa", "-P", "pw", "-C", "-l", "15"}, mssqlArgs(cfg))
+	assert.Equal(t, []string{
+		"/SourceServerName:db,1433",
+		"/SourceDatabaseName:app",
+		"/SourceUser:sa",
+		"/SourcePassword:pw",
+		"/SourceEncryptConnection:false",
+		"/SourceTrustServerCertificate:True",
+		"/SourceTimeout:15",
+	}, mssqlPackageArgs(cfg, "Source", "app"))
+}
+
+func TestMSSQLLocation(t *testing.T) {
+	assert.Equal(t, `D:\Backups\x.bak`, mssqlServerPath(`D:\Backups\`, "x.bak"))
+	assert.Equal(t, "/var/opt/mssql/backup/x.bak", mssqlServerPath("/var/opt/mssql/backup", "x.bak"))
+
+	local := mssqlLocation(&job.MSSQLConfig{}, "x.bak", "/tmp/agent/job-1-mssql-backup-1")
+	assert.Equal(t, "DISK = N'/tmp/agent/job-1-mssql-backup-1/x.bak'", local.Clause)
+	assert.Equal(t, "/tmp/agent/job-1-mssql-backup-1/x.bak", local.Local)
+
+	share := mssqlLocation(&job.MSSQLConfig{BackupDir: `D:\Backups`, LocalDir: "/mnt/backups"}, "x.bak", "/tmp")
+	assert.Equal(t, `DISK = N'D:\Backups\x.bak'`, share.Clause)
+	assert.Equal(t, "/mnt/backups/x.bak", share.Local)
+
+	url := mssqlLocation(&job.MSSQLConfig{URL: &job.MSSQLURLConfig{
+		Endpoint: "https://minio.local:9000", Bucket: "sql", Prefix: "prod",
+	}}, "x.bak", "/tmp/dir")
+	assert.Equal(t, "URL = N's3://minio.local:9000/sql/prod/x.bak'", url.Clause)
+	assert.Equal(t, "prod/x.bak", url.Key)
+	assert.Equal(t, "/tmp/dir/x.bak", url.Local)
+}
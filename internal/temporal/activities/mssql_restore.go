@@ -0,0 +1,189 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// mssqlFile is a database file listed by RESTORE FILELISTONLY
+type mssqlFile struct {
+	LogicalName  string
+	PhysicalName string
+}
+
+// MSSQLRestoreActivity restores the .bak file of an MSSQL backup with RESTORE DATABASE, or
+// imports a BACPAC with SqlPackage. When the target is another database, its files are moved
+// next to the original ones under the new database name, so both databases can live on the same
+// server. The .bak is staged where the server reads it the same way the dump had it written.
+func (a *Activities) MSSQLRestoreActivity(ctx context.Context, input RestoreActivityInput) (*RestoreActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("MSSQLRestoreActivity started", "jobId", input.Job.ID)
+
+	cfg, err := job.LoadAs[*job.MSSQLConfig](*input.Job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MSSQL config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid MSSQL config: %w", err)
+	}
+	database := cfg.Database
+	if input.Target.Database != "" {
+		database = input.Target.Database
+	}
+
+	// Restores run against master, the target database may not exist yet
+	sqlcmd := func(extra ...string) *exec.Cmd {
+		args := append(mssqlArgs(cfg), "-d", "master")
+		return exec.CommandContext(ctx, "sqlcmd", append(args, extra...)...)
+	}
+
+	if strings.HasSuffix(input.FilePath, ".bacpac") {
+		if err := a.mssqlImportBacpac(ctx, cfg, input.FilePath, database, input.Target.Overwrite, sqlcmd); err != nil {
+			return nil, err
+		}
+		logger.Info("MSSQLRestoreActivity completed", "database", database)
+		return &RestoreActivityOutput{}, nil
+	}
+
+	tempDir, err := os.MkdirTemp(a.Config.TempDir, input.Job.ID+"-mssql-restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-xzf", input.FilePath, "-C", tempDir), tempDir); err != nil {
+		return nil, fmt.Errorf("failed to extract archive: %w, output: %s", err, string(output))
+	}
+	difs, _ := filepath.Glob(filepath.Join(tempDir, "*.dif"))
+	trns, _ := filepath.Glob(filepath.Join(tempDir, "*.trn"))
+	if len(difs)+len(trns) > 0 {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"differential and log backups only restore on top of their full backup, restore them manually",
+			"RestoreUnsupported", nil)
+	}
+	baks, _ := filepath.Glob(filepath.Join(tempDir, "*.bak"))
+	if len(baks) != 1 {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("expected one .bak file in the backup, found %d", len(baks)), "InvalidBackup", nil)
+	}
+	bakPath := baks[0]
+
+	name := filepath.Base(bakPath)
+	if cfg.BackupDir != "" || cfg.URL != nil {
+		name = "restore-" + name
+	} else if err := os.Chmod(tempDir, 0o755); err != nil {
+		// The server reads the backup itself, as the user SQL Server runs as
+		return nil, fmt.Errorf("failed to chmod temp dir: %w", err)
+	}
+	loc := mssqlLocation(cfg, name, tempDir)
+	if err := loc.stage(ctx, a, bakPath); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := loc.remove(context.WithoutCancel(ctx)); err != nil {
+			logger.Warn("Failed to remove staged backup", "error", err)
+		}
+	}()
+
+	output, err := sqlcmd("-h", "-1", "-W", "-s", "|", "-Q",
+		fmt.Sprintf("SET NOCOUNT ON; RESTORE FILELISTONLY FROM %s", loc.Clause)).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup files: %w, output: %s", err, string(output))
+	}
+	files := parseMSSQLFileList(string(output))
+
+	query := fmt.Sprintf("RESTORE DATABASE [%s] FROM %s WITH %s",
+		mssqlQuoteName(database), loc.Clause, strings.Join(mssqlRestoreOptions(cfg.Database, database, files, input.Target.Overwrite), ", "))
+
+	logger.Info("Executing RESTORE DATABASE", "database", database, "files", len(files))
+
+	output, err = a.runWithProgress(ctx, sqlcmd("-b", "-Q", query), bakPath)
+	if err != nil {
+		return nil, fmt.Errorf("mssql restore failed: %w, output: %s", err, string(output))
+	}
+
+	logger.Info("MSSQLRestoreActivity completed", "database", database)
+	return &RestoreActivityOutput{}, nil
+}
+
+// mssqlImportBacpac imports a BACPAC into database with SqlPackage, which requires the database
+// to be missing or empty; with overwrite an existing database is dropped first
+func (a *Activities) mssqlImportBacpac(ctx context.Context, cfg *job.MSSQLConfig, path, database string, overwrite bool, sqlcmd func(...string) *exec.Cmd) error {
+	if overwrite {
+		name := mssqlQuoteName(database)
+		query := fmt.Sprintf("IF DB_ID(N'%s') IS NOT NULL BEGIN ALTER DATABASE [%s] SET SINGLE_USER WITH ROLLBACK IMMEDIATE; DROP DATABASE [%s]; END",
+			mssqlQuoteString(database), name, name)
+		if output, err := sqlcmd("-b", "-Q", query).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to drop database: %w, output: %s", err, string(output))
+		}
+	}
+
+	args := append([]string{"/Action:Import", "/SourceFile:" + path}, mssqlPackageArgs(cfg, "Target", database)...)
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "sqlpackage", args...), path); err != nil {
+		return fmt.Errorf("bacpac import failed: %w, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// mssqlRestoreOptions returns the WITH options of a RESTORE DATABASE from the source database
+// into target
+func mssqlRestoreOptions(source, target string, files []mssqlFile, overwrite bool) []string {
+	var opts []string
+	if target != source {
+		for _, f := range files {
+			opts = append(opts, fmt.Sprintf("MOVE N'%s' TO N'%s'",
+				mssqlQuoteString(f.LogicalName), mssqlQuoteString(mssqlMovedPath(f, target))))
+		}
+	}
+	if overwrite {
+		opts = append(opts, "REPLACE")
+	}
+	return append(opts, "STATS = 10")
+}
+
+// mssqlMovedPath returns the physical path of a database file restored as target: the same
+// directory and extension as the original, named after the target database and logical file.
+// Backslashes are kept, the path is resolved by the server which may run on Windows.
+func mssqlMovedPath(f mssqlFile, target string) string {
+	dir, base := "", f.PhysicalName
+	if i := strings.LastIndexAny(f.PhysicalName, `/\`); i >= 0 {
+		dir, base = f.PhysicalName[:i+1], f.PhysicalName[i+1:]
+	}
+	ext := ""
+	if i := strings.LastIndex(base, "."); i >= 0 {
+		ext = base[i:]
+	}
+	return fmt.Sprintf("%s%s_%s%s", dir, target, f.LogicalName, ext)
+}
+
+// parseMSSQLFileList parses the output of RESTORE FILELISTONLY printed by sqlcmd without
+// headers and with "|" separated columns: LogicalName|PhysicalName|Type|...
+func parseMSSQLFileList(output string) []mssqlFile {
+	var files []mssqlFile
+	for _, line := range strings.Split(output, "\n") {
+		cols := strings.Split(strings.TrimSpace(line), "|")
+		if len(cols) < 3 || cols[0] == "" || cols[1] == "" {
+			continue
+		}
+		files = append(files, mssqlFile{LogicalName: cols[0], PhysicalName: cols[1]})
+	}
+	return files
+}
+
+// mssqlQuoteName escapes a name for use between brackets
+func mssqlQuoteName(name string) string {
+	return strings.ReplaceAll(name, "]", "]]")
+}
+
+// mssqlQuoteString escapes a value for use in an N'...' literal
+func mssqlQuoteString(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
@@ -0,0 +1,25 @@
+package activities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMSSQLRestoreOptions(t *testing.T) {
+	output := "app|C:\\Data\\app.mdf|D|PRIMARY|...\napp_log|C:\\Data\\app_log.ldf|L|NULL|...\n\n"
+	files := parseMSSQLFileList(output)
+	assert.Equal(t, []mssqlFile{
+		{LogicalName: "app", PhysicalName: `C:\Data\app.mdf`},
+		{LogicalName: "app_log", PhysicalName: `C:\Data\app_log.ldf`},
+	}, files)
+
+	// Restoring over the source database keeps its files
+	assert.Equal(t, []string{"REPLACE", "STATS = 10"}, mssqlRestoreOptions("app", "app", files, true))
+
+	assert.Equal(t, []string{
+		`MOVE N'app' TO N'C:\Data\app_copy_app.mdf'`,
+		`MOVE N'app_log' TO N'C:\Data\app_copy_app_log.ldf'`,
+		"STATS = 10",
+	}, mssqlRestoreOptions("app", "app_copy", files, false))
+}
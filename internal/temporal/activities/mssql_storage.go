@@ -0,0 +1,176 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// mssqlBackupLocation is where the server reads or writes a backup file and where the agent
+// finds it. The server sees the path or s3:// URL in Clause; the agent reads and writes Local,
+// which it downloads from or uploads to the object Key of the job's URL bucket.
+type mssqlBackupLocation struct {
+	// Clause is the DISK = or URL = clause of BACKUP and RESTORE
+	Clause string
+	Local  string
+	Key    string
+	url    *job.MSSQLURLConfig
+}
+
+// mssqlLocation returns the location of the backup file name: in the URL bucket, in BackupDir,
+// or in localDir on the agent's machine when the server runs there as well
+func mssqlLocation(cfg *job.MSSQLConfig, name, localDir string) mssqlBackupLocation {
+	switch {
+	case cfg.URL != nil:
+		key := path.Join(cfg.URL.Prefix, name)
+		return mssqlBackupLocation{
+			Clause: fmt.Sprintf("URL = N'%s'", mssqlQuoteString(mssqlS3URL(cfg.URL, key))),
+			Local:  filepath.Join(localDir, name),
+			Key:    key,
+			url:    cfg.URL,
+		}
+	case cfg.BackupDir != "":
+		dir := cfg.LocalDir
+		if dir == "" {
+			dir = cfg.BackupDir
+		}
+		return mssqlBackupLocation{
+			Clause: fmt.Sprintf("DISK = N'%s'", mssqlQuoteString(mssqlServerPath(cfg.BackupDir, name))),
+			Local:  filepath.Join(dir, name),
+		}
+	default:
+		local := filepath.Join(localDir, name)
+		return mssqlBackupLocation{Clause: fmt.Sprintf("DISK = N'%s'", mssqlQuoteString(local)), Local: local}
+	}
+}
+
+// mssqlS3URL returns the s3:// URL of an object for BACKUP TO URL, which names the endpoint by
+// host and port only
+func mssqlS3URL(cfg *job.MSSQLURLConfig, key string) string {
+	host := strings.TrimSuffix(cfg.Endpoint, "/")
+	if u, err := url.Parse(cfg.Endpoint); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return "s3://" + host + "/" + cfg.Bucket + "/" + key
+}
+
+// mssqlServerPath joins a file name to a directory on the server, with backslashes when the
+// directory has them since the server may run on Windows
+func mssqlServerPath(dir, name string) string {
+	separator := "/"
+	if strings.Contains(dir, `\`) {
+		separator = `\`
+	}
+	return strings.TrimRight(dir, `/\`) + separator + name
+}
+
+// s3Client returns a path-style client for the URL bucket, as most S3 compatible services need
+func (l mssqlBackupLocation) s3Client(ctx context.Context) (*s3.Client, error) {
+	region := l.url.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return newS3Client(ctx, &job.AWSS3Config{
+		Region:          region,
+		Bucket:          l.url.Bucket,
+		Endpoint:        l.url.Endpoint,
+		AccessKeyID:     l.url.AccessKeyID,
+		SecretAccessKey: l.url.SecretAccessKey,
+	}, func(o *s3.Options) { o.UsePathStyle = true })
+}
+
+// fetch makes a backup the server wrote available at Local, downloading it from the bucket
+func (l mssqlBackupLocation) fetch(ctx context.Context, a *Activities) error {
+	if l.url == nil {
+		return nil
+	}
+	client, err := l.s3Client(ctx)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(l.Local)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer file.Close()
+
+	stop := a.newProgressReporter(ctx, 0).Watch(l.Local)
+	defer stop()
+	if _, err := manager.NewDownloader(client).Download(ctx, file, &s3.GetObjectInput{
+		Bucket: aws.String(l.url.Bucket),
+		Key:    aws.String(l.Key),
+	}); err != nil {
+		return fmt.Errorf("failed to download backup from %s: %w", l.Key, err)
+	}
+	return nil
+}
+
+// stage makes the backup at src readable by the server, uploading it to the bucket or copying
+// it to Local
+func (l mssqlBackupLocation) stage(ctx context.Context, a *Activities, src string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer file.Close()
+	progress := a.newProgressReporter(ctx, 0)
+
+	if l.url == nil {
+		if src == l.Local {
+			return nil
+		}
+		dst, err := os.Create(l.Local)
+		if err != nil {
+			return fmt.Errorf("failed to create backup file: %w", err)
+		}
+		if _, err := io.Copy(dst, &contextReader{ctx: ctx, r: io.TeeReader(file, progress)}); err != nil {
+			dst.Close()
+			return fmt.Errorf("failed to copy backup to %s: %w", l.Local, err)
+		}
+		return dst.Close()
+	}
+
+	client, err := l.s3Client(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := manager.NewUploader(client).Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(l.url.Bucket),
+		Key:    aws.String(l.Key),
+		Body:   io.TeeReader(file, progress),
+	}); err != nil {
+		return fmt.Errorf("failed to upload backup to %s: %w", l.Key, err)
+	}
+	return nil
+}
+
+// remove deletes the backup the server reads or writes, the agent keeps its own copy in TempDir
+func (l mssqlBackupLocation) remove(ctx context.Context) error {
+	if l.url == nil {
+		if err := os.Remove(l.Local); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove backup file: %w", err)
+		}
+		return nil
+	}
+	client, err := l.s3Client(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(l.url.Bucket),
+		Key:    aws.String(l.Key),
+	}); err != nil {
+		return fmt.Errorf("failed to delete backup %s: %w", l.Key, err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// mySQLBinlogExtension names the archives of binary logs
+	mySQLBinlogExtension = ".binlog.tar"
+	// mySQLBinlogManifestFile is the root file of a binlog archive
+	mySQLBinlogManifestFile = "manifest.json"
+	// mySQLBinlogStart is the position of the first event of a binary log
+	mySQLBinlogStart = 4
+	// mySQLPositionScanLimit bounds how much of a dump is searched for its binlog position,
+	// which --source-data writes right after the header
+	mySQLPositionScanLimit = 64 << 10
+)
+
+// mySQLSourceData matches the binlog position that mysqldump --source-data writes, in the
+// CHANGE REPLICATION SOURCE syntax of MySQL 8.0.23 and later or the older CHANGE MASTER
+var mySQLSourceData = regexp.MustCompile(`(?:SOURCE|MASTER)_LOG_FILE='([^']+)',\s*(?:SOURCE|MASTER)_LOG_POS=(\d+)`)
+
+// mySQLBinlogState is the binlog position the next backup of a binlog job continues from
+type mySQLBinlogState struct {
+	File     string `json:"file"`
+	Position int64  `json:"position"`
+}
+
+// mySQLBinlogManifest describes a binlog archive, it is written to its manifest.json. Replaying
+// the files from Start up to End rolls the previous backup forward to the time of this one.
+type mySQLBinlogManifest struct {
+	Version   int              `json:"version"`
+	CreatedAt time.Time        `json:"created_at"`
+	Start     mySQLBinlogState `json:"start"`
+	End       mySQLBinlogState `json:"end"`
+	Files     []string         `json:"files"`
+}
+
+// mySQLBinaryLog is a line of SHOW BINARY LOGS
+type mySQLBinaryLog struct {
+	Name string
+	Size int64
+}
+
+// mySQLPositionWriter keeps the head of a dump to find the binlog position in it
+type mySQLPositionWriter struct {
+	head []byte
+}
+
+func (w *mySQLPositionWriter) Write(p []byte) (int, error) {
+	if n := mySQLPositionScanLimit - len(w.head); n > 0 {
+		w.head = append(w.head, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// position returns the binlog position of the dump
+func (w *mySQLPositionWriter) position() (mySQLBinlogState, bool) {
+	m := mySQLSourceData.FindSubmatch(w.head)
+	if m == nil {
+		return mySQLBinlogState{}, false
+	}
+	pos, err := strconv.ParseInt(string(m[2]), 10, 64)
+	if err != nil {
+		return mySQLBinlogState{}, false
+	}
+	return mySQLBinlogState{File: string(m[1]), Position: pos}, true
+}
+
+// mySQLBinaryLogs lists the binary logs of the server, after rotating to a new one with flush
+func (a *Activities) mySQLBinaryLogs(ctx context.Context, jobId string, conn mySQLConn, flush bool) ([]mySQLBinaryLog, error) {
+	query := "SHOW BINARY LOGS"
+	if flush {
+		query = "FLUSH BINARY LOGS; " + query
+	}
+	args, cleanup, err := a.mySQLArgs(jobId, conn, "-N", "-B", "-e", query)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	out, err := querySQL(ctx, a.mySQLTool("mysql"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list binary logs: %w", err)
+	}
+	return parseMySQLBinaryLogs(out)
+}
+
+// parseMySQLBinaryLogs parses the tab separated output of SHOW BINARY LOGS, whose third
+// column with the encryption only exists on newer servers
+func parseMySQLBinaryLogs(out string) ([]mySQLBinaryLog, error) {
+	var logs []mySQLBinaryLog
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("unexpected binary log line: %q", line)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected binary log size in line: %q", line)
+		}
+		logs = append(logs, mySQLBinaryLog{Name: fields[0], Size: size})
+	}
+	return logs, nil
+}
+
+// mySQLBinlogsSince returns the binary logs from the one of state on, or false when the server
+// no longer has it
+func mySQLBinlogsSince(logs []mySQLBinaryLog, state mySQLBinlogState) ([]mySQLBinaryLog, bool) {
+	for i, log := range logs {
+		if log.Name == state.File {
+			return logs[i:], true
+		}
+	}
+	return nil, false
+}
+
+// mySQLArchiveBinlogs rotates the binary log of the server, fetches the closed logs from the one
+// of state on with mysqlbinlog --read-from-remote-server and tars them into archivePath with a
+// manifest. The first log is fetched whole, the manifest has the position to replay it from.
+// When the server purged the log of state the saved state is dropped, so the next backup is a
+// full dump again.
+func (a *Activities) mySQLArchiveBinlogs(ctx context.Context, jobId string, conn mySQLConn, state mySQLBinlogState, archivePath string) (*mySQLBinlogManifest, error) {
+	logs, err := a.mySQLBinaryLogs(ctx, jobId, conn, true)
+	if err != nil {
+		return nil, err
+	}
+	since, ok := mySQLBinlogsSince(logs, state)
+	if !ok {
+		if err := a.removeBackupState(jobId); err != nil {
+			return nil, err
+		}
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("binary log %s was purged from the server, the next backup will be a full dump", state.File),
+			"BinlogPurged", nil)
+	}
+
+	// The last log is the one the flush opened, the others are complete
+	current := since[len(since)-1]
+	manifest := &mySQLBinlogManifest{
+		Version:   1,
+		CreatedAt: time.Now().UTC(),
+		Start:     state,
+		End:       mySQLBinlogState{File: current.Name, Position: mySQLBinlogStart},
+		Files:     []string{},
+	}
+	for _, log := range since[:len(since)-1] {
+		manifest.Files = append(manifest.Files, log.Name)
+	}
+
+	tempDir, err := os.MkdirTemp(a.Config.TempDir, jobId+"-mysql-binlog-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if len(manifest.Files) > 0 {
+		args, cleanup, err := a.mySQLArgs(jobId, conn, append([]string{"--read-from-remote-server", "--raw",
+			"--result-file=" + tempDir + string(filepath.Separator)}, manifest.Files...)...)
+		if err != nil {
+			return nil, err
+		}
+		output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, a.mySQLTool("mysqlbinlog"), args...), tempDir)
+		cleanup()
+		if err != nil {
+			return nil, fmt.Errorf("mysqlbinlog failed: %w, output: %s", err, string(output))
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, mySQLBinlogManifestFile), data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-cf", archivePath, "-C", tempDir, "."), archivePath); err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w, output: %s", err, string(output))
+	}
+	return manifest, nil
+}
+
+// isMySQLBinlogArchive reports whether a backup file is a binlog archive by its name, which
+// keeps a gzip layer on download
+func isMySQLBinlogArchive(filePath string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(filepath.Base(filePath), ".gz"), mySQLBinlogExtension)
+}
@@ -0,0 +1,108 @@
+package activities
+
+import (
+	"agent/internal/config"
+	"agent/internal/job"
+	"encoding/json"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMySQLPositionWriter(t *testing.T) {
+	header := "-- MySQL dump 10.13\n--\n-- Position to start replication or point-in-time recovery from\n--\n\n"
+
+	w := &mySQLPositionWriter{}
+	// The line may be split across writes
+	w.Write([]byte(header + "-- CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.0000"))
+	w.Write([]byte("42', SOURCE_LOG_POS=157;\n"))
+	state, ok := w.position()
+	require.True(t, ok)
+	assert.Equal(t, mySQLBinlogState{File: "binlog.000042", Position: 157}, state)
+
+	w = &mySQLPositionWriter{}
+	w.Write([]byte(header + "-- CHANGE MASTER TO MASTER_LOG_FILE='mysql-bin.000003', MASTER_LOG_POS=1234;\n"))
+	state, ok = w.position()
+	require.True(t, ok)
+	assert.Equal(t, mySQLBinlogState{File: "mysql-bin.000003", Position: 1234}, state)
+
+	w = &mySQLPositionWriter{}
+	w.Write([]byte(header + "CREATE TABLE t (id int);\n"))
+	_, ok = w.position()
+	assert.False(t, ok)
+}
+
+func TestMySQLBinlogsSince(t *testing.T) {
+	logs, err := parseMySQLBinaryLogs("binlog.000001\t1200\tNo\nbinlog.000002\t5400\tNo\nbinlog.000003\t157\tNo\n")
+	require.NoError(t, err)
+	assert.Equal(t, []mySQLBinaryLog{{"binlog.000001", 1200}, {"binlog.000002", 5400}, {"binlog.000003", 157}}, logs)
+
+	since, ok := mySQLBinlogsSince(logs, mySQLBinlogState{File: "binlog.000002", Position: 900})
+	require.True(t, ok)
+	assert.Equal(t, logs[1:], since)
+
+	_, ok = mySQLBinlogsSince(logs, mySQLBinlogState{File: "binlog.000000", Position: 4})
+	assert.False(t, ok)
+
+	// Servers before 8.0.14 have no encryption column
+	logs, err = parseMySQLBinaryLogs("mysql-bin.000009\t4096\n")
+	require.NoError(t, err)
+	assert.Equal(t, []mySQLBinaryLog{{"mysql-bin.000009", 4096}}, logs)
+
+	_, err = parseMySQLBinaryLogs("binlog.000001\n")
+	assert.Error(t, err)
+}
+
+func TestBackupState(t *testing.T) {
+	acts := &Activities{Config: &config.Config{StateDir: t.TempDir() + "/state"}}
+	binlog := &job.Job{ID: "job-1", Provider: job.JobProviderMySQL,
+		Config: &job.MySQLConfig{ConnectionString: "app@tcp(db)/shop", Method: job.MySQLMethodBinlog}}
+
+	var state mySQLBinlogState
+	found, err := acts.loadBackupState("job-1", &state)
+	require.NoError(t, err)
+	assert.False(t, found)
+	kind, err := acts.backupKind(binlog)
+	require.NoError(t, err)
+	assert.Equal(t, job.BackupKindFull, kind)
+
+	require.NoError(t, acts.saveBackupState("job-1", json.RawMessage(`{"file":"binlog.000042","position":157}`)))
+	found, err = acts.loadBackupState("job-1", &state)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, mySQLBinlogState{File: "binlog.000042", Position: 157}, state)
+	kind, err = acts.backupKind(binlog)
+	require.NoError(t, err)
+	assert.Equal(t, job.BackupKindIncremental, kind)
+
+	// Only the state file is left behind
+	entries, err := os.ReadDir(acts.Config.StateDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.NoError(t, acts.removeBackupState("job-1"))
+	require.NoError(t, acts.removeBackupState("job-1"))
+	kind, err = acts.backupKind(binlog)
+	require.NoError(t, err)
+	assert.Equal(t, job.BackupKindFull, kind)
+
+	dump := &job.Job{ID: "job-2", Provider: job.JobProviderMySQL, Config: &job.MySQLConfig{ConnectionString: "app@tcp(db)/shop"}}
+	kind, err = acts.backupKind(dump)
+	require.NoError(t, err)
+	assert.Empty(t, kind)
+}
+
+func TestMySQLBinlogJobs(t *testing.T) {
+	cfg := &job.MySQLConfig{ConnectionString: "app@tcp(db)/shop", Method: job.MySQLMethodBinlog}
+	args := mySQLDumpArgs(cfg, "shop")
+	assert.True(t, slices.Contains(args, "--source-data=2"))
+
+	assert.False(t, SupportsStreaming(&job.Job{Provider: job.JobProviderMySQL, Config: cfg}))
+	assert.True(t, SupportsStreaming(&job.Job{Provider: job.JobProviderMySQL, Config: &job.MySQLConfig{ConnectionString: "app@tcp(db)/shop"}}))
+
+	assert.True(t, isMySQLBinlogArchive("/tmp/agent/job-1-restore-job-1.binlog.tar.gz"))
+	assert.False(t, isMySQLBinlogArchive("/tmp/agent/job-1-restore-job-1.sql"))
+}
@@ -4,6 +4,7 @@ import (
 	"agent/internal/job"
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"strings"
 
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 )
 
 type MySQLDumpActivityInput struct {
@@ -30,83 +32,167 @@ func (a *Activities) MySQLDumpActivity(ctx context.Context, input MySQLDumpActiv
 		return nil, fmt.Errorf("invalid MySQL config: %w", err)
 	}
 
-	// Parse connection string: user:password@tcp(host:port)/dbname
-	connStr := cfg.ConnectionString
-	atIndex := strings.LastIndex(connStr, "@")
-	if atIndex == -1 {
-		return nil, fmt.Errorf("invalid connection string format: missing @")
+	// Binlog jobs take a full dump until a confirmed backup saved the position to continue from
+	var state mySQLBinlogState
+	incremental := false
+	if cfg.Method == job.MySQLMethodBinlog {
+		if incremental, err = a.loadBackupState(input.Job.ID, &state); err != nil {
+			return nil, err
+		}
 	}
-	userPass := connStr[:atIndex]
-	authParts := strings.SplitN(userPass, ":", 2)
-	username := authParts[0]
-	password := ""
-	if len(authParts) > 1 {
-		password = authParts[1]
+
+	filename, mimeType := fmt.Sprintf("%s.sql", input.Job.ID), "application/sql"
+	if incremental {
+		filename, mimeType = input.Job.ID+mySQLBinlogExtension, "application/x-tar"
 	}
+	tempFilePath := filepath.Join(a.Config.TempDir, filename)
 
-	remaining := connStr[atIndex+1:]
-	hostStart := strings.Index(remaining, "(")
-	hostEnd := strings.Index(remaining, ")")
-	if hostStart == -1 || hostEnd == -1 {
-		return nil, fmt.Errorf("invalid connection string format: missing protocol/host")
+	hash := sha256.New()
+	var next mySQLBinlogState
+	if incremental {
+		conn, err := parseMySQLConnectionString(cfg.ConnectionString)
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := a.mySQLArchiveBinlogs(ctx, input.Job.ID, conn, state, tempFilePath)
+		if err != nil {
+			return nil, err
+		}
+		next = manifest.End
+		logger.Info("Archived MySQL binary logs", "start", state.File, "end", next.File, "files", len(manifest.Files))
+
+		file, err := os.Open(tempFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive: %w", err)
+		}
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate hash: %w", err)
+		}
+	} else {
+		file, err := os.Create(tempFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp file: %w", err)
+		}
+
+		position := &mySQLPositionWriter{}
+		progress := a.newProgressReporter(ctx, 0)
+		stop := progress.Keepalive()
+		err = a.mySQLDump(ctx, input.Job.ID, cfg, io.MultiWriter(file, hash, progress, position))
+		stop()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		file.Close()
+		progress.Done()
+
+		if cfg.Method == job.MySQLMethodBinlog {
+			var ok bool
+			if next, ok = position.position(); !ok {
+				return nil, temporal.NewNonRetryableApplicationError(
+					"the dump has no binary log position, is binary logging enabled on the server?", "BinlogDisabled", nil)
+			}
+			logger.Info("Dumped MySQL at binary log position", "file", next.File, "position", next.Position)
+		}
 	}
-	hostPort := remaining[hostStart+1 : hostEnd]
-	hostParts := strings.Split(hostPort, ":")
-	host := hostParts[0]
-	port := "3306"
-	if len(hostParts) > 1 {
-		port = hostParts[1]
+
+	fi, err := os.Stat(tempFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat temp file: %w", err)
 	}
-	slashIndex := strings.Index(remaining[hostEnd:], "/")
-	if slashIndex == -1 {
-		return nil, fmt.Errorf("invalid connection string format: missing database name")
+
+	logger.Info("MySQLDumpActivity completed", "filePath", tempFilePath, "size", fi.Size())
+
+	output := &DownloadActivityOutput{
+		FilePath: tempFilePath,
+		Size:     fi.Size(),
+		Checksum: fmt.Sprintf("%x", hash.Sum(nil)),
+		Name:     filename,
+		MimeType: mimeType,
 	}
-	dbName := remaining[hostEnd+slashIndex+1:]
-	if qIndex := strings.Index(dbName, "?"); qIndex != -1 {
-		dbName = dbName[:qIndex]
+	if cfg.Method == job.MySQLMethodBinlog {
+		if output.State, err = json.Marshal(next); err != nil {
+			return nil, fmt.Errorf("failed to encode binary log position: %w", err)
+		}
 	}
+	return output, nil
+}
 
-	filename := fmt.Sprintf("%s.sql", input.Job.ID)
-	tempFilePath := filepath.Join(a.Config.TempDir, filename)
+// mySQLDump runs mysqldump for the databases of the job and writes the dump to w
+func (a *Activities) mySQLDump(ctx context.Context, jobId string, cfg *job.MySQLConfig, w io.Writer) error {
+	logger := activity.GetLogger(ctx)
 
-	file, err := os.Create(tempFilePath)
+	conn, err := parseMySQLConnectionString(cfg.ConnectionString)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+		return err
+	}
+	if conn.dbName == "" && !cfg.MultiDatabase() {
+		return temporal.NewNonRetryableApplicationError(
+			"the connection string has no database and neither databases nor all_databases is set", "InvalidConfig", nil)
 	}
 
-	args := []string{"-h", host, "-P", port, "-u", username}
-	if password != "" {
-		args = append(args, fmt.Sprintf("-p%s", password))
+	args, cleanup, err := a.mySQLArgs(jobId, conn, mySQLDumpArgs(cfg, conn.dbName)...)
+	if err != nil {
+		return err
 	}
-	args = append(args, "--single-transaction", "--quick", "--lock-tables=false", "--routines", "--triggers", dbName)
+	defer cleanup()
 
-	cmd := exec.CommandContext(ctx, "mysqldump", args...)
-	hash := sha256.New()
-	cmd.Stdout = io.MultiWriter(file, hash)
+	cmd := exec.CommandContext(ctx, a.mySQLTool("mysqldump"), args...)
+	cmd.Stdout = w
 
 	var stderr strings.Builder
 	cmd.Stderr = &stderr
 
-	logger.Info("Executing mysqldump", "host", host, "port", port, "db", dbName)
+	logger.Info("Executing mysqldump", "network", conn.network, "addr", conn.addr, "db", conn.dbName,
+		"databases", cfg.Databases, "allDatabases", cfg.AllDatabases)
 
 	if err := cmd.Run(); err != nil {
-		file.Close()
-		return nil, fmt.Errorf("mysqldump failed: %w. Stderr: %s", err, stderr.String())
+		return fmt.Errorf("mysqldump failed: %w. Stderr: %s", err, stderr.String())
 	}
-	file.Close()
+	return nil
+}
 
-	fi, err := os.Stat(tempFilePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat temp file: %w", err)
+// mySQLDumpArgs returns the mysqldump arguments for the options of cfg, after the connection
+// options. Excluded tables without a database are taken from dbName.
+func mySQLDumpArgs(cfg *job.MySQLConfig, dbName string) []string {
+	args := []string{"--single-transaction", "--quick", "--lock-tables=false", "--routines", "--triggers"}
+	if cfg.HexBlob {
+		args = append(args, "--hex-blob")
 	}
+	if cfg.Method == job.MySQLMethodBinlog {
+		// Writes the binary log position of the dump as a comment, the next backup continues there
+		args = append(args, "--source-data=2")
+	}
+	if cfg.SetGTIDPurged != "" {
+		args = append(args, "--set-gtid-purged="+strings.ToUpper(cfg.SetGTIDPurged))
+	}
+	for _, table := range cfg.ExcludeTables {
+		if !strings.Contains(table, ".") {
+			table = dbName + "." + table
+		}
+		args = append(args, "--ignore-table="+table)
+	}
+	switch {
+	case cfg.AllDatabases:
+		args = append(args, "--all-databases")
+	case len(cfg.Databases) > 0:
+		args = append(append(args, "--databases"), cfg.Databases...)
+	default:
+		args = append(args, dbName)
+	}
+	return args
+}
 
-	logger.Info("MySQLDumpActivity completed", "filePath", tempFilePath, "size", fi.Size())
-
-	return &DownloadActivityOutput{
-		FilePath: tempFilePath,
-		Size:     fi.Size(),
-		Checksum: fmt.Sprintf("%x", hash.Sum(nil)),
-		Name:     filename,
-		MimeType: "application/sql",
-	}, nil
+// streamMySQL is the streaming source of MySQL jobs
+func (a *Activities) streamMySQL(ctx context.Context, j *job.Job, w io.Writer) (string, string, error) {
+	cfg, err := job.LoadAs[*job.MySQLConfig](*j)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load MySQL config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return "", "", fmt.Errorf("invalid MySQL config: %w", err)
+	}
+	return fmt.Sprintf("%s.sql", j.ID), "application/sql", a.mySQLDump(ctx, j.ID, cfg, w)
 }
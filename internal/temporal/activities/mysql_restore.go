@@ -0,0 +1,77 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"fmt"
+	"os/exec"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// MySQLRestoreActivity replays a mysqldump into the job's database or the restore target. The
+// dumps drop and recreate each table, so existing tables of the same name are replaced.
+func (a *Activities) MySQLRestoreActivity(ctx context.Context, input RestoreActivityInput) (*RestoreActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("MySQLRestoreActivity started", "jobId", input.Job.ID)
+
+	cfg, err := job.LoadAs[*job.MySQLConfig](*input.Job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MySQL config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid MySQL config: %w", err)
+	}
+
+	if isMySQLBinlogArchive(input.FilePath) {
+		// Replaying binary logs needs the full dump restored first and the positions of the manifest
+		return nil, temporal.NewNonRetryableApplicationError(
+			"binlog archives must be replayed with mysqlbinlog by hand", "RestoreUnsupported", nil)
+	}
+
+	connStr := cfg.ConnectionString
+	if input.Target.ConnectionString != "" {
+		connStr = input.Target.ConnectionString
+	}
+	conn, err := parseMySQLConnectionString(connStr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MultiDatabase() {
+		// The dump selects its databases itself
+		if input.Target.Database != "" {
+			return nil, temporal.NewNonRetryableApplicationError(
+				"dumps of several databases cannot be restored into another database", "InvalidRestoreTarget", nil)
+		}
+		conn.dbName = ""
+	} else if input.Target.Database != "" {
+		conn.dbName = input.Target.Database
+	}
+
+	logger.Info("Executing mysql", "network", conn.network, "addr", conn.addr, "db", conn.dbName)
+
+	if err := a.mySQLRestore(ctx, input.Job.ID, conn, input.FilePath); err != nil {
+		return nil, err
+	}
+
+	logger.Info("MySQLRestoreActivity completed", "db", conn.dbName)
+	return &RestoreActivityOutput{}, nil
+}
+
+// mySQLRestore replays the dump at path into the database of conn, or the databases the dump
+// selects when conn has none
+func (a *Activities) mySQLRestore(ctx context.Context, jobId string, conn mySQLConn, path string) error {
+	var database []string
+	if conn.dbName != "" {
+		database = []string{conn.dbName}
+	}
+	args, cleanup, err := a.mySQLArgs(jobId, conn, database...)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cmd := exec.CommandContext(ctx, a.mySQLTool("mysql"), args...)
+	return a.restoreFromFile(ctx, cmd, path)
+}
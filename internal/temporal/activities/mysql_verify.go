@@ -0,0 +1,108 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// mySQLDefaultVerifyChecks report the restored tables when the job defines no checks
+var mySQLDefaultVerifyChecks = []job.VerifyCheck{{
+	Name:  "tables",
+	Query: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE()",
+}}
+
+// MySQLVerifyActivity restore drills a MySQL dump: the scratch database is recreated, the dump
+// is replayed into it, the checks are run and the scratch database is dropped again
+func (a *Activities) MySQLVerifyActivity(ctx context.Context, input VerifyActivityInput) (*Verification, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("MySQLVerifyActivity started", "jobId", input.Job.ID)
+	start := time.Now()
+
+	cfg, err := job.LoadAs[*job.MySQLConfig](*input.Job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MySQL config: %w", err)
+	}
+	if cfg.Verify == nil {
+		return nil, temporal.NewNonRetryableApplicationError("job has no verify config", "InvalidVerifyConfig", nil)
+	}
+	if cfg.MultiDatabase() {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"restore drills are only supported for dumps of a single database", "InvalidVerifyConfig", nil)
+	}
+	if isMySQLBinlogArchive(input.FilePath) {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"binlog archives cannot be restore drilled, only the full dumps of the job", "InvalidVerifyConfig", nil)
+	}
+	if err := cfg.Verify.Validate(); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("invalid verify config: %v", err), "InvalidVerifyConfig", err)
+	}
+
+	source, err := parseMySQLConnectionString(cfg.ConnectionString)
+	if err != nil {
+		return nil, err
+	}
+	conn := source
+	if cfg.Verify.ConnectionString != "" {
+		if conn, err = parseMySQLConnectionString(cfg.Verify.ConnectionString); err != nil {
+			return nil, err
+		}
+	}
+	if conn.network == source.network && conn.addr == source.addr && cfg.Verify.Database == source.dbName {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"the scratch database of the verify config is the job's database", "InvalidVerifyConfig", nil)
+	}
+	conn.dbName = cfg.Verify.Database
+	name := mySQLQuoteIdent(conn.dbName)
+
+	// One option file serves every mysql call of the drill
+	client := a.mySQLTool("mysql")
+	args, cleanup, err := a.mySQLArgs(input.Job.ID, conn)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := runSQL(ctx, client, append(args, "-e",
+		fmt.Sprintf("DROP DATABASE IF EXISTS %s; CREATE DATABASE %s", name, name))...); err != nil {
+		return nil, fmt.Errorf("failed to create scratch database: %w", err)
+	}
+	defer func() {
+		dropCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), verifyCleanupTimeout)
+		defer cancel()
+		if err := runSQL(dropCtx, client, append(args, "-e", "DROP DATABASE IF EXISTS "+name)...); err != nil {
+			logger.Warn("Failed to drop scratch database", "database", conn.dbName, "error", err)
+		}
+	}()
+
+	if err := a.mySQLRestore(ctx, input.Job.ID, conn, input.FilePath); err != nil {
+		return nil, err
+	}
+
+	checks := cfg.Verify.Checks
+	if len(checks) == 0 {
+		checks = mySQLDefaultVerifyChecks
+	}
+	v := runVerifyChecks(checks, start, func(check job.VerifyCheck) (int64, error) {
+		return queryCount(ctx, client, append(args, "-N", "-B", "-e", check.Query, conn.dbName)...)
+	})
+
+	logger.Info("MySQLVerifyActivity completed", "verified", v.Verified, "checks", len(v.Checks))
+	return v, nil
+}
+
+// mySQLQuoteIdent quotes a MySQL identifier
+func mySQLQuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// mySQLQuoteLiteral quotes a MySQL string literal
+func mySQLQuoteLiteral(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(s) + "'"
+}
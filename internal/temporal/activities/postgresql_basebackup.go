@@ -0,0 +1,126 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// pgBaseBackupExtension names the archives of base backups, which hold the base.tar,
+	// pg_wal.tar, tablespace tars and backup_manifest written by pg_basebackup
+	pgBaseBackupExtension = ".basebackup.tar"
+	// pgWALExtension names the archives of WAL segments
+	pgWALExtension = ".wal.tar"
+	// pgWALMinVersion is the server_version_num from which pg_receivewal starts at the restart
+	// position of the slot; older servers start at the current position and skip the WAL
+	// written since the previous run
+	pgWALMinVersion = 150000
+)
+
+// postgreSQLBaseBackup takes a physical backup of the cluster with pg_basebackup in the tar
+// format, streaming the WAL written during the backup, and tars its output into archivePath.
+// With a slot the WAL after the backup is retained for the WAL archive runs.
+func (a *Activities) postgreSQLBaseBackup(ctx context.Context, jobId string, cfg *job.PostgreSQLConfig, archivePath string) error {
+	if cfg.Slot != "" {
+		if err := postgreSQLEnsureSlot(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	tempDir, err := os.MkdirTemp(a.Config.TempDir, jobId+"-pg-basebackup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	args := []string{"--dbname=" + cfg.ConnectionString, "--pgdata=" + tempDir,
+		"--format=tar", "--wal-method=stream", "--checkpoint=fast", "--no-password"}
+	if cfg.Slot != "" {
+		args = append(args, "--slot="+cfg.Slot)
+	}
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "pg_basebackup", args...), tempDir); err != nil {
+		return fmt.Errorf("pg_basebackup failed: %w, output: %s", err, string(output))
+	}
+
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-cf", archivePath, "-C", tempDir, "."), archivePath); err != nil {
+		return fmt.Errorf("failed to create archive: %w, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// postgreSQLArchiveWAL receives the WAL retained by the job's slot up to the current position
+// with pg_receivewal and tars the segments into archivePath. The slot is advanced to that
+// position, so the next run continues where this one stopped. The last segment is usually
+// incomplete and named ".partial"; the next run receives it again in full.
+func (a *Activities) postgreSQLArchiveWAL(ctx context.Context, jobId string, cfg *job.PostgreSQLConfig, archivePath string) (string, error) {
+	version, err := queryCount(ctx, "psql", cfg.ConnectionString, "-X", "-A", "-t", "-c", "SHOW server_version_num")
+	if err != nil {
+		return "", fmt.Errorf("failed to read server version: %w", err)
+	}
+	if err := checkPgWALVersion(version); err != nil {
+		return "", err
+	}
+
+	if err := postgreSQLEnsureSlot(ctx, cfg); err != nil {
+		return "", err
+	}
+
+	lsn, err := querySQL(ctx, "psql", cfg.ConnectionString, "-X", "-A", "-t", "-c", "SELECT pg_current_wal_lsn()")
+	if err != nil {
+		return "", fmt.Errorf("failed to read current WAL position: %w", err)
+	}
+	lsn = strings.TrimSpace(lsn)
+
+	tempDir, err := os.MkdirTemp(a.Config.TempDir, jobId+"-pg-wal-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// An empty directory makes pg_receivewal start at the restart position of the slot
+	args := []string{"--dbname=" + cfg.ConnectionString, "--slot=" + cfg.Slot, "--directory=" + tempDir,
+		"--endpos=" + lsn, "--no-loop", "--no-password"}
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "pg_receivewal", args...), tempDir); err != nil {
+		return "", fmt.Errorf("pg_receivewal failed: %w, output: %s", err, string(output))
+	}
+
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-cf", archivePath, "-C", tempDir, "."), archivePath); err != nil {
+		return "", fmt.Errorf("failed to create archive: %w, output: %s", err, string(output))
+	}
+	return lsn, nil
+}
+
+// checkPgWALVersion refuses WAL archiving on servers before PostgreSQL 15, where it would
+// silently miss WAL
+func checkPgWALVersion(version int64) error {
+	if version < pgWALMinVersion {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("WAL archiving needs PostgreSQL 15 or later, the server is version %d", version),
+			"UnsupportedServer", nil)
+	}
+	return nil
+}
+
+// postgreSQLEnsureSlot creates the job's physical replication slot when it does not exist.
+// The slot reserves WAL right away, so nothing written after it was created is lost.
+func postgreSQLEnsureSlot(ctx context.Context, cfg *job.PostgreSQLConfig) error {
+	if err := runSQL(ctx, "pg_receivewal", "--dbname="+cfg.ConnectionString, "--slot="+cfg.Slot,
+		"--create-slot", "--if-not-exists", "--no-password"); err != nil {
+		return fmt.Errorf("failed to create replication slot %q: %w", cfg.Slot, err)
+	}
+	return nil
+}
+
+// postgreSQLPhysicalArchive reports whether a backup file is a base backup or WAL archive by
+// its name, which keeps a gzip layer on download
+func postgreSQLPhysicalArchive(filePath string) bool {
+	name := strings.TrimSuffix(filepath.Base(filePath), ".gz")
+	return strings.HasSuffix(name, pgBaseBackupExtension) || strings.HasSuffix(name, pgWALExtension)
+}
@@ -0,0 +1,267 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// pgClusterExtension names the archives of cluster backups
+	pgClusterExtension = ".cluster.tar"
+	// pgClusterGlobals and pgClusterManifestFile are the root files of a cluster archive
+	pgClusterGlobals      = "globals.sql"
+	pgClusterManifestFile = "manifest.json"
+)
+
+// pgClusterDatabaseQuery lists the databases a cluster backup can dump with their size
+const pgClusterDatabaseQuery = "SELECT datname, pg_database_size(datname) FROM pg_database " +
+	"WHERE datallowconn AND NOT datistemplate ORDER BY datname"
+
+// pgUnsafeFileChars are replaced in the file names of database dumps
+var pgUnsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// pgClusterManifest describes a cluster archive, it is written to its manifest.json
+type pgClusterManifest struct {
+	Version       int       `json:"version"`
+	ServerVersion string    `json:"server_version"`
+	Format        string    `json:"format"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Globals is the pg_dumpall --globals-only output with the roles and tablespaces
+	Globals   string              `json:"globals"`
+	Databases []pgClusterDatabase `json:"databases"`
+}
+
+type pgClusterDatabase struct {
+	Name string `json:"name"`
+	// File is the dump of the database relative to the archive root, a directory for the
+	// directory format
+	File string `json:"file,omitempty"`
+	// Size is the size of the database on the server, DumpSize the size of its dump
+	Size     int64 `json:"size"`
+	DumpSize int64 `json:"dump_size"`
+}
+
+// postgreSQLDumpCluster dumps the globals and each selected database of the server into a temp
+// dir and tars it into archivePath together with a manifest
+func (a *Activities) postgreSQLDumpCluster(ctx context.Context, jobId string, cfg *job.PostgreSQLConfig, archivePath string) (*pgClusterManifest, error) {
+	databases, err := postgreSQLClusterDatabases(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(databases) == 0 {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"no database on the server matches the databases of the job", "NoDatabases", nil)
+	}
+	version, err := querySQL(ctx, "psql", cfg.ConnectionString, "-X", "-A", "-t", "-c", "SHOW server_version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server version: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp(a.Config.TempDir, jobId+"-pg-cluster-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+	if err := os.Mkdir(filepath.Join(tempDir, "databases"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	format := cfg.Format
+	if format == "" {
+		format = job.PostgreSQLFormatPlain
+	}
+	manifest := &pgClusterManifest{
+		Version:       1,
+		ServerVersion: strings.TrimSpace(version),
+		Format:        format,
+		CreatedAt:     time.Now().UTC(),
+		Globals:       pgClusterGlobals,
+	}
+
+	stop := a.newProgressReporter(ctx, 0).Watch(tempDir)
+	err = func() error {
+		defer stop()
+
+		if err := runSQL(ctx, "pg_dumpall", "--dbname="+cfg.ConnectionString, "--globals-only",
+			"--file="+filepath.Join(tempDir, pgClusterGlobals)); err != nil {
+			return err
+		}
+
+		// Every format can be written with --file; directory dumps stay directories in the archive
+		extension := postgreSQLFormat(cfg).Extension
+		if cfg.Format == job.PostgreSQLFormatDirectory {
+			extension = ".dir"
+		}
+		for i, db := range databases {
+			db.File = path.Join("databases", fmt.Sprintf("%02d-%s%s", i+1, pgUnsafeFileChars.ReplaceAllString(db.Name, "_"), extension))
+			dumpPath := filepath.Join(tempDir, filepath.FromSlash(db.File))
+
+			dbCfg := *cfg
+			dbCfg.ConnectionString = postgreSQLWithDatabase(cfg.ConnectionString, db.Name)
+			if err := runSQL(ctx, "pg_dump", append(postgreSQLDumpArgs(&dbCfg), "--file="+dumpPath)...); err != nil {
+				return fmt.Errorf("failed to dump database %q: %w", db.Name, err)
+			}
+			db.DumpSize = pathSize(dumpPath)
+			manifest.Databases = append(manifest.Databases, db)
+		}
+		return nil
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, pgClusterManifestFile), data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-cf", archivePath, "-C", tempDir, "."), archivePath); err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w, output: %s", err, string(output))
+	}
+	return manifest, nil
+}
+
+// postgreSQLClusterDatabases returns the databases of the server selected by the config
+func postgreSQLClusterDatabases(ctx context.Context, cfg *job.PostgreSQLConfig) ([]pgClusterDatabase, error) {
+	out, err := querySQL(ctx, "psql", cfg.ConnectionString, "-X", "-A", "-t", "-F", "\t", "-c", pgClusterDatabaseQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	all, err := parsePgDatabases(out)
+	if err != nil {
+		return nil, err
+	}
+	var selected []pgClusterDatabase
+	for _, db := range all {
+		if pgDatabaseSelected(db.Name, cfg.Databases, cfg.ExcludeDatabases) {
+			selected = append(selected, db)
+		}
+	}
+	return selected, nil
+}
+
+// parsePgDatabases parses the tab separated output of pgClusterDatabaseQuery
+func parsePgDatabases(out string) ([]pgClusterDatabase, error) {
+	var databases []pgClusterDatabase
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		name, size, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("unexpected database list line: %q", line)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected database size in line: %q", line)
+		}
+		databases = append(databases, pgClusterDatabase{Name: name, Size: n})
+	}
+	return databases, nil
+}
+
+// pgDatabaseSelected reports whether a database matches one of the include patterns, or there
+// are none, and none of the exclude patterns
+func pgDatabaseSelected(name string, include, exclude []string) bool {
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(include) == 0 || matches(include)) && !matches(exclude)
+}
+
+// isPgClusterArchive reports whether a backup file is a cluster archive by its name, which
+// keeps a gzip layer on download
+func isPgClusterArchive(filePath string) bool {
+	name := filepath.Base(filePath)
+	return strings.HasSuffix(name, pgClusterExtension) || strings.HasSuffix(name, pgClusterExtension+".gz")
+}
+
+// postgreSQLRestoreCluster restores a cluster archive into the server of connStr: the globals,
+// then each database of the manifest into the database of the same name, which is created when
+// missing. When source is set only that database is restored, without the globals, into target
+// when that is set too. It returns the number of databases restored.
+func (a *Activities) postgreSQLRestoreCluster(ctx context.Context, connStr, archivePath, source, target string, clean bool, jobs int) (int64, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(archivePath), filepath.Base(archivePath)+"-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-xf", archivePath, "-C", dir), dir); err != nil {
+		return 0, fmt.Errorf("failed to extract archive: %w, output: %s", err, string(output))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, pgClusterManifestFile))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest pgClusterManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return 0, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("failed to decode manifest: %v", err), "InvalidBackup", err)
+	}
+
+	admin := postgreSQLWithDatabase(connStr, "postgres")
+	if source == "" {
+		// Without ON_ERROR_STOP: CREATE ROLE fails for existing roles, the ALTER ROLE after it
+		// still applies the attributes of the backup
+		if err := runSQL(ctx, "psql", admin, "-X", "-q", "-f", filepath.Join(dir, manifest.Globals)); err != nil {
+			return 0, fmt.Errorf("failed to restore globals: %w", err)
+		}
+	}
+
+	var restored int64
+	for _, db := range manifest.Databases {
+		if source != "" && db.Name != source {
+			continue
+		}
+		name := db.Name
+		if target != "" {
+			name = target
+		}
+		exists, err := queryCount(ctx, "psql", admin, "-X", "-A", "-t", "-c",
+			"SELECT count(*) FROM pg_database WHERE datname = "+pgQuoteLiteral(name))
+		if err != nil {
+			return restored, err
+		}
+		if exists == 0 {
+			if err := runSQL(ctx, "psql", admin, "-X", "-q", "-c", "CREATE DATABASE "+pgQuoteIdent(name)); err != nil {
+				return restored, fmt.Errorf("failed to create database %q: %w", name, err)
+			}
+		}
+		if _, err := a.postgreSQLRestore(ctx, postgreSQLWithDatabase(connStr, name),
+			filepath.Join(dir, filepath.FromSlash(db.File)), clean, jobs); err != nil {
+			return restored, fmt.Errorf("failed to restore database %q: %w", name, err)
+		}
+		restored++
+	}
+	if source != "" && restored == 0 {
+		return 0, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("database %q is not in the backup", source), "InvalidRestoreTarget", nil)
+	}
+	return restored, nil
+}
+
+// pgQuoteLiteral quotes a PostgreSQL string literal
+func pgQuoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,68 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestParsePgDatabases(t *testing.T) {
+	databases, err := parsePgDatabases("app\t8033011\npostgres\t7508515\nmy db\t0\n")
+	require.NoError(t, err)
+	assert.Equal(t, []pgClusterDatabase{
+		{Name: "app", Size: 8033011},
+		{Name: "postgres", Size: 7508515},
+		{Name: "my db", Size: 0},
+	}, databases)
+
+	_, err = parsePgDatabases("app|8033011\n")
+	assert.Error(t, err)
+}
+
+func TestPgDatabaseSelected(t *testing.T) {
+	assert.True(t, pgDatabaseSelected("app", nil, nil))
+	assert.True(t, pgDatabaseSelected("app_eu", []string{"app_*"}, nil))
+	assert.False(t, pgDatabaseSelected("billing", []string{"app_*"}, nil))
+	assert.False(t, pgDatabaseSelected("app_test", []string{"app_*"}, []string{"*_test"}))
+	assert.False(t, pgDatabaseSelected("postgres", nil, []string{"postgres"}))
+}
+
+func TestIsPgClusterArchive(t *testing.T) {
+	assert.True(t, isPgClusterArchive("/tmp/agent/job-1-restore-job-1.cluster.tar"))
+	assert.True(t, isPgClusterArchive("/tmp/agent/job-1-restore-job-1.cluster.tar.gz"))
+	assert.False(t, isPgClusterArchive("/tmp/agent/job-1-restore-job-1.tar"))
+}
+
+func TestPostgreSQLRestoreClusterTarget(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	acts := &Activities{}
+	env.RegisterActivity(acts.PostgreSQLRestoreActivity)
+
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderPostgreSQL,
+		Config: &job.PostgreSQLConfig{ConnectionString: "postgres://db/app"}}
+	tests := []struct {
+		name, filePath string
+		target         RestoreTarget
+		want           string
+	}{
+		// database names the database restored into, it does not select one of the archive
+		{"database without source", "/tmp/agent/job-1.cluster.tar", RestoreTarget{Database: "app_copy"}, "set source_database"},
+		{"source of a dump", "/tmp/agent/job-1.dump", RestoreTarget{SourceDatabase: "app"}, "only applies to cluster archives"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.ExecuteActivity(acts.PostgreSQLRestoreActivity,
+				RestoreActivityInput{Job: j, Target: tt.target, FilePath: tt.filePath})
+			require.Error(t, err)
+			assert.ErrorContains(t, err, tt.want)
+			var appErr *temporal.ApplicationError
+			require.ErrorAs(t, err, &appErr)
+			assert.Equal(t, "InvalidRestoreTarget", appErr.Type())
+		})
+	}
+}
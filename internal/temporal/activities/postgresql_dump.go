@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"go.temporal.io/sdk/activity"
 )
@@ -17,6 +18,31 @@ type PostgreSQLDumpActivityInput struct {
 	Job *job.Job `json:"job"`
 }
 
+// pgDumpFormat describes the artifact of a pg_dump output format
+type pgDumpFormat struct {
+	// Flag is the value of pg_dump --format
+	Flag      string
+	Extension string
+	MimeType  string
+}
+
+// pgDumpFormats holds the pg_dump formats by job.PostgreSQLConfig Format. Directory dumps are
+// tarred into one file; ".dir.tar" tells them apart from tar format archives on restore.
+var pgDumpFormats = map[string]pgDumpFormat{
+	job.PostgreSQLFormatPlain:     {Flag: "p", Extension: ".sql", MimeType: "application/sql"},
+	job.PostgreSQLFormatCustom:    {Flag: "c", Extension: ".dump", MimeType: "application/octet-stream"},
+	job.PostgreSQLFormatTar:       {Flag: "t", Extension: ".tar", MimeType: "application/x-tar"},
+	job.PostgreSQLFormatDirectory: {Flag: "d", Extension: ".dir.tar", MimeType: "application/x-tar"},
+}
+
+// postgreSQLFormat returns the dump format of a config, plain when none is set
+func postgreSQLFormat(cfg *job.PostgreSQLConfig) pgDumpFormat {
+	if cfg.Format == "" {
+		return pgDumpFormats[job.PostgreSQLFormatPlain]
+	}
+	return pgDumpFormats[cfg.Format]
+}
+
 func (a *Activities) PostgreSQLDumpActivity(ctx context.Context, input PostgreSQLDumpActivityInput) (*DownloadActivityOutput, error) {
 	logger := activity.GetLogger(ctx)
 	logger.Info("PostgreSQLDumpActivity started", "jobId", input.Job.ID)
@@ -29,38 +55,165 @@ func (a *Activities) PostgreSQLDumpActivity(ctx context.Context, input PostgreSQ
 		return nil, fmt.Errorf("invalid PostgreSQL config: %w", err)
 	}
 
-	filename := fmt.Sprintf("%s.sql", input.Job.ID)
+	format := postgreSQLFormat(cfg)
+	filename := input.Job.ID + format.Extension
+	mimeType := format.MimeType
+	switch {
+	case cfg.Method == job.PostgreSQLMethodBaseBackup:
+		filename, mimeType = input.Job.ID+pgBaseBackupExtension, "application/x-tar"
+	case cfg.Method == job.PostgreSQLMethodWAL:
+		filename, mimeType = input.Job.ID+pgWALExtension, "application/x-tar"
+	case cfg.Cluster:
+		filename, mimeType = input.Job.ID+pgClusterExtension, "application/x-tar"
+	}
 	tempFilePath := filepath.Join(a.Config.TempDir, filename)
 
-	file, err := os.Create(tempFilePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	// Everything but a dump to a single file is written to a temp dir by the tools and tarred
+	var archive func() error
+	switch {
+	case cfg.Method == job.PostgreSQLMethodBaseBackup:
+		archive = func() error { return a.postgreSQLBaseBackup(ctx, input.Job.ID, cfg, tempFilePath) }
+	case cfg.Method == job.PostgreSQLMethodWAL:
+		archive = func() error {
+			lsn, err := a.postgreSQLArchiveWAL(ctx, input.Job.ID, cfg, tempFilePath)
+			if err == nil {
+				logger.Info("Archived PostgreSQL WAL", "slot", cfg.Slot, "endLsn", lsn)
+			}
+			return err
+		}
+	case cfg.Cluster:
+		archive = func() error {
+			manifest, err := a.postgreSQLDumpCluster(ctx, input.Job.ID, cfg, tempFilePath)
+			if err == nil {
+				logger.Info("Dumped PostgreSQL cluster", "serverVersion", manifest.ServerVersion, "databases", len(manifest.Databases))
+			}
+			return err
+		}
+	case cfg.Format == job.PostgreSQLFormatDirectory:
+		archive = func() error { return a.postgreSQLDumpDirectory(ctx, input.Job.ID, cfg, tempFilePath) }
 	}
 
-	args := []string{cfg.ConnectionString, "--no-owner", "--no-acl"}
-
-	cmd := exec.CommandContext(ctx, "pg_dump", args...)
 	hash := sha256.New()
-	cmd.Stdout = io.MultiWriter(file, hash)
-
-	if err := cmd.Run(); err != nil {
+	if archive != nil {
+		if err := archive(); err != nil {
+			return nil, err
+		}
+		file, err := os.Open(tempFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive: %w", err)
+		}
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate hash: %w", err)
+		}
+	} else {
+		file, err := os.Create(tempFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		progress := a.newProgressReporter(ctx, 0)
+		stop := progress.Keepalive()
+		err = a.postgreSQLDump(ctx, cfg, io.MultiWriter(file, hash, progress))
+		stop()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
 		file.Close()
-		return nil, fmt.Errorf("pg_dump failed: %w", err)
+		progress.Done()
 	}
-	file.Close()
 
 	fi, err := os.Stat(tempFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat temp file: %w", err)
 	}
 
-	logger.Info("PostgreSQLDumpActivity completed", "filePath", tempFilePath, "size", fi.Size())
+	logger.Info("PostgreSQLDumpActivity completed", "filePath", tempFilePath, "size", fi.Size(), "format", format.Flag)
 
 	return &DownloadActivityOutput{
 		FilePath: tempFilePath,
 		Size:     fi.Size(),
 		Checksum: fmt.Sprintf("%x", hash.Sum(nil)),
 		Name:     filename,
-		MimeType: "application/sql",
+		MimeType: mimeType,
 	}, nil
 }
+
+// postgreSQLDumpArgs returns the pg_dump arguments for a config, without an output file
+func postgreSQLDumpArgs(cfg *job.PostgreSQLConfig) []string {
+	args := []string{cfg.ConnectionString, "--no-owner", "--no-acl", "--format=" + postgreSQLFormat(cfg).Flag}
+	if cfg.SchemaOnly {
+		args = append(args, "--schema-only")
+	}
+	if cfg.DataOnly {
+		args = append(args, "--data-only")
+	}
+	for _, t := range cfg.Tables {
+		args = append(args, "--table="+t)
+	}
+	for _, t := range cfg.ExcludeTables {
+		args = append(args, "--exclude-table="+t)
+	}
+	for _, s := range cfg.Schemas {
+		args = append(args, "--schema="+s)
+	}
+	for _, s := range cfg.ExcludeSchemas {
+		args = append(args, "--exclude-schema="+s)
+	}
+	if cfg.Jobs > 1 {
+		args = append(args, fmt.Sprintf("--jobs=%d", cfg.Jobs))
+	}
+	return args
+}
+
+// postgreSQLDump runs pg_dump and writes the dump to w. The directory format cannot be
+// written to a stream, see postgreSQLDumpDirectory.
+func (a *Activities) postgreSQLDump(ctx context.Context, cfg *job.PostgreSQLConfig, w io.Writer) error {
+	cmd := exec.CommandContext(ctx, "pg_dump", postgreSQLDumpArgs(cfg)...)
+	cmd.Stdout = w
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_dump failed: %w. Stderr: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// postgreSQLDumpDirectory dumps in the directory format into a temp dir and tars its contents
+// into archivePath. The data files of the directory format are compressed by pg_dump, so the
+// tar is not.
+func (a *Activities) postgreSQLDumpDirectory(ctx context.Context, jobId string, cfg *job.PostgreSQLConfig, archivePath string) error {
+	tempDir, err := os.MkdirTemp(a.Config.TempDir, jobId+"-pg-dump-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// pg_dump creates the directory itself and refuses one that is not empty
+	dumpDir := filepath.Join(tempDir, "dump")
+	args := append(postgreSQLDumpArgs(cfg), "--file="+dumpDir)
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "pg_dump", args...), dumpDir); err != nil {
+		return fmt.Errorf("pg_dump failed: %w, output: %s", err, string(output))
+	}
+
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-cf", archivePath, "-C", dumpDir, "."), archivePath); err != nil {
+		return fmt.Errorf("failed to create archive: %w, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// streamPostgreSQL is the streaming source of PostgreSQL jobs
+func (a *Activities) streamPostgreSQL(ctx context.Context, j *job.Job, w io.Writer) (string, string, error) {
+	cfg, err := job.LoadAs[*job.PostgreSQLConfig](*j)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load PostgreSQL config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return "", "", fmt.Errorf("invalid PostgreSQL config: %w", err)
+	}
+	format := postgreSQLFormat(cfg)
+	return j.ID + format.Extension, format.MimeType, a.postgreSQLDump(ctx, cfg, w)
+}
@@ -0,0 +1,59 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestPostgreSQLDumpArgs(t *testing.T) {
+	cfg := &job.PostgreSQLConfig{ConnectionString: "postgres://db/app"}
+	assert.Equal(t, []string{"postgres://db/app", "--no-owner", "--no-acl", "--format=p"}, postgreSQLDumpArgs(cfg))
+	assert.Equal(t, pgDumpFormat{Flag: "p", Extension: ".sql", MimeType: "application/sql"}, postgreSQLFormat(cfg))
+
+	cfg = &job.PostgreSQLConfig{
+		ConnectionString: "postgres://db/app",
+		Format:           job.PostgreSQLFormatDirectory,
+		DataOnly:         true,
+		Tables:           []string{"public.users", "public.orders"},
+		ExcludeTables:    []string{"public.audit_*"},
+		Schemas:          []string{"public"},
+		ExcludeSchemas:   []string{"tmp"},
+		Jobs:             4,
+	}
+	assert.Equal(t, []string{
+		"postgres://db/app", "--no-owner", "--no-acl", "--format=d", "--data-only",
+		"--table=public.users", "--table=public.orders", "--exclude-table=public.audit_*",
+		"--schema=public", "--exclude-schema=tmp", "--jobs=4",
+	}, postgreSQLDumpArgs(cfg))
+	assert.Equal(t, ".dir.tar", postgreSQLFormat(cfg).Extension)
+}
+
+func TestPostgreSQLMethods(t *testing.T) {
+	dump := &job.Job{Provider: job.JobProviderPostgreSQL, Config: &job.PostgreSQLConfig{ConnectionString: "postgres://db/app"}}
+	assert.True(t, SupportsStreaming(dump))
+
+	for _, method := range []string{job.PostgreSQLMethodBaseBackup, job.PostgreSQLMethodWAL} {
+		j := &job.Job{Provider: job.JobProviderPostgreSQL, Config: &job.PostgreSQLConfig{ConnectionString: "postgres://db/app", Method: method}}
+		assert.False(t, SupportsStreaming(j), method)
+	}
+
+	assert.True(t, postgreSQLPhysicalArchive("/tmp/agent/job-1-restore-job-1.basebackup.tar"))
+	assert.True(t, postgreSQLPhysicalArchive("/tmp/agent/job-1-restore-job-1.wal.tar.gz"))
+	assert.False(t, postgreSQLPhysicalArchive("/tmp/agent/job-1-restore-job-1.cluster.tar"))
+}
+
+func TestCheckPgWALVersion(t *testing.T) {
+	assert.NoError(t, checkPgWALVersion(150000))
+	assert.NoError(t, checkPgWALVersion(170002))
+
+	// pg_receivewal of older servers starts at the current position, not at the slot
+	err := checkPgWALVersion(140011)
+	var appErr *temporal.ApplicationError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "UnsupportedServer", appErr.Type())
+	assert.ErrorContains(t, err, "PostgreSQL 15")
+}
@@ -0,0 +1,185 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// pgCustomMagic starts every pg_dump archive in the custom format
+var pgCustomMagic = []byte("PGDMP")
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// PostgreSQLRestoreActivity restores a dump into the job's database or the restore target.
+// Plain SQL dumps are replayed with psql, archives in the other formats with pg_restore. Cluster
+// archives restore all their databases under their own names, or only the target's source
+// database, under its name or the target database.
+func (a *Activities) PostgreSQLRestoreActivity(ctx context.Context, input RestoreActivityInput) (*RestoreActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("PostgreSQLRestoreActivity started", "jobId", input.Job.ID)
+
+	cfg, err := job.LoadAs[*job.PostgreSQLConfig](*input.Job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PostgreSQL config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid PostgreSQL config: %w", err)
+	}
+
+	connStr := cfg.ConnectionString
+	if input.Target.ConnectionString != "" {
+		connStr = input.Target.ConnectionString
+	}
+
+	if postgreSQLPhysicalArchive(input.FilePath) {
+		// Physical backups are restored into a stopped server's data directory, out of reach of
+		// the agent's connection string
+		return nil, temporal.NewNonRetryableApplicationError(
+			"base backups and WAL archives must be restored into the data directory by hand",
+			"RestoreUnsupported", nil)
+	}
+
+	if isPgClusterArchive(input.FilePath) {
+		if input.Target.Database != "" && input.Target.SourceDatabase == "" {
+			return nil, temporal.NewNonRetryableApplicationError(
+				"a cluster archive restores several databases, set source_database to restore one of them into database",
+				"InvalidRestoreTarget", nil)
+		}
+		restored, err := a.postgreSQLRestoreCluster(ctx, connStr, input.FilePath, input.Target.SourceDatabase,
+			input.Target.Database, input.Target.Overwrite, cfg.Jobs)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("PostgreSQLRestoreActivity completed", "databases", restored)
+		return &RestoreActivityOutput{Items: restored}, nil
+	}
+
+	if input.Target.SourceDatabase != "" {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"source_database only applies to cluster archives", "InvalidRestoreTarget", nil)
+	}
+	if input.Target.Database != "" {
+		connStr = postgreSQLWithDatabase(connStr, input.Target.Database)
+	}
+
+	format, err := a.postgreSQLRestore(ctx, connStr, input.FilePath, input.Target.Overwrite, cfg.Jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("PostgreSQLRestoreActivity completed", "format", format)
+	return &RestoreActivityOutput{}, nil
+}
+
+// postgreSQLRestore restores the dump at path into the database of connStr and returns its
+// format. Plain dumps cannot drop what they create, so clean only applies to archives; jobs
+// restores directory archives in parallel.
+func (a *Activities) postgreSQLRestore(ctx context.Context, connStr, path string, clean bool, jobs int) (string, error) {
+	format, err := pgArchiveFormat(path)
+	if err != nil {
+		return "", err
+	}
+	if format == job.PostgreSQLFormatPlain {
+		cmd := exec.CommandContext(ctx, "psql", connStr, "-X", "-q", "-v", "ON_ERROR_STOP=1")
+		return format, a.restoreFromFile(ctx, cmd, path)
+	}
+
+	args := []string{"--no-owner", "--no-acl", "--exit-on-error", "--dbname", connStr,
+		"--format=" + pgDumpFormats[format].Flag}
+	if clean {
+		args = append(args, "--clean", "--if-exists")
+	}
+	if format != job.PostgreSQLFormatDirectory {
+		return format, a.restoreFromFile(ctx, exec.CommandContext(ctx, "pg_restore", args...), path)
+	}
+
+	// Directory dumps are tarred by PostgreSQLDumpActivity, except inside cluster archives;
+	// tar detects a kept gzip layer
+	dir := path
+	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+		if dir, err = os.MkdirTemp(filepath.Dir(path), filepath.Base(path)+"-*"); err != nil {
+			return "", fmt.Errorf("failed to create temp dir: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", "-xf", path, "-C", dir), dir); err != nil {
+			return "", fmt.Errorf("failed to extract archive: %w, output: %s", err, string(output))
+		}
+	}
+	if jobs > 1 {
+		args = append(args, fmt.Sprintf("--jobs=%d", jobs))
+	}
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "pg_restore", append(args, dir)...), dir); err != nil {
+		return "", fmt.Errorf("pg_restore failed: %w, output: %s", err, string(output))
+	}
+	return format, nil
+}
+
+// pgArchiveFormat returns the pg_dump format of the dump at path, which is a directory for the
+// directory dumps of a cluster archive. Tarred directory archives are told apart from tar format
+// archives by their ".dir.tar" name; a gzip layer is looked through,
+// as the gzip compression of tar archives is kept on download.
+func pgArchiveFormat(path string) (string, error) {
+	name := filepath.Base(path)
+	if strings.HasSuffix(name, ".dir.tar") || strings.HasSuffix(name, ".dir.tar.gz") {
+		return job.PostgreSQLFormatDirectory, nil
+	}
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return job.PostgreSQLFormatDirectory, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(r, head)
+	if bytes.HasPrefix(head[:n], gzipMagic) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return "", fmt.Errorf("failed to read backup file: %w", err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to read backup file: %w", err)
+		}
+		n, _ = io.ReadFull(zr, head)
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, pgCustomMagic):
+		return job.PostgreSQLFormatCustom, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return job.PostgreSQLFormatTar, nil
+	default:
+		// Shorter than any header, so an (empty) SQL file
+		return job.PostgreSQLFormatPlain, nil
+	}
+}
+
+// postgreSQLWithDatabase returns the connection string pointed at another database. URLs get
+// a new path; keyword/value strings get a dbname, which libpq takes over an earlier one.
+func postgreSQLWithDatabase(connStr, database string) string {
+	if u, err := url.Parse(connStr); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		u.Path = "/" + database
+		u.RawPath = ""
+		return u.String()
+	}
+	quoted := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(database)
+	return fmt.Sprintf("%s dbname='%s'", connStr, quoted)
+}
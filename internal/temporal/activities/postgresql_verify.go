@@ -0,0 +1,156 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// pgDefaultVerifyChecks report the restored tables when the job defines no checks
+var pgDefaultVerifyChecks = []job.VerifyCheck{{
+	Name:  "tables",
+	Query: "SELECT count(*) FROM information_schema.tables WHERE table_schema NOT IN ('pg_catalog', 'information_schema')",
+}}
+
+// pgConnParam matches a parameter of a keyword/value connection string
+var pgConnParam = regexp.MustCompile(`(\w+)\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+
+// PostgreSQLVerifyActivity restore drills a PostgreSQL dump: the scratch database is recreated
+// through the server's "postgres" database, the dump is restored into it, the checks are run and
+// the scratch database is dropped again. The connecting role needs the CREATEDB privilege.
+func (a *Activities) PostgreSQLVerifyActivity(ctx context.Context, input VerifyActivityInput) (*Verification, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("PostgreSQLVerifyActivity started", "jobId", input.Job.ID)
+	start := time.Now()
+
+	cfg, err := job.LoadAs[*job.PostgreSQLConfig](*input.Job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PostgreSQL config: %w", err)
+	}
+	if cfg.Verify == nil {
+		return nil, temporal.NewNonRetryableApplicationError("job has no verify config", "InvalidVerifyConfig", nil)
+	}
+	if cfg.Cluster || (cfg.Method != "" && cfg.Method != job.PostgreSQLMethodDump) {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"restore drills are only supported for dumps of a single database", "InvalidVerifyConfig", nil)
+	}
+	if err := cfg.Verify.Validate(); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("invalid verify config: %v", err), "InvalidVerifyConfig", err)
+	}
+
+	server := cfg.ConnectionString
+	if cfg.Verify.ConnectionString != "" {
+		server = cfg.Verify.ConnectionString
+	}
+	if postgreSQLSameDatabase(server, cfg.Verify.Database, cfg.ConnectionString) {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"the scratch database of the verify config is the job's database", "InvalidVerifyConfig", nil)
+	}
+	admin := postgreSQLWithDatabase(server, "postgres")
+	scratch := postgreSQLWithDatabase(server, cfg.Verify.Database)
+	name := pgQuoteIdent(cfg.Verify.Database)
+
+	// DROP DATABASE cannot run in a transaction, so each statement is sent on its own
+	if err := runSQL(ctx, "psql", admin, "-X", "-q", "-v", "ON_ERROR_STOP=1",
+		"-c", "DROP DATABASE IF EXISTS "+name, "-c", "CREATE DATABASE "+name); err != nil {
+		return nil, fmt.Errorf("failed to create scratch database: %w", err)
+	}
+	defer func() {
+		dropCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), verifyCleanupTimeout)
+		defer cancel()
+		if err := runSQL(dropCtx, "psql", admin, "-X", "-q", "-c", "DROP DATABASE IF EXISTS "+name); err != nil {
+			logger.Warn("Failed to drop scratch database", "database", cfg.Verify.Database, "error", err)
+		}
+	}()
+
+	if _, err := a.postgreSQLRestore(ctx, scratch, input.FilePath, false, cfg.Jobs); err != nil {
+		return nil, err
+	}
+
+	checks := cfg.Verify.Checks
+	if len(checks) == 0 {
+		checks = pgDefaultVerifyChecks
+	}
+	v := runVerifyChecks(checks, start, func(check job.VerifyCheck) (int64, error) {
+		return queryCount(ctx, "psql", scratch, "-X", "-A", "-t", "-v", "ON_ERROR_STOP=1", "-c", check.Query)
+	})
+
+	logger.Info("PostgreSQLVerifyActivity completed", "verified", v.Verified, "checks", len(v.Checks))
+	return v, nil
+}
+
+// postgreSQLDatabase returns the database of a connection string, or "" when it has none
+func postgreSQLDatabase(connStr string) string {
+	return postgreSQLParams(connStr)["dbname"]
+}
+
+// postgreSQLParams returns the host, port, dbname and user of a URL or keyword/value
+// connection string, as far as they are set
+func postgreSQLParams(connStr string) map[string]string {
+	params := map[string]string{}
+	if u, err := url.Parse(connStr); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		params["host"], params["port"] = u.Hostname(), u.Port()
+		params["dbname"] = strings.TrimPrefix(u.Path, "/")
+		params["user"] = u.User.Username()
+		// Query parameters take precedence over the URL parts
+		for _, key := range []string{"host", "port", "dbname", "user"} {
+			if value := u.Query().Get(key); value != "" {
+				params[key] = value
+			}
+		}
+		return params
+	}
+	// libpq takes the last value of a parameter
+	for _, match := range pgConnParam.FindAllStringSubmatch(connStr, -1) {
+		value := match[2]
+		if strings.HasPrefix(value, "'") {
+			value = strings.NewReplacer(`\\`, `\`, `\'`, `'`).Replace(value[1 : len(value)-1])
+		}
+		params[match[1]] = value
+	}
+	return params
+}
+
+// postgreSQLSameDatabase reports whether database on the server of connStr is the database of
+// jobConnStr. Unset hosts and ports are libpq's defaults: the local server on port 5432, and a
+// database named after the user.
+func postgreSQLSameDatabase(connStr, database, jobConnStr string) bool {
+	server, production := postgreSQLParams(connStr), postgreSQLParams(jobConnStr)
+	jobDatabase := production["dbname"]
+	if jobDatabase == "" {
+		jobDatabase = production["user"]
+	}
+	return pgHost(server["host"]) == pgHost(production["host"]) &&
+		pgPort(server["port"]) == pgPort(production["port"]) && database == jobDatabase
+}
+
+// pgHost normalizes a host for comparison, the loopback addresses and sockets are all local
+func pgHost(host string) string {
+	host = strings.ToLower(host)
+	switch {
+	case host == "", host == "127.0.0.1", host == "::1", strings.HasPrefix(host, "/"):
+		return "localhost"
+	}
+	return host
+}
+
+// pgPort returns the port, 5432 when it is unset
+func pgPort(port string) string {
+	if port == "" {
+		return "5432"
+	}
+	return port
+}
+
+// pgQuoteIdent quotes a PostgreSQL identifier
+func pgQuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,208 @@
+package activities
+
+import (
+	"agent/internal"
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+const (
+	// heartbeatInterval bounds how often progress is recorded as heartbeat details.
+	// It must stay well below the HeartbeatTimeout set in the workflow activity options.
+	heartbeatInterval = 10 * time.Second
+	// progressSignalInterval bounds how often progress is forwarded to the workflow,
+	// which keeps the number of signals in the workflow history small
+	progressSignalInterval = time.Minute
+)
+
+// Progress is recorded as heartbeat details by long running activities and forwarded to the
+// workflow, where it can be read through the progress query
+type Progress struct {
+	Activity  string    `json:"activity"`
+	Bytes     int64     `json:"bytes"`
+	Total     int64     `json:"total,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// progressReporter counts bytes processed by an activity and heartbeats them.
+// It is an io.Writer so it can be added to an io.MultiWriter or io.TeeReader.
+// Writes and updates to a nil reporter are discarded.
+type progressReporter struct {
+	ctx context.Context
+	a   *Activities
+
+	// details, when set, builds the heartbeat details from the current progress so an
+	// activity can checkpoint extra state that it reads back on retry
+	details func(Progress) any
+
+	mu         sync.Mutex
+	progress   Progress
+	lastBeat   time.Time
+	lastSignal time.Time
+}
+
+func (a *Activities) newProgressReporter(ctx context.Context, total int64) *progressReporter {
+	return &progressReporter{
+		ctx: ctx,
+		a:   a,
+		progress: Progress{
+			Activity: activity.GetInfo(ctx).ActivityType.Name,
+			Total:    total,
+		},
+	}
+}
+
+func (p *progressReporter) Write(b []byte) (int, error) {
+	p.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Add records n more bytes processed
+func (p *progressReporter) Add(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.progress.Bytes += n
+	p.mu.Unlock()
+	p.report(false, false)
+}
+
+// Set records the absolute number of bytes processed so far
+func (p *progressReporter) Set(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.progress.Bytes = n
+	p.mu.Unlock()
+	p.report(false, false)
+}
+
+// Done records the final byte count regardless of throttling
+func (p *progressReporter) Done() {
+	if p == nil {
+		return
+	}
+	p.report(true, true)
+}
+
+// Checkpoint records a heartbeat immediately so the details survive an activity retry
+func (p *progressReporter) Checkpoint() {
+	if p == nil {
+		return
+	}
+	p.report(true, false)
+}
+
+// Watch heartbeats the size of path (a file or a directory tree) until the returned stop
+// function is called. It is used for external tools that write their output themselves.
+func (p *progressReporter) Watch(path string) (stop func()) {
+	stopTicker := p.tick(func() { p.Set(pathSize(path)) })
+	return func() {
+		stopTicker()
+		p.Set(pathSize(path))
+	}
+}
+
+// Keepalive heartbeats the current progress every heartbeatInterval until the returned stop
+// function is called, so an activity keeps heartbeating while its output pauses, for example
+// while a tool builds indexes or a script computes before printing
+func (p *progressReporter) Keepalive() (stop func()) {
+	return p.tick(func() { p.report(false, false) })
+}
+
+// tick calls fn every heartbeatInterval until the returned stop function is first called
+func (p *progressReporter) tick(fn func()) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-p.ctx.Done():
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() { close(done) })
+		wg.Wait()
+	}
+}
+
+func (p *progressReporter) report(forceBeat, forceSignal bool) {
+	p.mu.Lock()
+	now := time.Now()
+	beat := forceBeat || now.Sub(p.lastBeat) >= heartbeatInterval
+	signal := forceSignal || now.Sub(p.lastSignal) >= progressSignalInterval
+	if beat {
+		p.lastBeat = now
+	}
+	if signal {
+		p.lastSignal = now
+	}
+	p.progress.UpdatedAt = now
+	progress := p.progress
+	p.mu.Unlock()
+
+	if beat {
+		var details any = progress
+		if p.details != nil {
+			details = p.details(progress)
+		}
+		activity.RecordHeartbeat(p.ctx, details)
+	}
+	if signal && p.a != nil && p.a.TemporalClient != nil {
+		info := activity.GetInfo(p.ctx)
+		if err := p.a.TemporalClient.SignalWorkflow(p.ctx, info.WorkflowExecution.ID, info.WorkflowExecution.RunID,
+			internal.SignalNameProgress, progress); err != nil {
+			activity.GetLogger(p.ctx).Debug("Failed to signal progress", "error", err)
+		}
+	}
+}
+
+// runWithProgress runs an external command to completion while heartbeating the size of the
+// path it writes to, and returns its combined output
+func (a *Activities) runWithProgress(ctx context.Context, cmd *exec.Cmd, path string) ([]byte, error) {
+	stop := a.newProgressReporter(ctx, 0).Watch(path)
+	defer stop()
+	return cmd.CombinedOutput()
+}
+
+// pathSize returns the size of a file, or the total size of the files below a directory
+func pathSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	if !fi.IsDir() {
+		return fi.Size()
+	}
+	var total int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
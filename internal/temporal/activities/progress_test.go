@@ -0,0 +1,20 @@
+package activities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNilProgressReporter(t *testing.T) {
+	var p *progressReporter
+	assert.NotPanics(t, func() {
+		n, err := p.Write([]byte("data"))
+		assert.Equal(t, 4, n)
+		assert.NoError(t, err)
+		p.Add(1)
+		p.Set(2)
+		p.Checkpoint()
+		p.Done()
+	})
+}
@@ -0,0 +1,155 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newRedisClient returns a client for connStr, read according to the mode of the job. db
+// replaces the DB of the connection string unless it is nil; cluster clients ignore it.
+func newRedisClient(cfg *job.RedisConfig, connStr string, db *int) (redis.UniversalClient, error) {
+	switch cfg.Mode {
+	case job.RedisModeCluster:
+		opt, err := redis.ParseClusterURL(connStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis cluster URL: %w", err)
+		}
+		return redis.NewClusterClient(opt), nil
+	case job.RedisModeSentinel:
+		opt, err := redis.ParseFailoverURL(connStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis sentinel URL: %w", err)
+		}
+		if db != nil {
+			opt.DB = *db
+		}
+		return redis.NewFailoverClient(opt), nil
+	default:
+		opt, err := redis.ParseURL(connStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+		if db != nil {
+			opt.DB = *db
+		}
+		return redis.NewClient(opt), nil
+	}
+}
+
+// redisNodes returns the options of the servers that hold the data of the job: the server of
+// the URL, the master the sentinels currently name, or every master of a cluster
+func redisNodes(ctx context.Context, cfg *job.RedisConfig) ([]*redis.Options, error) {
+	switch cfg.Mode {
+	case job.RedisModeCluster:
+		opt, err := redis.ParseClusterURL(cfg.ConnectionString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis cluster URL: %w", err)
+		}
+		client := redis.NewClusterClient(opt)
+		defer client.Close()
+
+		var mu sync.Mutex
+		var nodes []*redis.Options
+		if err := client.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			node := *master.Options()
+			mu.Lock()
+			defer mu.Unlock()
+			nodes = append(nodes, &node)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("failed to discover the cluster masters: %w", err)
+		}
+		slices.SortFunc(nodes, func(a, b *redis.Options) int { return strings.Compare(a.Addr, b.Addr) })
+		return nodes, nil
+
+	case job.RedisModeSentinel:
+		opt, err := redis.ParseFailoverURL(cfg.ConnectionString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis sentinel URL: %w", err)
+		}
+		addr, err := redisSentinelMaster(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		return []*redis.Options{{
+			Network:     "tcp",
+			Addr:        addr,
+			Username:    opt.Username,
+			Password:    opt.Password,
+			DB:          opt.DB,
+			TLSConfig:   opt.TLSConfig,
+			DialTimeout: opt.DialTimeout,
+		}}, nil
+
+	default:
+		opt, err := redis.ParseURL(cfg.ConnectionString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+		return []*redis.Options{opt}, nil
+	}
+}
+
+// redisSentinelMaster returns the address of the current master from the first sentinel that
+// answers
+func redisSentinelMaster(ctx context.Context, opt *redis.FailoverOptions) (string, error) {
+	var lastErr error
+	for _, addr := range opt.SentinelAddrs {
+		sentinel := redis.NewSentinelClient(&redis.Options{
+			Addr:        addr,
+			Username:    opt.SentinelUsername,
+			Password:    opt.SentinelPassword,
+			TLSConfig:   opt.TLSConfig,
+			DialTimeout: opt.DialTimeout,
+		})
+		master, err := sentinel.GetMasterAddrByName(ctx, opt.MasterName).Result()
+		sentinel.Close()
+		if err == nil && len(master) == 2 {
+			return master[0] + ":" + master[1], nil
+		}
+		if err == nil {
+			err = fmt.Errorf("unexpected reply %v", master)
+		}
+		lastErr = fmt.Errorf("sentinel %s: %w", addr, err)
+	}
+	return "", fmt.Errorf("failed to resolve master %q: %w", opt.MasterName, lastErr)
+}
+
+// redisDatabases returns the DB indexes of a node to dump: the configured ones, every DB with
+// keys, or the DB of the connection string
+func redisDatabases(ctx context.Context, cfg *job.RedisConfig, client *redis.Client) ([]int, error) {
+	switch {
+	case len(cfg.Databases) > 0:
+		return cfg.Databases, nil
+	case cfg.AllDatabases:
+		info, err := client.Info(ctx, "keyspace").Result()
+		if err != nil {
+			return nil, fmt.Errorf("INFO failed: %w", err)
+		}
+		return parseRedisKeyspace(info), nil
+	}
+	return []int{client.Options().DB}, nil
+}
+
+// parseRedisKeyspace returns the DB indexes of the keyspace section of INFO, which only lists
+// the DBs with keys: db0:keys=1,expires=0,avg_ttl=0
+func parseRedisKeyspace(info string) []int {
+	var dbs []int
+	for _, line := range strings.Split(info, "\n") {
+		name, _, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || !strings.HasPrefix(name, "db") {
+			continue
+		}
+		if db, err := strconv.Atoi(name[2:]); err == nil {
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs
+}
@@ -0,0 +1,37 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRedisClient(t *testing.T) {
+	db := 3
+	client, err := newRedisClient(&job.RedisConfig{}, "redis://db:6379/1", &db)
+	require.NoError(t, err)
+	defer client.Close()
+	require.IsType(t, &redis.Client{}, client)
+	assert.Equal(t, 3, client.(*redis.Client).Options().DB)
+
+	cluster, err := newRedisClient(&job.RedisConfig{Mode: job.RedisModeCluster}, "redis://n1:7000?addr=n2:7000", nil)
+	require.NoError(t, err)
+	defer cluster.Close()
+	require.IsType(t, &redis.ClusterClient{}, cluster)
+	assert.Equal(t, []string{"n1:7000", "n2:7000"}, cluster.(*redis.ClusterClient).Options().Addrs)
+
+	failover, err := newRedisClient(&job.RedisConfig{Mode: job.RedisModeSentinel},
+		"redis://s1:26379?master_name=main&password=pw", nil)
+	require.NoError(t, err)
+	defer failover.Close()
+	assert.IsType(t, &redis.Client{}, failover)
+}
+
+func TestParseRedisKeyspace(t *testing.T) {
+	info := "# Keyspace\r\ndb0:keys=10,expires=1,avg_ttl=0\r\ndb3:keys=2,expires=0,avg_ttl=0\r\n"
+	assert.Equal(t, []int{0, 3}, parseRedisKeyspace(info))
+	assert.Empty(t, parseRedisKeyspace("# Keyspace\r\n"))
+}
@@ -3,13 +3,13 @@ package activities
 import (
 	"agent/internal/job"
 	"context"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.temporal.io/sdk/activity"
@@ -19,17 +19,13 @@ type RedisDumpActivityInput struct {
 	Job *job.Job `json:"job"`
 }
 
-type redisKeyEntry struct {
-	Key  string `json:"key"`
-	TTL  int64  `json:"ttl_ms"` // -1 = no expiry
-	Dump string `json:"dump"`   // base64-encoded DUMP payload
-}
-
-type redisDumpFile struct {
-	Version int             `json:"version"`
-	Keys    []redisKeyEntry `json:"keys"`
-}
+// redisDumpBatch is the SCAN count, and so how many keys are dumped in one pipeline
+const redisDumpBatch = 100
 
+// RedisDumpActivity backs up the selected DBs of the job's nodes (the server, the sentinels'
+// master or every cluster master) key by key into a newline-delimited JSON file (version 2 of
+// the dump format), or, with the rdb method, receives an RDB snapshot of each node. Both are
+// written to disk as they arrive.
 func (a *Activities) RedisDumpActivity(ctx context.Context, input RedisDumpActivityInput) (*DownloadActivityOutput, error) {
 	logger := activity.GetLogger(ctx)
 	logger.Info("RedisDumpActivity started", "jobId", input.Job.ID)
@@ -43,88 +39,252 @@ func (a *Activities) RedisDumpActivity(ctx context.Context, input RedisDumpActiv
 		return nil, fmt.Errorf("invalid Redis config: %w", err)
 	}
 
-	opt, err := redis.ParseURL(cfg.ConnectionString)
+	nodes, err := redisNodes(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Method == job.RedisMethodRDB {
+		return a.redisDumpRDB(ctx, input.Job.ID, nodes)
+	}
+
+	filename := fmt.Sprintf("%s.ndjson", input.Job.ID)
+	tempFilePath := filepath.Join(a.Config.TempDir, filename)
+
+	f, err := os.Create(tempFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dump file: %w", err)
+	}
+	defer f.Close()
+
+	progress := a.newProgressReporter(ctx, 0)
+	dw, err := newRedisDumpWriter(io.MultiWriter(f, progress), cfg.MultiDatabase())
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		if err := redisDumpNode(ctx, cfg, node, dw.Write); err != nil {
+			return nil, err
+		}
+	}
+	if err := dw.Close(); err != nil {
+		return nil, err
+	}
+	progress.Done()
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write dump file: %w", err)
+	}
+
+	out, err := a.hashAndReturn(tempFilePath, filename, "application/x-ndjson")
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		return nil, err
 	}
+	logger.Info("RedisDumpActivity completed", "filePath", tempFilePath, "size", out.Size, "keys", dw.Keys(), "nodes", len(nodes))
+	return out, nil
+}
 
-	client := redis.NewClient(opt)
+// redisDumpNode calls fn with the keys of the selected DBs of one node and their DUMP payloads
+func redisDumpNode(ctx context.Context, cfg *job.RedisConfig, node *redis.Options, fn func(redisKeyEntry, []byte) error) error {
+	client := redis.NewClient(node)
 	defer client.Close()
 
 	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		return fmt.Errorf("failed to connect to Redis %s: %w", node.Addr, err)
+	}
+	dbs, err := redisDatabases(ctx, cfg, client)
+	if err != nil {
+		return err
 	}
 
-	dump := redisDumpFile{Version: 1}
-
-	var cursor uint64
-	for {
-		keys, nextCursor, err := client.Scan(ctx, cursor, "*", 100).Result()
+	for _, db := range dbs {
+		dbClient := client
+		if db != node.DB {
+			opt := *node
+			opt.DB = db
+			dbClient = redis.NewClient(&opt)
+		}
+		err := redisScanDump(ctx, dbClient, cfg.Match, cfg.Types, func(entry redisKeyEntry, value []byte) error {
+			entry.DB = db
+			return fn(entry, value)
+		})
+		if dbClient != client {
+			dbClient.Close()
+		}
 		if err != nil {
-			return nil, fmt.Errorf("SCAN failed: %w", err)
+			return fmt.Errorf("failed to dump DB %d of %s: %w", db, node.Addr, err)
 		}
+	}
+	return nil
+}
 
-		for _, key := range keys {
-			raw, err := client.Dump(ctx, key).Result()
-			if err != nil {
-				if err == redis.Nil {
-					continue
-				}
-				return nil, fmt.Errorf("DUMP failed for key %q: %w", key, err)
+// redisScanDump SCANs the keys of the database of client that match pattern and, when types
+// are given, are of one of them, and calls fn with each of them. DUMP, PTTL and TYPE of a SCAN
+// batch are sent in one pipeline; keys that expired in between are skipped.
+func redisScanDump(ctx context.Context, client *redis.Client, match string, types []string, fn func(redisKeyEntry, []byte) error) error {
+	if match == "" {
+		match = "*"
+	}
+	if len(types) == 0 {
+		types = []string{""}
+	}
+	for _, keyType := range types {
+		var cursor uint64
+		for {
+			var keys []string
+			var nextCursor uint64
+			var err error
+			if keyType != "" {
+				keys, nextCursor, err = client.ScanType(ctx, cursor, match, redisDumpBatch, keyType).Result()
+			} else {
+				keys, nextCursor, err = client.Scan(ctx, cursor, match, redisDumpBatch).Result()
 			}
-
-			ttl, err := client.PTTL(ctx, key).Result()
 			if err != nil {
-				return nil, fmt.Errorf("PTTL failed for key %q: %w", key, err)
+				return fmt.Errorf("SCAN failed: %w", err)
+			}
+			if err := redisDumpKeys(ctx, client, keys, fn); err != nil {
+				return err
 			}
 
-			dump.Keys = append(dump.Keys, redisKeyEntry{
-				Key:  key,
-				TTL:  ttl.Milliseconds(),
-				Dump: base64.StdEncoding.EncodeToString([]byte(raw)),
-			})
+			cursor = nextCursor
+			if cursor == 0 {
+				break
+			}
 		}
+	}
+	return nil
+}
+
+// redisDumpKeys sends DUMP, PTTL and TYPE of keys in one pipeline and calls fn with each key
+// that still exists
+func redisDumpKeys(ctx context.Context, client *redis.Client, keys []string, fn func(redisKeyEntry, []byte) error) error {
+	pipe := client.Pipeline()
+	dumps := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	types := make([]*redis.StatusCmd, len(keys))
+	for i, key := range keys {
+		dumps[i] = pipe.Dump(ctx, key)
+		ttls[i] = pipe.PTTL(ctx, key)
+		types[i] = pipe.Type(ctx, key)
+	}
+	// Exec returns the first error of the batch, the commands are checked one by one
+	if _, err := pipe.Exec(ctx); err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
 
-		cursor = nextCursor
-		if cursor == 0 {
-			break
+	for i, key := range keys {
+		raw, err := dumps[i].Result()
+		if err != nil {
+			if err == redis.Nil {
+				continue
+			}
+			return fmt.Errorf("DUMP failed for key %q: %w", key, err)
+		}
+		pttl, err := ttls[i].Result()
+		if err != nil {
+			return fmt.Errorf("PTTL failed for key %q: %w", key, err)
+		}
+		ttl, ok := redisTTL(pttl)
+		if !ok {
+			// Expired between DUMP and PTTL
+			continue
+		}
+		keyType, err := types[i].Result()
+		if err != nil {
+			return fmt.Errorf("TYPE failed for key %q: %w", key, err)
+		}
+		if err := fn(redisKeyEntry{Key: key, TTL: ttl, Type: keyType}, []byte(raw)); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	filename := fmt.Sprintf("%s.json", input.Job.ID)
-	tempFilePath := filepath.Join(a.Config.TempDir, filename)
+// redisTTL returns the ttl_ms of a key from its PTTL result, -1 for keys without expiry, and
+// false when the key no longer exists. go-redis passes the -1 and -2 replies through as
+// nanoseconds instead of scaling them to milliseconds.
+func redisTTL(pttl time.Duration) (int64, bool) {
+	switch pttl {
+	case -2:
+		return 0, false
+	case -1:
+		return -1, true
+	}
+	return pttl.Milliseconds(), true
+}
 
-	f, err := os.Create(tempFilePath)
+// redisDumpRDB receives an RDB snapshot of each node: <job>.rdb for a single node, or a
+// <job>.rdb.tar of one <host>_<port>.rdb per master of a cluster
+func (a *Activities) redisDumpRDB(ctx context.Context, jobId string, nodes []*redis.Options) (*DownloadActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+
+	if len(nodes) == 1 {
+		filename := jobId + redisRDBExtension
+		tempFilePath := filepath.Join(a.Config.TempDir, filename)
+		if err := a.redisSyncRDBFile(ctx, nodes[0], tempFilePath); err != nil {
+			return nil, err
+		}
+		out, err := a.hashAndReturn(tempFilePath, filename, "application/octet-stream")
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("RedisDumpActivity completed", "filePath", tempFilePath, "size", out.Size)
+		return out, nil
+	}
+
+	tempDir, err := os.MkdirTemp(a.Config.TempDir, jobId+"-redis-rdb-*")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create dump file: %w", err)
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
-	defer f.Close()
+	defer os.RemoveAll(tempDir)
 
-	if err := json.NewEncoder(f).Encode(dump); err != nil {
-		return nil, fmt.Errorf("failed to encode dump: %w", err)
+	var names []string
+	for _, node := range nodes {
+		name := strings.NewReplacer(":", "_", "/", "_").Replace(node.Addr) + redisRDBExtension
+		if err := a.redisSyncRDBFile(ctx, node, filepath.Join(tempDir, name)); err != nil {
+			return nil, fmt.Errorf("%s: %w", node.Addr, err)
+		}
+		names = append(names, name)
 	}
 
-	if _, err := f.Seek(0, io.SeekStart); err != nil {
-		return nil, fmt.Errorf("failed to seek dump file: %w", err)
+	archiveName := jobId + redisRDBExtension + ".tar"
+	archivePath := filepath.Join(a.Config.TempDir, archiveName)
+	args := append([]string{"-cf", archivePath, "-C", tempDir}, names...)
+	if output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "tar", args...), archivePath); err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w, output: %s", err, string(output))
 	}
 
-	hash := sha256.New()
-	if _, err := io.Copy(hash, f); err != nil {
-		return nil, fmt.Errorf("failed to calculate hash: %w", err)
+	out, err := a.hashAndReturn(archivePath, archiveName, "application/x-tar")
+	if err != nil {
+		return nil, err
 	}
+	logger.Info("RedisDumpActivity completed", "filePath", archivePath, "size", out.Size, "nodes", len(nodes))
+	return out, nil
+}
 
-	fi, err := f.Stat()
+// redisSyncRDBFile receives an RDB snapshot of node into path and checks its header
+func (a *Activities) redisSyncRDBFile(ctx context.Context, node *redis.Options, path string) error {
+	f, err := os.Create(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat dump file: %w", err)
+		return fmt.Errorf("failed to create dump file: %w", err)
 	}
+	defer f.Close()
 
-	logger.Info("RedisDumpActivity completed", "filePath", tempFilePath, "size", fi.Size(), "keys", len(dump.Keys))
+	// The server sends nothing while it writes the snapshot, the heartbeats keep going meanwhile
+	stop := a.newProgressReporter(ctx, 0).Watch(path)
+	_, err = redisSyncRDB(ctx, node, f)
+	stop()
+	if err != nil {
+		return fmt.Errorf("failed to receive RDB snapshot: %w", err)
+	}
 
-	return &DownloadActivityOutput{
-		FilePath: tempFilePath,
-		Size:     fi.Size(),
-		Checksum: fmt.Sprintf("%x", hash.Sum(nil)),
-		Name:     filename,
-		MimeType: "application/json",
-	}, nil
+	header := make([]byte, len(redisRDBMagic)+4)
+	if _, err := f.ReadAt(header, 0); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read dump file: %w", err)
+	}
+	if err := checkRDBHeader(header); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write dump file: %w", err)
+	}
+	return nil
 }
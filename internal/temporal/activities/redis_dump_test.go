@@ -0,0 +1,22 @@
+package activities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisTTL(t *testing.T) {
+	ttl, ok := redisTTL(-1)
+	assert.True(t, ok)
+	assert.Equal(t, int64(-1), ttl)
+
+	ttl, ok = redisTTL(1500 * time.Millisecond)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1500), ttl)
+
+	// The key expired after it was dumped
+	_, ok = redisTTL(-2)
+	assert.False(t, ok)
+}
@@ -0,0 +1,290 @@
+package activities
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// Redis JSON dump formats. Version 1 is a single object with a "keys" array, version 2 is
+// newline-delimited: a header line, one line per key and a trailer line with the counts.
+const (
+	redisDumpVersion1 = 1
+	redisDumpVersion  = 2
+)
+
+// redisDumpHeader is the first line of a version 2 dump, and the fields of a version 1 dump
+// before its keys
+type redisDumpHeader struct {
+	Version int `json:"version"`
+	// MultiDB is set when the job selected its DBs, the keys are then restored into their DB
+	MultiDB bool `json:"multi_db,omitempty"`
+}
+
+type redisKeyEntry struct {
+	Key string `json:"key,omitempty"`
+	// KeyB64 replaces Key for keys that are not valid UTF-8, base64 encoded (version 2)
+	KeyB64 string `json:"key_b64,omitempty"`
+	TTL    int64  `json:"ttl_ms"` // -1 = no expiry
+	Dump   string `json:"dump"`   // base64-encoded DUMP payload
+	// DB is the DB index of the key; version 1 dumps only record it for dumps of several DBs
+	DB int `json:"db"`
+	// Type is the type of the key (version 2)
+	Type string `json:"type,omitempty"`
+	// Checksum is the hex CRC-32C of the key, TTL, DB and DUMP payload (version 2)
+	Checksum string `json:"crc32c,omitempty"`
+
+	// Value is the decoded DUMP payload, set by redisDumpReader
+	Value []byte `json:"-"`
+}
+
+// redisDumpTrailer is the last line of a version 2 dump. A dump without it was cut short.
+type redisDumpTrailer struct {
+	Keys  int64            `json:"keys"`
+	DBs   map[string]int64 `json:"dbs"`
+	Types map[string]int64 `json:"types"`
+}
+
+// redisDumpLine is a line of a version 2 dump after the header: a key or the trailer
+type redisDumpLine struct {
+	redisKeyEntry
+	Trailer *redisDumpTrailer `json:"trailer,omitempty"`
+}
+
+var redisChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// redisChecksum returns the hex CRC-32C of a key entry with its DUMP payload value: the length
+// of the key and the key, the TTL and the DB as big-endian 64-bit integers, then the payload
+func redisChecksum(entry *redisKeyEntry, value []byte) string {
+	buf := make([]byte, 0, 24+len(entry.Key)+len(value))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(len(entry.Key)))
+	buf = append(buf, entry.Key...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(entry.TTL))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(entry.DB))
+	buf = append(buf, value...)
+	return fmt.Sprintf("%08x", crc32.Checksum(buf, redisChecksumTable))
+}
+
+func newRedisDumpTrailer() redisDumpTrailer {
+	return redisDumpTrailer{DBs: map[string]int64{}, Types: map[string]int64{}}
+}
+
+// add counts a key
+func (t *redisDumpTrailer) add(entry *redisKeyEntry) {
+	t.Keys++
+	t.DBs[strconv.Itoa(entry.DB)]++
+	t.Types[entry.Type]++
+}
+
+// redisDumpWriter writes a version 2 dump one key at a time
+type redisDumpWriter struct {
+	w      *bufio.Writer
+	enc    *json.Encoder
+	counts redisDumpTrailer
+}
+
+// newRedisDumpWriter writes the header of a dump to w
+func newRedisDumpWriter(w io.Writer, multiDB bool) (*redisDumpWriter, error) {
+	bw := bufio.NewWriter(w)
+	dw := &redisDumpWriter{w: bw, enc: json.NewEncoder(bw), counts: newRedisDumpTrailer()}
+	if err := dw.enc.Encode(redisDumpHeader{Version: redisDumpVersion, MultiDB: multiDB}); err != nil {
+		return nil, fmt.Errorf("failed to write dump header: %w", err)
+	}
+	return dw, nil
+}
+
+// Write writes the line of a key with its raw DUMP payload value
+func (dw *redisDumpWriter) Write(entry redisKeyEntry, value []byte) error {
+	entry.Dump = base64.StdEncoding.EncodeToString(value)
+	entry.Checksum = redisChecksum(&entry, value)
+	line := entry
+	if !utf8.ValidString(entry.Key) {
+		// encoding/json would replace the invalid bytes
+		line.Key, line.KeyB64 = "", base64.StdEncoding.EncodeToString([]byte(entry.Key))
+	}
+	if err := dw.enc.Encode(line); err != nil {
+		return fmt.Errorf("failed to write key %q: %w", entry.Key, err)
+	}
+	dw.counts.add(&entry)
+	return nil
+}
+
+// Close writes the trailer and flushes the dump, it does not close the underlying writer
+func (dw *redisDumpWriter) Close() error {
+	trailer := struct {
+		Trailer *redisDumpTrailer `json:"trailer"`
+	}{&dw.counts}
+	if err := dw.enc.Encode(trailer); err != nil {
+		return fmt.Errorf("failed to write dump trailer: %w", err)
+	}
+	if err := dw.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write dump file: %w", err)
+	}
+	return nil
+}
+
+// Keys returns how many keys were written
+func (dw *redisDumpWriter) Keys() int64 { return dw.counts.Keys }
+
+// redisDumpReader reads the keys of a version 1 or 2 dump one at a time, checking the payload
+// checksums and the trailer of version 2 dumps
+type redisDumpReader struct {
+	redisDumpHeader
+	file   *os.File
+	dec    *json.Decoder
+	counts redisDumpTrailer
+	done   bool
+}
+
+// openRedisDump opens a dump written by RedisDumpActivity and reads its header. Malformed dumps
+// are InvalidBackup errors.
+func openRedisDump(path string, progress io.Writer) (*redisDumpReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dump file: %w", err)
+	}
+	var src io.Reader = file
+	if progress != nil {
+		src = io.TeeReader(file, progress)
+	}
+	r := &redisDumpReader{file: file, dec: json.NewDecoder(bufio.NewReader(src)), counts: newRedisDumpTrailer()}
+	if err := r.readHeader(); err != nil {
+		file.Close()
+		return nil, invalidRedisDump(err)
+	}
+	return r, nil
+}
+
+// readHeader reads the fields of the first object token by token. A version 1 dump is left
+// inside its keys array, a version 2 dump at the first key line.
+func (r *redisDumpReader) readHeader() error {
+	if err := r.expectDelim('{'); err != nil {
+		return err
+	}
+	for r.dec.More() {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case "version":
+			err = r.dec.Decode(&r.Version)
+		case "multi_db":
+			err = r.dec.Decode(&r.MultiDB)
+		case "keys":
+			if r.Version != redisDumpVersion1 {
+				return errors.New("keys array outside a version 1 dump")
+			}
+			// Version 1 dumps without keys have "keys":null
+			tok, err := r.dec.Token()
+			if err != nil {
+				return err
+			}
+			if tok == nil {
+				r.done = true
+			} else if tok != json.Delim('[') {
+				return fmt.Errorf("unexpected keys %v", tok)
+			}
+			return nil
+		default:
+			var skip json.RawMessage
+			err = r.dec.Decode(&skip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if r.Version != redisDumpVersion {
+		return fmt.Errorf("unsupported dump version %d", r.Version)
+	}
+	return r.expectDelim('}')
+}
+
+func (r *redisDumpReader) expectDelim(delim json.Delim) error {
+	tok, err := r.dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %v, found %v", delim, tok)
+	}
+	return nil
+}
+
+// Next returns the next key, or io.EOF after the last one once the dump proved complete
+func (r *redisDumpReader) Next() (*redisKeyEntry, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	entry, err := r.next()
+	if err != nil {
+		if err != io.EOF {
+			return nil, invalidRedisDump(err)
+		}
+		r.done = true
+		return nil, io.EOF
+	}
+	if entry.KeyB64 != "" {
+		key, err := base64.StdEncoding.DecodeString(entry.KeyB64)
+		if err != nil {
+			return nil, invalidRedisDump(fmt.Errorf("invalid key_b64 %q", entry.KeyB64))
+		}
+		entry.Key, entry.KeyB64 = string(key), ""
+	}
+	value, err := base64.StdEncoding.DecodeString(entry.Dump)
+	if err != nil {
+		return nil, invalidRedisDump(fmt.Errorf("invalid DUMP payload for key %q", entry.Key))
+	}
+	if entry.Checksum != "" && entry.Checksum != redisChecksum(entry, value) {
+		return nil, invalidRedisDump(fmt.Errorf("checksum mismatch for key %q", entry.Key))
+	}
+	entry.Value = value
+	r.counts.add(entry)
+	return entry, nil
+}
+
+func (r *redisDumpReader) next() (*redisKeyEntry, error) {
+	if r.Version == redisDumpVersion1 {
+		if !r.dec.More() {
+			return nil, io.EOF
+		}
+		var entry redisKeyEntry
+		if err := r.dec.Decode(&entry); err != nil {
+			return nil, err
+		}
+		return &entry, nil
+	}
+
+	var line redisDumpLine
+	if err := r.dec.Decode(&line); err != nil {
+		if err == io.EOF {
+			return nil, errors.New("the dump has no trailer, it was cut short")
+		}
+		return nil, err
+	}
+	if line.Trailer == nil {
+		return &line.redisKeyEntry, nil
+	}
+	if t := line.Trailer; t.Keys != r.counts.Keys || !maps.Equal(t.DBs, r.counts.DBs) || !maps.Equal(t.Types, r.counts.Types) {
+		return nil, fmt.Errorf("the dump has %d keys, its trailer counts %d", r.counts.Keys, t.Keys)
+	}
+	return nil, io.EOF
+}
+
+func (r *redisDumpReader) Close() error { return r.file.Close() }
+
+func invalidRedisDump(err error) error {
+	return temporal.NewNonRetryableApplicationError(
+		fmt.Sprintf("failed to decode dump file: %v", err), "InvalidBackup", err)
+}
@@ -0,0 +1,138 @@
+package activities
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+)
+
+// readAllRedisDump returns the keys of the dump at path, or the first error
+func readAllRedisDump(t *testing.T, path string) (*redisDumpReader, []redisKeyEntry, error) {
+	t.Helper()
+	dump, err := openRedisDump(path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	t.Cleanup(func() { dump.Close() })
+	var keys []redisKeyEntry
+	for {
+		entry, err := dump.Next()
+		if err == io.EOF {
+			return dump, keys, nil
+		}
+		if err != nil {
+			return dump, keys, err
+		}
+		keys = append(keys, *entry)
+	}
+}
+
+func writeRedisDumpFile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "job-1.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	return path
+}
+
+func TestRedisDumpRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	dw, err := newRedisDumpWriter(&buf, true)
+	require.NoError(t, err)
+	require.NoError(t, dw.Write(redisKeyEntry{Key: "a", TTL: -1, DB: 0, Type: "string"}, []byte("\x00\x01a")))
+	require.NoError(t, dw.Write(redisKeyEntry{Key: "b", TTL: 5000, DB: 3, Type: "hash"}, []byte("\x04\x02b")))
+	require.NoError(t, dw.Close())
+	assert.Equal(t, int64(2), dw.Keys())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, `{"version":2,"multi_db":true}`, lines[0])
+	assert.Equal(t, `{"trailer":{"keys":2,"dbs":{"0":1,"3":1},"types":{"hash":1,"string":1}}}`, lines[3])
+
+	dump, keys, err := readAllRedisDump(t, writeRedisDumpFile(t, buf.String()))
+	require.NoError(t, err)
+	assert.Equal(t, 2, dump.Version)
+	assert.True(t, dump.MultiDB)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "a", keys[0].Key)
+	assert.Equal(t, []byte("\x00\x01a"), keys[0].Value)
+	assert.Equal(t, 3, keys[1].DB)
+	assert.Equal(t, "hash", keys[1].Type)
+	assert.Equal(t, int64(5000), keys[1].TTL)
+}
+
+func TestRedisDumpBinaryKey(t *testing.T) {
+	key := "bin:\xff\xfe\x00"
+	var buf bytes.Buffer
+	dw, err := newRedisDumpWriter(&buf, false)
+	require.NoError(t, err)
+	require.NoError(t, dw.Write(redisKeyEntry{Key: key, TTL: -1, Type: "string"}, []byte("payload")))
+	require.NoError(t, dw.Close())
+
+	lines := strings.Split(buf.String(), "\n")
+	assert.Contains(t, lines[1], `"key_b64":"YmluOv/+AA=="`)
+	assert.NotContains(t, lines[1], `"key"`)
+
+	_, keys, err := readAllRedisDump(t, writeRedisDumpFile(t, buf.String()))
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, key, keys[0].Key)
+	assert.Empty(t, keys[0].KeyB64)
+}
+
+func TestRedisDumpVersion1(t *testing.T) {
+	dump, keys, err := readAllRedisDump(t, writeRedisDumpFile(t,
+		`{"version":1,"multi_db":true,"keys":[{"key":"a","ttl_ms":-1,"dump":"AAE=","db":2},{"key":"b","ttl_ms":10,"dump":"AAI="}]}`+"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, dump.Version)
+	assert.True(t, dump.MultiDB)
+	require.Len(t, keys, 2)
+	assert.Equal(t, 2, keys[0].DB)
+	assert.Equal(t, []byte{0, 2}, keys[1].Value)
+
+	// Dumps of an empty DB were written with "keys":null
+	_, keys, err = readAllRedisDump(t, writeRedisDumpFile(t, `{"version":1,"keys":null}`))
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestRedisDumpInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	dw, err := newRedisDumpWriter(&buf, false)
+	require.NoError(t, err)
+	require.NoError(t, dw.Write(redisKeyEntry{Key: "a", TTL: -1, Type: "string"}, []byte("payload")))
+	require.NoError(t, dw.Close())
+	valid := buf.String()
+	lines := strings.SplitAfter(valid, "\n")
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"truncated", lines[0] + lines[1], "cut short"},
+		{"checksum", strings.Replace(valid, redisChecksum(&redisKeyEntry{Key: "a", TTL: -1}, []byte("payload")), "00000000", 1), "checksum mismatch"},
+		{"key", strings.Replace(valid, `"key":"a"`, `"key":"b"`, 1), "checksum mismatch"},
+		{"ttl", strings.Replace(valid, `"ttl_ms":-1`, `"ttl_ms":1000`, 1), "checksum mismatch"},
+		{"db", strings.Replace(valid, `"db":0`, `"db":1`, 1), "checksum mismatch"},
+		{"trailer", lines[0] + lines[1] + strings.Replace(lines[2], `"keys":1`, `"keys":2`, 1), "trailer counts 2"},
+		{"version", `{"version":3}` + "\n", "unsupported dump version 3"},
+		{"not json", "REDIS0011", "failed to decode dump file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readAllRedisDump(t, writeRedisDumpFile(t, tt.data))
+			require.Error(t, err)
+			assert.ErrorContains(t, err, tt.want)
+			var appErr *temporal.ApplicationError
+			require.ErrorAs(t, err, &appErr)
+			assert.Equal(t, "InvalidBackup", appErr.Type())
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package activities
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// redisRDBExtension is the extension of RDB snapshots, which the restore tells apart by name
+	redisRDBExtension = ".rdb"
+	// redisRDBMagic starts every RDB file, followed by a four digit version
+	redisRDBMagic = "REDIS"
+)
+
+// redisSyncRDB receives an RDB snapshot of the server of opt the way a replica does: PSYNC (or
+// SYNC on old servers) makes the server write a snapshot of all databases, which is copied to w
+// as it arrives. The connection is closed after the snapshot, before the replication stream.
+func redisSyncRDB(ctx context.Context, opt *redis.Options, w io.Writer) (int64, error) {
+	network := opt.Network
+	if network == "" {
+		network = "tcp"
+	}
+	dialer := &net.Dialer{Timeout: opt.DialTimeout}
+	var conn net.Conn
+	var err error
+	if opt.TLSConfig != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: opt.TLSConfig}).DialContext(ctx, network, opt.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, network, opt.Addr)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	r := bufio.NewReader(conn)
+	call := func(args ...string) (string, error) {
+		if _, err := conn.Write(redisCommand(args...)); err != nil {
+			return "", err
+		}
+		return readRedisReply(r)
+	}
+
+	if opt.Password != "" {
+		args := []string{"AUTH", opt.Password}
+		if opt.Username != "" {
+			args = []string{"AUTH", opt.Username, opt.Password}
+		}
+		if _, err := call(args...); err != nil {
+			return 0, fmt.Errorf("AUTH failed: %w", err)
+		}
+	}
+	// With capa eof a server that syncs without a disk sends the snapshot with an end marker
+	// instead of its length; servers that do not know it reject it and send the length
+	if _, err := call("REPLCONF", "capa", "eof", "capa", "psync2"); err != nil && !isRedisReplyError(err) {
+		return 0, fmt.Errorf("REPLCONF failed: %w", err)
+	}
+	if reply, err := call("PSYNC", "?", "-1"); err != nil {
+		if !isRedisReplyError(err) {
+			return 0, fmt.Errorf("PSYNC failed: %w", err)
+		}
+		// Servers before 2.8 only know SYNC, which replies with the snapshot right away
+		if _, err := conn.Write(redisCommand("SYNC")); err != nil {
+			return 0, fmt.Errorf("SYNC failed: %w", err)
+		}
+	} else if !strings.HasPrefix(reply, "FULLRESYNC") {
+		return 0, fmt.Errorf("unexpected PSYNC reply %q", reply)
+	}
+
+	n, err := readRDBPayload(r, w)
+	if err != nil && ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+	return n, err
+}
+
+// redisCommand encodes a command as a RESP array of bulk strings
+func redisCommand(args ...string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return b.Bytes()
+}
+
+// redisReplyError is an error reply of the server
+type redisReplyError string
+
+func (e redisReplyError) Error() string { return string(e) }
+
+func isRedisReplyError(err error) bool {
+	var re redisReplyError
+	return errors.As(err, &re)
+}
+
+// readRedisReply reads a simple string reply, returning error replies as redisReplyError
+func readRedisReply(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case strings.HasPrefix(line, "+"):
+		return line[1:], nil
+	case strings.HasPrefix(line, "-"):
+		return "", redisReplyError(line[1:])
+	}
+	return "", fmt.Errorf("unexpected reply %q", line)
+}
+
+// readRDBPayload copies the snapshot that follows a sync to w. The server sends newlines while
+// it writes the snapshot, then either $<length> and the snapshot, or $EOF:<40 byte mark>, the
+// snapshot and the mark again.
+func readRDBPayload(r *bufio.Reader, w io.Writer) (int64, error) {
+	var header string
+	for header == "" {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return 0, fmt.Errorf("failed to read snapshot header: %w", err)
+		}
+		header = strings.TrimRight(line, "\r\n")
+	}
+	if strings.HasPrefix(header, "-") {
+		return 0, fmt.Errorf("sync failed: %s", header[1:])
+	}
+	if !strings.HasPrefix(header, "$") {
+		return 0, fmt.Errorf("unexpected snapshot header %q", header)
+	}
+
+	if mark, ok := strings.CutPrefix(header, "$EOF:"); ok {
+		if len(mark) != 40 {
+			return 0, fmt.Errorf("invalid snapshot end mark %q", mark)
+		}
+		return copyUntilMark(w, r, []byte(mark))
+	}
+	size, err := strconv.ParseInt(header[1:], 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid snapshot length %q", header)
+	}
+	n, err := io.CopyN(w, r, size)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// copyUntilMark copies r to w up to the first occurrence of mark, which is not copied
+func copyUntilMark(w io.Writer, r io.Reader, mark []byte) (int64, error) {
+	buf := make([]byte, 64<<10)
+	var held int // bytes at the start of buf that may be the beginning of mark
+	var n int64
+	for {
+		m, err := r.Read(buf[held:])
+		held += m
+		if i := bytes.Index(buf[:held], mark); i >= 0 {
+			written, err := w.Write(buf[:i])
+			return n + int64(written), err
+		}
+		if keep := len(mark) - 1; held > keep {
+			written, err := w.Write(buf[:held-keep])
+			n += int64(written)
+			if err != nil {
+				return n, err
+			}
+			held = copy(buf, buf[held-keep:held])
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+	}
+}
+
+// checkRDBHeader checks that a snapshot starts with the RDB magic and a version
+func checkRDBHeader(header []byte) error {
+	if len(header) < len(redisRDBMagic)+4 || !bytes.HasPrefix(header, []byte(redisRDBMagic)) {
+		return temporal.NewNonRetryableApplicationError("the snapshot is not an RDB file", "InvalidBackup", nil)
+	}
+	if _, err := strconv.Atoi(string(header[len(redisRDBMagic) : len(redisRDBMagic)+4])); err != nil {
+		return temporal.NewNonRetryableApplicationError("the snapshot has no RDB version", "InvalidBackup", err)
+	}
+	return nil
+}
+
+// isRedisRDB reports whether a backup file is an RDB snapshot, or an archive of the snapshots of
+// a cluster, by its name
+func isRedisRDB(filePath string) bool {
+	name := strings.TrimSuffix(strings.TrimSuffix(filePath, ".gz"), ".tar")
+	return strings.HasSuffix(name, redisRDBExtension)
+}
@@ -0,0 +1,140 @@
+package activities
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedisPrimary accepts one connection, answers the commands of a replica handshake from
+// replies and then writes snapshot. It returns the address and the commands it received.
+func fakeRedisPrimary(t *testing.T, replies map[string]string, snapshot string) (string, <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var commands []string
+		defer func() { received <- commands }()
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			// Each command is an array of bulk strings: *<n>, then $<len> and the argument n times
+			var n int
+			if _, err := fmt.Sscanf(line, "*%d\r\n", &n); err != nil {
+				return
+			}
+			var args []string
+			for range n {
+				r.ReadString('\n')
+				arg, _ := r.ReadString('\n')
+				args = append(args, strings.TrimRight(arg, "\r\n"))
+			}
+			commands = append(commands, strings.Join(args, " "))
+			reply, ok := replies[args[0]]
+			if !ok {
+				reply = "-ERR unknown command\r\n"
+			}
+			conn.Write([]byte(reply))
+			if args[0] == "SYNC" || (args[0] == "PSYNC" && strings.HasPrefix(reply, "+")) {
+				conn.Write([]byte(snapshot))
+				return
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestRedisSyncRDB(t *testing.T) {
+	rdb := "REDIS0011\xfa\x09redis-ver\x057.2.4\xff12345678"
+	mark := strings.Repeat("a", 40)
+
+	tests := []struct {
+		name     string
+		password string
+		replies  map[string]string
+		snapshot string
+		commands []string
+	}{
+		{
+			name:     "length",
+			replies:  map[string]string{"REPLCONF": "+OK\r\n", "PSYNC": "+FULLRESYNC 8de1 0\r\n"},
+			snapshot: "\n\n$" + strconv.Itoa(len(rdb)) + "\r\n" + rdb + "*1\r\n$4\r\nPING\r\n",
+			commands: []string{"REPLCONF capa eof capa psync2", "PSYNC ? -1"},
+		},
+		{
+			name:     "eof mark",
+			password: "pw",
+			replies:  map[string]string{"AUTH": "+OK\r\n", "REPLCONF": "+OK\r\n", "PSYNC": "+FULLRESYNC 8de1 0\r\n"},
+			snapshot: "\n$EOF:" + mark + "\r\n" + rdb + mark,
+			commands: []string{"AUTH pw", "REPLCONF capa eof capa psync2", "PSYNC ? -1"},
+		},
+		{
+			name:     "sync fallback",
+			replies:  map[string]string{"SYNC": ""},
+			snapshot: "$" + strconv.Itoa(len(rdb)) + "\r\n" + rdb,
+			commands: []string{"REPLCONF capa eof capa psync2", "PSYNC ? -1", "SYNC"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, received := fakeRedisPrimary(t, tt.replies, tt.snapshot)
+
+			var out bytes.Buffer
+			n, err := redisSyncRDB(context.Background(), &redis.Options{Network: "tcp", Addr: addr, Password: tt.password}, &out)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(rdb)), n)
+			assert.Equal(t, rdb, out.String())
+			assert.NoError(t, checkRDBHeader(out.Bytes()))
+			assert.Equal(t, tt.commands, <-received)
+		})
+	}
+
+	t.Run("auth rejected", func(t *testing.T) {
+		addr, _ := fakeRedisPrimary(t, map[string]string{"AUTH": "-WRONGPASS invalid password\r\n"}, "")
+		_, err := redisSyncRDB(context.Background(), &redis.Options{Network: "tcp", Addr: addr, Password: "pw"}, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "WRONGPASS")
+	})
+}
+
+func TestCopyUntilMark(t *testing.T) {
+	mark := []byte(strings.Repeat("m", 40))
+	payload := strings.Repeat("payload-", 20000)
+
+	var out bytes.Buffer
+	n, err := copyUntilMark(&out, iotest.HalfReader(strings.NewReader(payload+string(mark)+"trailing")), mark)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(payload)), n)
+	assert.Equal(t, payload, out.String())
+
+	_, err = copyUntilMark(&bytes.Buffer{}, strings.NewReader(payload), mark)
+	assert.Error(t, err)
+}
+
+func TestCheckRDBHeader(t *testing.T) {
+	assert.NoError(t, checkRDBHeader([]byte("REDIS0011")))
+	assert.Error(t, checkRDBHeader([]byte("REDIS")))
+	assert.Error(t, checkRDBHeader([]byte(`{"version":1}`)))
+	assert.True(t, isRedisRDB("/tmp/agent/job-1-restore-job-1.rdb"))
+	assert.False(t, isRedisRDB("/tmp/agent/job-1-restore-job-1.json"))
+}
@@ -0,0 +1,171 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// redisRestoreBatch is how many RESTORE commands are sent in one pipeline
+const redisRestoreBatch = 100
+
+// RedisRestoreActivity restores the keys of a JSON dump, of any version, with RESTORE, keeping
+// the TTL each key had left when it was dumped. The dump is read one key at a time and its
+// checksums and trailer are checked on the way, so a damaged dump stops the restore at the
+// first bad key. Existing keys fail the restore unless the target allows overwriting them.
+func (a *Activities) RedisRestoreActivity(ctx context.Context, input RestoreActivityInput) (*RestoreActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("RedisRestoreActivity started", "jobId", input.Job.ID)
+
+	cfg, err := job.LoadAs[*job.RedisConfig](*input.Job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Redis config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Redis config: %w", err)
+	}
+
+	if isRedisRDB(input.FilePath) {
+		// An RDB snapshot is loaded by a server starting with it as its dbfilename
+		return nil, temporal.NewNonRetryableApplicationError(
+			"RDB snapshots must be loaded by placing them in the data directory of a stopped server", "RestoreUnsupported", nil)
+	}
+
+	connStr := cfg.ConnectionString
+	if input.Target.ConnectionString != "" {
+		connStr = input.Target.ConnectionString
+	}
+	var db *int
+	if input.Target.Database != "" {
+		index, err := strconv.Atoi(input.Target.Database)
+		if err != nil || index < 0 {
+			return nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("invalid Redis database index %q", input.Target.Database), "InvalidRestoreTarget", nil)
+		}
+		db = &index
+	}
+
+	progress := a.newProgressReporter(ctx, pathSize(input.FilePath))
+	dump, err := openRedisDump(input.FilePath, progress)
+	if err != nil {
+		return nil, err
+	}
+	defer dump.Close()
+	if dump.MultiDB && db != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"dumps of several DBs restore into their own DBs, target.database does not apply", "InvalidRestoreTarget", nil)
+	}
+
+	// Dumps of a single DB restore into the target DB, the others into the DB of each key
+	clients := map[int]redis.UniversalClient{}
+	defer func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}()
+	clientFor := func(keyDB int) (redis.UniversalClient, error) {
+		if !dump.MultiDB {
+			keyDB = -1
+		}
+		if client, ok := clients[keyDB]; ok {
+			return client, nil
+		}
+		target := db
+		if keyDB >= 0 {
+			target = &keyDB
+		}
+		client, err := newRedisClient(cfg, connStr, target)
+		if err != nil {
+			return nil, err
+		}
+		clients[keyDB] = client
+		if err := client.Ping(ctx).Err(); err != nil {
+			return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		return client, nil
+	}
+
+	counts, err := redisRestore(ctx, dump, clientFor, input.Target.Overwrite)
+	if err != nil {
+		return nil, err
+	}
+	progress.Done()
+
+	logger.Info("RedisRestoreActivity completed", "keys", counts.Keys, "version", dump.Version)
+	return &RestoreActivityOutput{Items: counts.Keys}, nil
+}
+
+// redisRestoreCounts is what redisRestore restored
+type redisRestoreCounts struct {
+	Keys int64
+	// Persistent is how many of the keys have no TTL
+	Persistent int64
+}
+
+// redisRestore restores the keys of dump with RESTORE into the client clientFor returns for
+// their DB, a cluster client routing each key to its shard. The keys of a DB are sent in
+// pipelines of redisRestoreBatch. Existing keys fail the restore unless replace is set.
+func redisRestore(ctx context.Context, dump *redisDumpReader, clientFor func(db int) (redis.UniversalClient, error), replace bool) (redisRestoreCounts, error) {
+	var counts redisRestoreCounts
+	pipes := map[int]redis.Pipeliner{}
+	exec := func(db int) error {
+		pipe := pipes[db]
+		n := int64(pipe.Len())
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("RESTORE failed: %w", err)
+		}
+		counts.Keys += n
+		return nil
+	}
+
+	for {
+		entry, err := dump.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return counts, err
+		}
+
+		pipe, ok := pipes[entry.DB]
+		if !ok {
+			client, err := clientFor(entry.DB)
+			if err != nil {
+				return counts, err
+			}
+			pipe = client.Pipeline()
+			pipes[entry.DB] = pipe
+		}
+		// Keys without expiry are dumped with a TTL of 0 or -1, RESTORE takes 0 for them
+		ttl := time.Duration(max(entry.TTL, 0)) * time.Millisecond
+		if replace {
+			pipe.RestoreReplace(ctx, entry.Key, ttl, string(entry.Value))
+		} else {
+			pipe.Restore(ctx, entry.Key, ttl, string(entry.Value))
+		}
+		if entry.TTL <= 0 {
+			counts.Persistent++
+		}
+		if pipe.Len() >= redisRestoreBatch {
+			if err := exec(entry.DB); err != nil {
+				return counts, err
+			}
+		}
+	}
+
+	for db, pipe := range pipes {
+		if pipe.Len() > 0 {
+			if err := exec(db); err != nil {
+				return counts, err
+			}
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,132 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// RedisVerifyActivity restore drills a Redis dump: the scratch DB is flushed, the keys are
+// restored into it, the checks are run and the scratch DB is flushed again. Without checks the
+// restored keys are counted; keys that expire may be gone by then, so only the keys without a
+// TTL must be present.
+func (a *Activities) RedisVerifyActivity(ctx context.Context, input VerifyActivityInput) (*Verification, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("RedisVerifyActivity started", "jobId", input.Job.ID)
+	start := time.Now()
+
+	cfg, err := job.LoadAs[*job.RedisConfig](*input.Job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Redis config: %w", err)
+	}
+	if cfg.Verify == nil {
+		return nil, temporal.NewNonRetryableApplicationError("job has no verify config", "InvalidVerifyConfig", nil)
+	}
+	if isRedisRDB(input.FilePath) {
+		return nil, temporal.NewNonRetryableApplicationError("RDB snapshots cannot be restore drilled", "InvalidVerifyConfig", nil)
+	}
+	if cfg.MultiDatabase() {
+		// The keys of several DBs may collide in the one scratch DB
+		return nil, temporal.NewNonRetryableApplicationError(
+			"restore drills are only supported for dumps of a single DB", "InvalidVerifyConfig", nil)
+	}
+	opt, err := redisScratchOptions(cfg)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("invalid verify config: %v", err), "InvalidVerifyConfig", err)
+	}
+
+	dump, err := openRedisDump(input.FilePath, a.newProgressReporter(ctx, pathSize(input.FilePath)))
+	if err != nil {
+		return nil, err
+	}
+	defer dump.Close()
+
+	client := redis.NewClient(opt)
+	defer client.Close()
+
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to flush scratch DB: %w", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), verifyCleanupTimeout)
+		defer cancel()
+		if err := client.FlushDB(flushCtx).Err(); err != nil {
+			logger.Warn("Failed to flush scratch DB", "db", opt.DB, "error", err)
+		}
+	}()
+
+	counts, err := redisRestore(ctx, dump, func(int) (redis.UniversalClient, error) { return client, nil }, false)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := cfg.Verify.Checks
+	if len(checks) == 0 {
+		checks = []job.VerifyCheck{{Name: "keys", Pattern: "*", Min: &counts.Persistent, Max: &counts.Keys}}
+	}
+	v := runVerifyChecks(checks, start, func(check job.VerifyCheck) (int64, error) {
+		if check.Pattern == "" {
+			return 0, errors.New("redis checks need a pattern")
+		}
+		return redisCountKeys(ctx, client, check.Pattern)
+	})
+
+	logger.Info("RedisVerifyActivity completed", "verified", v.Verified, "checks", len(v.Checks))
+	return v, nil
+}
+
+// redisScratchOptions returns the client options of the scratch DB of a verify config, always a
+// standalone server. The scratch DB is flushed, so it must not be the DB the job backs up;
+// cluster and sentinel jobs need a connection string of their own for it.
+func redisScratchOptions(cfg *job.RedisConfig) (*redis.Options, error) {
+	if err := cfg.Verify.Validate(); err != nil {
+		return nil, err
+	}
+	var source *redis.Options
+	if cfg.Mode == "" || cfg.Mode == job.RedisModeStandalone {
+		var err error
+		if source, err = redis.ParseURL(cfg.ConnectionString); err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+	}
+	opt := source
+	if cfg.Verify.ConnectionString != "" {
+		var err error
+		if opt, err = redis.ParseURL(cfg.Verify.ConnectionString); err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+	} else if source == nil {
+		return nil, fmt.Errorf("%s jobs need a verify connection_string", cfg.Mode)
+	}
+	db, err := strconv.Atoi(cfg.Verify.Database)
+	if err != nil || db < 0 {
+		return nil, fmt.Errorf("invalid Redis database index %q", cfg.Verify.Database)
+	}
+	if source != nil && opt.Addr == source.Addr && db == source.DB {
+		return nil, fmt.Errorf("scratch DB %d is the job's DB", db)
+	}
+	scratch := *opt
+	scratch.DB = db
+	return &scratch, nil
+}
+
+// redisCountKeys counts the keys matching pattern with SCAN
+func redisCountKeys(ctx context.Context, client *redis.Client, pattern string) (int64, error) {
+	var count int64
+	iter := client.Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		count++
+	}
+	if err := iter.Err(); err != nil {
+		return 0, fmt.Errorf("SCAN failed: %w", err)
+	}
+	return count, nil
+}
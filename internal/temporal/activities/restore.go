@@ -0,0 +1,88 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RestoreTarget overrides where a backup is restored to, so it can be restored next to the
+// data it was taken from. Empty fields keep the value of the job config.
+type RestoreTarget struct {
+	// ConnectionString replaces the connection string of PostgreSQL, MySQL and Redis jobs
+	ConnectionString string `json:"connection_string,omitempty"`
+	// Database replaces the database of PostgreSQL, MySQL and MSSQL jobs, or the DB index of Redis jobs
+	Database string `json:"database,omitempty"`
+	// SourceDatabase selects the one database of a PostgreSQL cluster archive that is restored,
+	// into Database when that is set too
+	SourceDatabase string `json:"source_database,omitempty"`
+	// Bucket and Path replace the bucket and key or prefix of AWS S3 jobs
+	Bucket string `json:"bucket,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// TableName replaces the table of AWS DynamoDB jobs
+	TableName string `json:"table_name,omitempty"`
+	// Overwrite replaces existing data: pg_restore --clean, MSSQL WITH REPLACE, Redis RESTORE REPLACE.
+	// MySQL dumps drop their tables anyway, S3 objects and DynamoDB items are always overwritten.
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+type RestoreActivityInput struct {
+	Job    *job.Job      `json:"job"`
+	Target RestoreTarget `json:"target"`
+	// FilePath is the decrypted and decompressed backup written by RestoreDownloadActivity
+	FilePath string `json:"file_path"`
+	Name     string `json:"name"`
+}
+
+type RestoreActivityOutput struct {
+	// Items is the number of keys, items or objects restored; 0 for SQL restores
+	Items int64 `json:"items"`
+}
+
+// restoreFromFile runs cmd with the file at path on its standard input, decompressed when it is
+// gzipped, and heartbeats how much of it was consumed. Most restore tools print the statements they run, so only stderr is kept.
+func (a *Activities) restoreFromFile(ctx context.Context, cmd *exec.Cmd, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer file.Close()
+
+	var total int64
+	if fi, err := file.Stat(); err == nil {
+		total = fi.Size()
+	}
+	progress := a.newProgressReporter(ctx, total)
+
+	// Tar archives keep their gzip layer on download (see backupLayers), the tools want it removed
+	in := bufio.NewReader(io.TeeReader(file, progress))
+	var stdin io.Reader = in
+	if magic, _ := in.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("failed to read backup file: %w", err)
+		}
+		stdin = zr
+	}
+
+	var stderr strings.Builder
+	cmd.Stdin = stdin
+	cmd.Stderr = &stderr
+
+	// The tools stop reading while they build indexes and constraints, the keepalive heartbeats
+	// meanwhile
+	stop := progress.Keepalive()
+	defer stop()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w. Stderr: %s", cmd.Args[0], err, stderr.String())
+	}
+	progress.Done()
+	return nil
+}
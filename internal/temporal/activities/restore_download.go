@@ -0,0 +1,211 @@
+package activities
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+type RestoreDownloadActivityInput struct {
+	JobId    string `json:"job_id"`
+	BackupId string `json:"backup_id"`
+}
+
+type RestoreDownloadActivityOutput struct {
+	FilePath string `json:"file_path"`
+	Size     int64  `json:"size"`
+	// Name is the name of the backup without the compression and encryption suffixes
+	Name string `json:"name"`
+}
+
+// backupDownload is the API response with a presigned GET URL for a stored backup
+type backupDownload struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum"`
+}
+
+// RestoreDownloadActivity downloads a backup through a presigned URL from the API, checks it
+// against the stored checksum and decrypts and decompresses it on the way to TempDir. The
+// layers to undo are read from the suffixes of the backup name, so backups taken before the
+// job's compression or encryption settings changed are still restored correctly.
+func (a *Activities) RestoreDownloadActivity(ctx context.Context, input RestoreDownloadActivityInput) (*RestoreDownloadActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("RestoreDownloadActivity started", "jobId", input.JobId, "backupId", input.BackupId)
+
+	dl, err := a.backupDownloadURL(ctx, input.JobId, input.BackupId)
+	if err != nil {
+		return nil, err
+	}
+
+	name, enc, comp := backupLayers(dl.Name)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dl.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// The body is read for as long as the restore takes; the context bounds it instead
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("download returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	// The checksum covers the stored object, before decryption and decompression
+	hash := sha256.New()
+	progress := a.newProgressReporter(ctx, dl.Size)
+	raw := &contextReader{ctx: ctx, r: io.TeeReader(resp.Body, io.MultiWriter(hash, progress))}
+	var r io.Reader = raw
+
+	if enc != nil {
+		keys, err := a.restoreKeys()
+		if err != nil {
+			return nil, err
+		}
+		if r, err = enc.NewReader(r, keys, a.Config.Restore.Passphrase); err != nil {
+			return nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("failed to decrypt backup: %v", err), "DecryptionFailed", err)
+		}
+	}
+	if comp != nil {
+		zr, err := comp.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create decompression reader: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	if err := os.MkdirAll(a.Config.TempDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	filePath := filepath.Join(a.Config.TempDir, fmt.Sprintf("%s-restore-%s", input.JobId, filepath.Base(name)))
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer file.Close()
+
+	size, err := io.Copy(file, r)
+	if err == nil {
+		// Drain what the decoders left unread so the checksum covers the whole object
+		_, err = io.Copy(io.Discard, raw)
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+	if checksum := fmt.Sprintf("%x", hash.Sum(nil)); dl.Checksum != "" && checksum != dl.Checksum {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("checksum mismatch: expected %s, got %s", dl.Checksum, checksum)
+	}
+	progress.Done()
+
+	logger.Info("RestoreDownloadActivity completed", "filePath", filePath, "size", size,
+		"decrypted", enc != nil, "decompressed", comp != nil)
+
+	return &RestoreDownloadActivityOutput{FilePath: filePath, Size: size, Name: name}, nil
+}
+
+// backupDownloadURL asks the API for a presigned URL of a stored backup
+func (a *Activities) backupDownloadURL(ctx context.Context, jobId, backupId string) (*backupDownload, error) {
+	token, err := a.Auth.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth token: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/workspaces/%s/jobs/%s/backups/%s/download",
+		a.Config.API,
+		a.Hub.Workspace,
+		jobId,
+		backupId)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("backup %s not found", backupId), "BackupNotFound", nil)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result backupDownload
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &result, nil
+}
+
+// restoreKeys reads the private key files configured for restores
+func (a *Activities) restoreKeys() ([]string, error) {
+	var keys []string
+	for _, path := range a.Config.Restore.KeyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read restore key file: %w", err)
+		}
+		keys = append(keys, string(data))
+	}
+	if len(keys) == 0 {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"the backup is encrypted but no restore.key_files are configured", "MissingDecryptionKey", nil)
+	}
+	return keys, nil
+}
+
+// backupLayers splits the encryption and compression suffixes off a backup name and returns
+// the name of the original artifact with the encryptor and compressor that produced the
+// backup, or nil when a layer is absent. A ".gz" that leaves a ".tar" behind belongs to the
+// artifact itself, as in the "<job>.tar.gz" archives of the file based providers; restores
+// decompress such a layer when the artifact was a plain tar.
+func backupLayers(name string) (string, *encryptor, *compressor) {
+	var enc *encryptor
+	for _, e := range encryptors {
+		if strings.HasSuffix(name, e.Suffix) {
+			enc = &e
+			name = strings.TrimSuffix(name, e.Suffix)
+			break
+		}
+	}
+
+	var comp *compressor
+	for _, c := range compressors {
+		if base, ok := strings.CutSuffix(name, c.Suffix); ok && (c.Suffix != ".gz" || !strings.HasSuffix(base, ".tar")) {
+			comp = &c
+			name = base
+			break
+		}
+	}
+	return name, enc, comp
+}
@@ -0,0 +1,130 @@
+package activities
+
+import (
+	"agent/internal/config"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestBackupLayers(t *testing.T) {
+	tests := []struct {
+		name, plain string
+		enc, comp   bool
+	}{
+		{"job-1.sql", "job-1.sql", false, false},
+		{"job-1.sql.gz", "job-1.sql", false, true},
+		{"job-1.sql.zst.age", "job-1.sql", true, true},
+		{"job-1.json.gpg", "job-1.json", true, false},
+		// The archive of the file based providers is not a compression layer
+		{"job-1.tar.gz", "job-1.tar.gz", false, false},
+		{"job-1.tar.gz.xz", "job-1.tar.gz", false, true},
+		{"job-1.dir.tar.zst", "job-1.dir.tar", false, true},
+		{"job-1.dump.gz.age", "job-1.dump", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain, enc, comp := backupLayers(tt.name)
+			assert.Equal(t, tt.plain, plain)
+			assert.Equal(t, tt.enc, enc != nil)
+			assert.Equal(t, tt.comp, comp != nil)
+		})
+	}
+}
+
+// newRestoreServer serves the download API for backup-1 of job-1 and the stored object
+func newRestoreServer(t *testing.T, name string, object []byte, checksum string) *httptest.Server {
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/v1/workspaces/ws/jobs/job-1/backups/backup-1/download", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"url": srv.URL + "/object", "name": name, "size": len(object), "checksum": checksum,
+		})
+	})
+	mux.HandleFunc("/object", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(object)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRestoreDownloadActivity_DecryptsAndDecompresses(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "key.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0o600))
+
+	data := bytes.Repeat([]byte("INSERT INTO t VALUES (1);\n"), 100)
+	var object bytes.Buffer
+	ew, err := age.Encrypt(&object, identity.Recipient())
+	require.NoError(t, err)
+	zw := gzip.NewWriter(ew)
+	_, err = zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, ew.Close())
+
+	srv := newRestoreServer(t, "job-1.sql.gz.age", object.Bytes(), fmt.Sprintf("%x", sha256.Sum256(object.Bytes())))
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	tempDir := t.TempDir()
+	acts := &Activities{
+		Config: &config.Config{API: srv.URL, TempDir: tempDir,
+			Restore: config.RestoreConfig{KeyFiles: []string{keyFile}}},
+		Auth:       staticToken{},
+		Hub:        &config.HubConfig{Workspace: "ws"},
+		HTTPClient: srv.Client(),
+	}
+	env.RegisterActivity(acts.RestoreDownloadActivity)
+
+	val, err := env.ExecuteActivity(acts.RestoreDownloadActivity, RestoreDownloadActivityInput{JobId: "job-1", BackupId: "backup-1"})
+	require.NoError(t, err)
+
+	var res RestoreDownloadActivityOutput
+	require.NoError(t, val.Get(&res))
+	assert.Equal(t, "job-1.sql", res.Name)
+	assert.Equal(t, int64(len(data)), res.Size)
+	assert.Equal(t, tempDir, filepath.Dir(res.FilePath))
+
+	restored, err := os.ReadFile(res.FilePath)
+	require.NoError(t, err)
+	assert.Equal(t, data, restored)
+}
+
+func TestRestoreDownloadActivity_ChecksumMismatch(t *testing.T) {
+	srv := newRestoreServer(t, "job-1.sql", []byte("SELECT 1;\n"), "0000")
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	tempDir := t.TempDir()
+	acts := &Activities{
+		Config:     &config.Config{API: srv.URL, TempDir: tempDir},
+		Auth:       staticToken{},
+		Hub:        &config.HubConfig{Workspace: "ws"},
+		HTTPClient: srv.Client(),
+	}
+	env.RegisterActivity(acts.RestoreDownloadActivity)
+
+	_, err := env.ExecuteActivity(acts.RestoreDownloadActivity, RestoreDownloadActivityInput{JobId: "job-1", BackupId: "backup-1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+
+	// The corrupt download is not left behind
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
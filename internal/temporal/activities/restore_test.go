@@ -0,0 +1,59 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgreSQLWithDatabase(t *testing.T) {
+	assert.Equal(t, "postgres://user:pw@db:5432/restored?sslmode=disable",
+		postgreSQLWithDatabase("postgres://user:pw@db:5432/app?sslmode=disable", "restored"))
+	assert.Equal(t, `host=db dbname=app dbname='it\'s'`,
+		postgreSQLWithDatabase("host=db dbname=app", "it's"))
+}
+
+func TestPgArchiveFormat(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "toc.dat", Mode: 0o600, Size: 5}))
+	_, err := tw.Write([]byte("PGDMP"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	_, err = zw.Write(archive.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+	}{
+		{"job-1-restore-job-1.sql", []byte("CREATE TABLE t (id int);\n"), job.PostgreSQLFormatPlain},
+		{"job-1-restore-job-1.sql", nil, job.PostgreSQLFormatPlain},
+		{"job-1-restore-job-1.dump", []byte("PGDMP\x01\x0e\x00"), job.PostgreSQLFormatCustom},
+		{"job-1-restore-job-1.tar", archive.Bytes(), job.PostgreSQLFormatTar},
+		// Kept gzip layer of a compressed tar format archive
+		{"job-1-restore-job-1.tar.gz", gzipped.Bytes(), job.PostgreSQLFormatTar},
+		{"job-1-restore-job-1.dir.tar", archive.Bytes(), job.PostgreSQLFormatDirectory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			require.NoError(t, os.WriteFile(path, tt.data, 0o600))
+			format, err := pgArchiveFormat(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+		})
+	}
+}
@@ -17,13 +17,7 @@ type ScriptRunActivityInput struct {
 	Job *job.Job
 }
 
-type ScriptRunActivityOutput struct {
-	FilePath string
-	Size     int64
-	Checksum string
-	Name     string
-	MimeType string
-}
+type ScriptRunActivityOutput = DownloadActivityOutput
 
 func (a *Activities) ScriptRunActivity(ctx context.Context, input ScriptRunActivityInput) (*ScriptRunActivityOutput, error) {
 	logger := activity.GetLogger(ctx)
@@ -35,8 +29,11 @@ func (a *Activities) ScriptRunActivity(ctx context.Context, input ScriptRunActiv
 
 	config := input.Job.Script
 
-	// Create a temp file to store the stdout
-	tmpFile, err := os.CreateTemp("", "backup-script-*.dat")
+	// Create a temp file to store the stdout, named after the job so the janitor knows its owner
+	if err := os.MkdirAll(a.Config.TempDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(a.Config.TempDir, fmt.Sprintf("%s-script-*.dat", input.Job.ID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
@@ -48,7 +45,11 @@ func (a *Activities) ScriptRunActivity(ctx context.Context, input ScriptRunActiv
 	}
 
 	// Capture stdout to file
-	cmd.Stdout = tmpFile
+	// Scripts may print nothing for a while, the keepalive heartbeats meanwhile
+	progress := a.newProgressReporter(ctx, 0)
+	cmd.Stdout = io.MultiWriter(tmpFile, progress)
+	stop := progress.Keepalive()
+	defer stop()
 
 	// Capture stderr for logging
 	stderrPipe, err := cmd.StderrPipe()
@@ -67,6 +68,7 @@ func (a *Activities) ScriptRunActivity(ctx context.Context, input ScriptRunActiv
 		logger.Error("Command execution failed", "error", err, "stderr", string(stderrOutput))
 		return nil, fmt.Errorf("command execution failed: %s: %w", string(stderrOutput), err)
 	}
+	progress.Done()
 
 	// Calculate size and checksum
 	info, err := tmpFile.Stat()
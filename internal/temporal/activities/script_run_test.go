@@ -1,8 +1,11 @@
 package activities
 
 import (
+	"agent/internal/config"
 	"agent/internal/job"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,7 +18,8 @@ func TestScriptRunActivity(t *testing.T) {
 
 	// Mock Activities struct
 	// We don't need actual services for this test as ScriptRunActivity doesn't use them
-	acts := &Activities{}
+	tempDir := t.TempDir()
+	acts := &Activities{Config: &config.Config{TempDir: tempDir}}
 	env.RegisterActivity(acts.ScriptRunActivity)
 
 	// Create a dummy script that prints to stdout
@@ -30,7 +34,9 @@ func TestScriptRunActivity(t *testing.T) {
 	os.Chmod(tmpScript.Name(), 0755)
 
 	jobConfig := &job.Job{
-		ID: "test-job-1",
+		ID:       "test-job-1",
+		Provider: job.JobProviderScript,
+		Config:   &job.ScriptConfig{Command: tmpScript.Name()},
 		Script: &job.ScriptConfig{
 			Command: tmpScript.Name(),
 		},
@@ -48,7 +54,8 @@ func TestScriptRunActivity(t *testing.T) {
 	assert.NoError(t, err)
 
 	assert.FileExists(t, res.FilePath)
-	defer os.Remove(res.FilePath)
+	assert.Equal(t, tempDir, filepath.Dir(res.FilePath))
+	assert.True(t, strings.HasPrefix(filepath.Base(res.FilePath), "test-job-1-"))
 
 	// Verify content
 	outContent, err := os.ReadFile(res.FilePath)
@@ -61,7 +61,7 @@ func (a *Activities) SFTPDownloadActivity(ctx context.Context, input SFTPDownloa
 
 	args = append(args, targetURL)
 
-	output, err := exec.CommandContext(ctx, "curl", args...).CombinedOutput()
+	output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "curl", args...), tempFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("transfer failed: %w, output: %s", err, string(output))
 	}
@@ -0,0 +1,376 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/redis/go-redis/v9"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// sizeEstimator returns the expected size in bytes of the uncompressed backup of a job
+type sizeEstimator func(a *Activities, ctx context.Context, j *job.Job) (int64, error)
+
+// sizeEstimators are the providers that can tell the size of a backup before taking it.
+// Backups of other providers start without a disk space check.
+var sizeEstimators = map[job.Provider]sizeEstimator{
+	job.JobProviderPostgreSQL:  (*Activities).estimatePostgreSQL,
+	job.JobProviderMySQL:       (*Activities).estimateMySQL,
+	job.JobProviderAWSS3:       (*Activities).estimateAWSS3,
+	job.JobProviderAWSDynamoDB: (*Activities).estimateAWSDynamoDB,
+	job.JobProviderRedis:       (*Activities).estimateRedis,
+}
+
+// tempSpace tracks the backups running on this worker and the TempDir space reserved for them,
+// so two large backups do not both start when only one of them fits, and the janitor leaves
+// their files alone
+type tempSpace struct {
+	mu       sync.Mutex
+	reserved map[string]reservation
+}
+
+type reservation struct {
+	jobId string
+	bytes int64
+}
+
+// reserve records n bytes for the backup id of job jobId once check accepts them. check is
+// called with the bytes reserved by the other backups, under the lock so concurrent
+// reservations are serialized.
+func (s *tempSpace) reserve(id, jobId string, n int64, check func(committed int64) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var committed int64
+	for other, r := range s.reserved {
+		if other != id {
+			committed += r.bytes
+		}
+	}
+	if err := check(committed); err != nil {
+		return err
+	}
+
+	if s.reserved == nil {
+		s.reserved = make(map[string]reservation)
+	}
+	s.reserved[id] = reservation{jobId: jobId, bytes: n}
+	return nil
+}
+
+// release frees the space reserved for id and returns its size
+func (s *tempSpace) release(id string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.reserved[id].bytes
+	delete(s.reserved, id)
+	return n
+}
+
+// owned reports whether a TempDir entry belongs to a running backup. Activities name their
+// files and directories after the job: "<job>.sql", "<job>-<name>", "<job>-git-clone-123".
+func (s *tempSpace) owned(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.reserved {
+		if name == r.jobId || strings.HasPrefix(name, r.jobId+".") || strings.HasPrefix(name, r.jobId+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// TempFileInUse reports whether the TempDir entry with the given base name belongs to a backup
+// that is running on this worker
+func (a *Activities) TempFileInUse(name string) bool {
+	return a.space.owned(name)
+}
+
+type TempSpaceReserveActivityInput struct {
+	Job      *job.Job `json:"job"`
+	BackupId string   `json:"backup_id"`
+}
+
+type TempSpaceReserveActivityOutput struct {
+	Estimate int64 `json:"estimate"`
+	Reserved int64 `json:"reserved"`
+}
+
+// TempSpaceReserveActivity estimates the size of a backup and reserves room for it in TempDir.
+// The reservation also marks the files of the backup as in use for the janitor.
+// It fails with a non-retryable InsufficientDiskSpace error when the backup would not fit in the
+// free disk space or the temp_dir_max_bytes quota, counting the space reserved by the other
+// backups running on this worker. It must run on the same worker as the backup, in its session.
+func (a *Activities) TempSpaceReserveActivity(ctx context.Context, input TempSpaceReserveActivityInput) (*TempSpaceReserveActivityOutput, error) {
+	logger := activity.GetLogger(ctx)
+
+	// The backup is recorded even without an estimate, so its files are known to be in use
+	untracked := func(int64) error { return nil }
+
+	estimator, ok := sizeEstimators[input.Job.Provider]
+	if !ok {
+		logger.Debug("No size estimate for provider, skipping disk space check", "provider", input.Job.Provider)
+		return &TempSpaceReserveActivityOutput{}, a.space.reserve(input.BackupId, input.Job.ID, 0, untracked)
+	}
+	estimate, err := estimator(a, ctx, input.Job)
+	if err != nil {
+		// The estimate only protects the host, it must not block the backup itself
+		logger.Warn("Failed to estimate backup size, skipping disk space check", "error", err)
+		return &TempSpaceReserveActivityOutput{}, a.space.reserve(input.BackupId, input.Job.ID, 0, untracked)
+	}
+
+	// The original and its compressed or encrypted copy exist side by side for a while
+	need := estimate
+	if input.Job.Compression.Enabled || input.Job.Encryption.Enabled {
+		need *= 2
+	}
+
+	if err := os.MkdirAll(a.Config.TempDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	err = a.space.reserve(input.BackupId, input.Job.ID, need, func(committed int64) error {
+		// Reservations include what their backups have already written to TempDir
+		used := pathSize(a.Config.TempDir)
+
+		if quota := a.Config.TempDirMaxBytes; quota > 0 {
+			if available := quota - max(used, committed); need > available {
+				return insufficientSpaceError(need, available, "temp_dir_max_bytes")
+			}
+		}
+
+		free, err := diskFree(a.Config.TempDir)
+		if err != nil {
+			logger.Warn("Failed to read free disk space", "error", err)
+			return nil
+		}
+		if available := int64(free) - max(committed-used, 0); need > available {
+			return insufficientSpaceError(need, available, "free disk space")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Reserved temp space", "backupId", input.BackupId, "estimate", estimate, "reserved", need)
+	return &TempSpaceReserveActivityOutput{Estimate: estimate, Reserved: need}, nil
+}
+
+type TempSpaceReleaseActivityInput struct {
+	BackupId string `json:"backup_id"`
+}
+
+// TempSpaceReleaseActivity frees the space reserved by TempSpaceReserveActivity
+func (a *Activities) TempSpaceReleaseActivity(ctx context.Context, input TempSpaceReleaseActivityInput) error {
+	n := a.space.release(input.BackupId)
+	activity.GetLogger(ctx).Debug("Released temp space", "backupId", input.BackupId, "reserved", n)
+	return nil
+}
+
+func insufficientSpaceError(need, available int64, limit string) error {
+	return temporal.NewNonRetryableApplicationError(
+		fmt.Sprintf("backup needs about %d bytes in the temp directory but only %d bytes are left within the %s",
+			need, max(available, 0), limit),
+		"InsufficientDiskSpace", nil)
+}
+
+// estimatePostgreSQL returns the size of the database on disk. Cluster and base backups hold
+// the dumps or tars and their archive at the same time, so they count twice.
+func (a *Activities) estimatePostgreSQL(ctx context.Context, j *job.Job) (int64, error) {
+	cfg, err := job.LoadAs[*job.PostgreSQLConfig](*j)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load PostgreSQL config: %w", err)
+	}
+	switch cfg.Method {
+	case job.PostgreSQLMethodWAL:
+		// The WAL retained by the slot is not known up front, and usually small
+		return 0, nil
+	case job.PostgreSQLMethodBaseBackup:
+		// The tars of pg_basebackup and their archive exist side by side
+		cmd := exec.CommandContext(ctx, "psql", cfg.ConnectionString, "-At", "-c",
+			"SELECT sum(pg_database_size(datname)) FROM pg_database")
+		output, err := cmd.Output()
+		if err != nil {
+			return 0, fmt.Errorf("psql failed: %w", err)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+		return 2 * size, err
+	}
+	if cfg.Cluster {
+		databases, err := postgreSQLClusterDatabases(ctx, cfg)
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		for _, db := range databases {
+			total += db.Size
+		}
+		return 2 * total, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "psql", cfg.ConnectionString, "-At", "-c",
+		"SELECT pg_database_size(current_database())")
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("psql failed: %w", err)
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+}
+
+// estimateMySQL returns the size of the tables and indexes of the databases the job dumps, or of
+// the binary logs a binlog job archives
+func (a *Activities) estimateMySQL(ctx context.Context, j *job.Job) (int64, error) {
+	cfg, err := job.LoadAs[*job.MySQLConfig](*j)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load MySQL config: %w", err)
+	}
+	conn, err := parseMySQLConnectionString(cfg.ConnectionString)
+	if err != nil {
+		return 0, err
+	}
+
+	// Binlog jobs with a saved position archive the binary logs from there on
+	if cfg.Method == job.MySQLMethodBinlog {
+		var state mySQLBinlogState
+		found, err := a.loadBackupState(j.ID, &state)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			logs, err := a.mySQLBinaryLogs(ctx, j.ID, conn, false)
+			if err != nil {
+				return 0, err
+			}
+			since, _ := mySQLBinlogsSince(logs, state)
+			var total int64
+			for _, log := range since {
+				total += log.Size
+			}
+			return total, nil
+		}
+	}
+
+	schemas := "table_schema = DATABASE()"
+	switch {
+	case cfg.AllDatabases:
+		schemas = "table_schema NOT IN ('information_schema', 'performance_schema', 'sys')"
+	case len(cfg.Databases) > 0:
+		quoted := make([]string, len(cfg.Databases))
+		for i, db := range cfg.Databases {
+			quoted[i] = mySQLQuoteLiteral(db)
+		}
+		schemas = "table_schema IN (" + strings.Join(quoted, ", ") + ")"
+	}
+	extra := []string{"-N", "-B", "-e", "SELECT COALESCE(SUM(data_length + index_length), 0) FROM information_schema.tables WHERE " + schemas}
+	if !cfg.MultiDatabase() {
+		extra = append(extra, conn.dbName)
+	}
+
+	args, cleanup, err := a.mySQLArgs(j.ID, conn, extra...)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+
+	output, err := exec.CommandContext(ctx, a.mySQLTool("mysql"), args...).Output()
+	if err != nil {
+		return 0, fmt.Errorf("mysql failed: %w", err)
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+}
+
+// estimateAWSS3 returns the total size of the objects under the job path
+func (a *Activities) estimateAWSS3(ctx context.Context, j *job.Job) (int64, error) {
+	_, _, objects, err := s3ListJobObjects(ctx, j)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, obj := range objects {
+		total += aws.ToInt64(obj.Size)
+	}
+	return total, nil
+}
+
+// estimateAWSDynamoDB returns the TableSizeBytes of the tables, which DynamoDB updates about
+// every six hours. Backups that stay in AWS only write a small record.
+func (a *Activities) estimateAWSDynamoDB(ctx context.Context, j *job.Job) (int64, error) {
+	cfg, err := job.LoadAs[*job.AWSDynamoDBConfig](*j)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load DynamoDB config: %w", err)
+	}
+	switch {
+	case cfg.BackupMethod == job.DynamoDBMethodOnDemand,
+		cfg.BackupMethod == job.DynamoDBMethodExportS3 && !cfg.ExportDownload:
+		return 0, nil
+	}
+	client, err := newDynamoDBClient(ctx, cfg)
+	if err != nil {
+		return 0, err
+	}
+	tables, err := dynamoDBTables(ctx, client, cfg)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, table := range tables {
+		out, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		if err != nil {
+			return 0, fmt.Errorf("failed to describe table %s: %w", table, err)
+		}
+		total += aws.ToInt64(out.Table.TableSizeBytes)
+	}
+	if cfg.MultiTable() {
+		// The dumps of the tables and their archive are on disk together
+		total *= 2
+	}
+	return total, nil
+}
+
+// estimateRedis returns the memory used by the dataset of every node the job dumps
+func (a *Activities) estimateRedis(ctx context.Context, j *job.Job) (int64, error) {
+	cfg, err := job.LoadAs[*job.RedisConfig](*j)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load Redis config: %w", err)
+	}
+	nodes, err := redisNodes(ctx, cfg)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, node := range nodes {
+		size, err := redisDatasetSize(ctx, node)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", node.Addr, err)
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// redisDatasetSize returns the used_memory_dataset of a node
+func redisDatasetSize(ctx context.Context, node *redis.Options) (int64, error) {
+	client := redis.NewClient(node)
+	defer client.Close()
+
+	info, err := client.Info(ctx, "memory").Result()
+	if err != nil {
+		return 0, fmt.Errorf("INFO failed: %w", err)
+	}
+	for _, line := range strings.Split(info, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "used_memory_dataset:"); ok {
+			return strconv.ParseInt(value, 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("INFO memory has no used_memory_dataset")
+}
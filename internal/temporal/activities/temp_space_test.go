@@ -0,0 +1,98 @@
+package activities
+
+import (
+	"agent/internal/config"
+	"agent/internal/job"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestTempSpaceReserveActivity_Quota(t *testing.T) {
+	orig, had := sizeEstimators[job.JobProviderHTTP]
+	sizeEstimators[job.JobProviderHTTP] = func(a *Activities, ctx context.Context, j *job.Job) (int64, error) {
+		return 300, nil
+	}
+	t.Cleanup(func() {
+		if had {
+			sizeEstimators[job.JobProviderHTTP] = orig
+		} else {
+			delete(sizeEstimators, job.JobProviderHTTP)
+		}
+	})
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	acts := &Activities{Config: &config.Config{TempDir: t.TempDir(), TempDirMaxBytes: 1000}}
+	env.RegisterActivity(acts.TempSpaceReserveActivity)
+	env.RegisterActivity(acts.TempSpaceReleaseActivity)
+
+	compressed := &job.Job{ID: "job-1", Provider: job.JobProviderHTTP}
+	compressed.Compression.Enabled = true
+
+	// The compressed copy doubles the space needed
+	val, err := env.ExecuteActivity(acts.TempSpaceReserveActivity, TempSpaceReserveActivityInput{Job: compressed, BackupId: "backup-1"})
+	require.NoError(t, err)
+	var res TempSpaceReserveActivityOutput
+	require.NoError(t, val.Get(&res))
+	assert.Equal(t, int64(300), res.Estimate)
+	assert.Equal(t, int64(600), res.Reserved)
+
+	// 600 of the 1000 byte quota are reserved by the first backup
+	_, err = env.ExecuteActivity(acts.TempSpaceReserveActivity, TempSpaceReserveActivityInput{Job: compressed, BackupId: "backup-2"})
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr))
+	assert.Equal(t, "InsufficientDiskSpace", appErr.Type())
+	assert.True(t, appErr.NonRetryable())
+
+	plain := &job.Job{ID: "job-2", Provider: job.JobProviderHTTP}
+	_, err = env.ExecuteActivity(acts.TempSpaceReserveActivity, TempSpaceReserveActivityInput{Job: plain, BackupId: "backup-3"})
+	require.NoError(t, err)
+
+	_, err = env.ExecuteActivity(acts.TempSpaceReleaseActivity, TempSpaceReleaseActivityInput{BackupId: "backup-1"})
+	require.NoError(t, err)
+	_, err = env.ExecuteActivity(acts.TempSpaceReserveActivity, TempSpaceReserveActivityInput{Job: compressed, BackupId: "backup-2"})
+	require.NoError(t, err)
+}
+
+func TestTempSpaceReserveActivity_NoEstimate(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	acts := &Activities{Config: &config.Config{TempDir: t.TempDir(), TempDirMaxBytes: 1}}
+	env.RegisterActivity(acts.TempSpaceReserveActivity)
+
+	val, err := env.ExecuteActivity(acts.TempSpaceReserveActivity, TempSpaceReserveActivityInput{
+		Job: &job.Job{ID: "job-1", Provider: job.JobProviderGit}, BackupId: "backup-1",
+	})
+	require.NoError(t, err)
+	var res TempSpaceReserveActivityOutput
+	require.NoError(t, val.Get(&res))
+	assert.Zero(t, res.Reserved)
+}
+
+func TestTempFileInUse(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestActivityEnvironment()
+	acts := &Activities{Config: &config.Config{TempDir: t.TempDir()}}
+	env.RegisterActivity(acts.TempSpaceReserveActivity)
+	env.RegisterActivity(acts.TempSpaceReleaseActivity)
+
+	_, err := env.ExecuteActivity(acts.TempSpaceReserveActivity, TempSpaceReserveActivityInput{
+		Job: &job.Job{ID: "job-1", Provider: job.JobProviderGit}, BackupId: "backup-1",
+	})
+	require.NoError(t, err)
+
+	assert.True(t, acts.TempFileInUse("job-1.tar.gz"))
+	assert.True(t, acts.TempFileInUse("job-1-git-clone-123"))
+	assert.False(t, acts.TempFileInUse("job-10.tar.gz"))
+	assert.False(t, acts.TempFileInUse("job-2.sql"))
+
+	_, err = env.ExecuteActivity(acts.TempSpaceReleaseActivity, TempSpaceReleaseActivityInput{BackupId: "backup-1"})
+	require.NoError(t, err)
+	assert.False(t, acts.TempFileInUse("job-1.tar.gz"))
+}
@@ -0,0 +1,97 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// verifyCleanupTimeout bounds dropping the scratch target after a drill, which also runs when
+// the activity was cancelled
+const verifyCleanupTimeout = time.Minute
+
+type VerifyActivityInput struct {
+	Job *job.Job `json:"job"`
+	// FilePath is the dump written by the provider's dump activity, before compression
+	FilePath string `json:"file_path"`
+}
+
+// Verification is the result of a restore drill, reported to the API with the backup confirmation
+type Verification struct {
+	Verified bool                `json:"verified"`
+	Checks   []VerifyCheckResult `json:"checks,omitempty"`
+	// Error is why the dump could not be restored into the scratch target or checked
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type VerifyCheckResult struct {
+	Name   string `json:"name"`
+	Value  int64  `json:"value"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// runVerifyChecks evaluates each check with value and returns the verification, which is
+// verified when every check passed
+func runVerifyChecks(checks []job.VerifyCheck, start time.Time, value func(job.VerifyCheck) (int64, error)) *Verification {
+	v := &Verification{Verified: true}
+	for _, check := range checks {
+		result := VerifyCheckResult{Name: check.Name}
+		n, err := value(check)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Value = n
+			result.Passed = (check.Min == nil || n >= *check.Min) && (check.Max == nil || n <= *check.Max)
+		}
+		v.Verified = v.Verified && result.Passed
+		v.Checks = append(v.Checks, result)
+	}
+	v.DurationMs = time.Since(start).Milliseconds()
+	return v
+}
+
+// queryCount runs a query with a SQL client whose output is only the result, and parses the
+// first column of the first row as a number
+func queryCount(ctx context.Context, name string, args ...string) (int64, error) {
+	out, err := querySQL(ctx, name, args...)
+	if err != nil {
+		return 0, err
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	field, _, _ := strings.Cut(line, "\t")
+	n, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("query did not return a number: %q", line)
+	}
+	return n, nil
+}
+
+// querySQL runs a query with a SQL client and returns its output
+func querySQL(ctx context.Context, name string, args ...string) (string, error) {
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w. Stderr: %s", name, err, stderr.String())
+	}
+	return string(out), nil
+}
+
+// runSQL runs a SQL client for its side effects, keeping stderr for the error
+func runSQL(ctx context.Context, name string, args ...string) error {
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w. Stderr: %s", name, err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package activities
+
+import (
+	"agent/internal/job"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunVerifyChecks(t *testing.T) {
+	one, ten := int64(1), int64(10)
+	values := map[string]int64{"users": 5, "orders": 0, "events": 20}
+	value := func(check job.VerifyCheck) (int64, error) {
+		if check.Name == "broken" {
+			return 0, errors.New("relation does not exist")
+		}
+		return values[check.Name], nil
+	}
+
+	v := runVerifyChecks([]job.VerifyCheck{
+		{Name: "users", Min: &one, Max: &ten},
+		{Name: "events"},
+	}, time.Now(), value)
+	assert.True(t, v.Verified)
+	assert.Equal(t, []VerifyCheckResult{
+		{Name: "users", Value: 5, Passed: true},
+		{Name: "events", Value: 20, Passed: true},
+	}, v.Checks)
+
+	v = runVerifyChecks([]job.VerifyCheck{
+		{Name: "orders", Min: &one},
+		{Name: "events", Max: &ten},
+		{Name: "broken"},
+	}, time.Now(), value)
+	assert.False(t, v.Verified)
+	assert.Equal(t, []VerifyCheckResult{
+		{Name: "orders", Value: 0, Passed: false},
+		{Name: "events", Value: 20, Passed: false},
+		{Name: "broken", Error: "relation does not exist"},
+	}, v.Checks)
+}
+
+func TestPostgreSQLDatabase(t *testing.T) {
+	assert.Equal(t, "app", postgreSQLDatabase("postgres://user:pw@db:5432/app?sslmode=disable"))
+	assert.Equal(t, "", postgreSQLDatabase("postgres://db:5432"))
+	assert.Equal(t, "it's", postgreSQLDatabase(`host=db dbname=app dbname='it\'s'`))
+}
+
+func TestPostgreSQLSameDatabase(t *testing.T) {
+	jobConnStr := "postgres://user:pw@db.internal:5432/app?sslmode=disable"
+	// A verify server written differently is still the production server
+	assert.True(t, postgreSQLSameDatabase("host=db.internal port=5432 user=admin sslmode=require", "app", jobConnStr))
+	assert.True(t, postgreSQLSameDatabase("postgresql://admin@DB.internal/other?dbname=postgres", "app", jobConnStr))
+	assert.False(t, postgreSQLSameDatabase("host=db.internal port=5432", "app_verify", jobConnStr))
+	assert.False(t, postgreSQLSameDatabase("host=db.internal port=5433", "app", jobConnStr))
+	assert.False(t, postgreSQLSameDatabase("postgres://scratch.internal/app", "app", jobConnStr))
+
+	// Unset hosts, ports and databases are libpq's defaults
+	assert.True(t, postgreSQLSameDatabase("host=/var/run/postgresql", "app", "postgres://localhost:5432/app"))
+	assert.True(t, postgreSQLSameDatabase("postgres://127.0.0.1", "app", "user=app"))
+}
+
+func TestRedisScratchOptions(t *testing.T) {
+	cfg := &job.RedisConfig{
+		ConnectionString: "redis://db:6379/0",
+		Verify:           &job.VerifyConfig{Enabled: true, Database: "15"},
+	}
+	opt, err := redisScratchOptions(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "db:6379", opt.Addr)
+	assert.Equal(t, 15, opt.DB)
+
+	// The scratch DB is flushed, it must not be the one that is backed up
+	cfg.Verify.Database = "0"
+	_, err = redisScratchOptions(cfg)
+	assert.Error(t, err)
+
+	// The same index on another server is fine
+	cfg.Verify.ConnectionString = "redis://scratch:6379"
+	opt, err = redisScratchOptions(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "scratch:6379", opt.Addr)
+	assert.Equal(t, 0, opt.DB)
+
+	cfg.Verify.Database = "one"
+	_, err = redisScratchOptions(cfg)
+	assert.Error(t, err)
+
+	// Cluster and sentinel jobs restore into a standalone scratch server of their own
+	cluster := &job.RedisConfig{
+		ConnectionString: "redis://n1:7000?addr=n2:7000",
+		Mode:             job.RedisModeCluster,
+		Verify:           &job.VerifyConfig{Enabled: true, Database: "0"},
+	}
+	_, err = redisScratchOptions(cluster)
+	assert.Error(t, err)
+	cluster.Verify.ConnectionString = "redis://scratch:6379"
+	opt, err = redisScratchOptions(cluster)
+	require.NoError(t, err)
+	assert.Equal(t, "scratch:6379", opt.Addr)
+}
@@ -52,7 +52,7 @@ func (a *Activities) WebDAVDownloadActivity(ctx context.Context, input WebDAVDow
 		u.String(),
 	}
 
-	output, err := exec.CommandContext(ctx, "curl", args...).CombinedOutput()
+	output, err := a.runWithProgress(ctx, exec.CommandContext(ctx, "curl", args...), tempFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("transfer failed: %w, output: %s", err, string(output))
 	}
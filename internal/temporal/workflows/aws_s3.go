@@ -1,36 +0,0 @@
-package workflows
-
-import (
-	"agent/internal"
-	"agent/internal/temporal/activities"
-
-	"go.temporal.io/sdk/workflow"
-)
-
-func AWSS3BackupWorkflow(ctx workflow.Context, input GeneralWorkflowInput) error {
-	logger := workflow.GetLogger(ctx)
-	logger.Info("AWSS3BackupWorkflow started", "jobId", input.JobId)
-
-	ctx = workflow.WithActivityOptions(ctx, defaultActivityOptions)
-
-	var getJobOut activities.GetJobActivityOutput
-	if err := workflow.ExecuteActivity(ctx, internal.ActivityNameGetJob,
-		activities.GetJobActivityInput{JobId: input.JobId}).Get(ctx, &getJobOut); err != nil {
-		return err
-	}
-
-	var backupOut activities.BackupRequestActivityOutput
-	if err := workflow.ExecuteActivity(ctx, internal.ActivityNameBackupRequest,
-		activities.BackupRequestActivityInput{Job: getJobOut.Job}).Get(ctx, &backupOut); err != nil {
-		return err
-	}
-
-	var dlOut activities.DownloadActivityOutput
-	if err := workflow.ExecuteActivity(ctx, internal.ActivityNameAWSS3Download,
-		activities.AWSS3DownloadActivityInput{Job: getJobOut.Job}).Get(ctx, &dlOut); err != nil {
-		return err
-	}
-
-	return ProcessAndUpload(ctx, getJobOut.Job, input.JobId, backupOut.ID.String(),
-		dlOut.FilePath, dlOut.Size, dlOut.Checksum, dlOut.Name, dlOut.MimeType)
-}
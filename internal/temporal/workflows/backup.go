@@ -0,0 +1,168 @@
+package workflows
+
+import (
+	"agent/internal"
+	"agent/internal/job"
+	"agent/internal/temporal/activities"
+	"encoding/json"
+	"fmt"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// BackupWorkflow runs GetJob → BackupRequest → provider download → restore drill →
+// processAndUpload → BackupConfirm for any registered provider, with everything after GetJob
+// pinned to one worker by a session. The restore drill only runs when the job config enables
+// it, the state of incremental jobs is saved after the confirmation. The provider is
+// taken from the input, falling back to the workflow type when started through one of the
+// legacy per-provider workflow names.
+func BackupWorkflow(ctx workflow.Context, input GeneralWorkflowInput) error {
+	logger := workflow.GetLogger(ctx)
+
+	provider := job.Provider(input.Provider)
+	if provider == "" {
+		provider, _ = providerForWorkflow(workflow.GetInfo(ctx).WorkflowType.Name)
+	}
+	spec, ok := LookupProvider(provider)
+	if !ok {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("unsupported provider: %q", provider), "UnsupportedProvider", nil)
+	}
+
+	logger.Info("BackupWorkflow started", "jobId", input.JobId, "provider", provider)
+
+	ctx, err := trackProgress(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = workflow.WithActivityOptions(ctx, defaultActivityOptions)
+
+	var getJobOut activities.GetJobActivityOutput
+	if err := workflow.ExecuteActivity(ctx, internal.ActivityNameGetJob,
+		activities.GetJobActivityInput{JobId: input.JobId}).Get(ctx, &getJobOut); err != nil {
+		return err
+	}
+
+	// Download, compression, encryption and upload all scale with the size of the backup,
+	// so they share the provider timeout; heartbeats still catch hung activities early
+	transferOptions := transferActivityOptions(spec, getJobOut.Job)
+	transferCtx := workflow.WithActivityOptions(ctx, transferOptions)
+
+	// Each step leaves its output in TempDir, so the chain runs on one worker through a session.
+	// The backup is requested and its state saved on that worker as well, since the kind of an
+	// incremental backup depends on the state in its StateDir. A pipeline restarted on a new
+	// session requests a new backup, after failing the one of the lost worker.
+	_, err = runInSession(transferCtx, transferOptions, func(sessionCtx workflow.Context) (string, error) {
+		var backupOut activities.BackupRequestActivityOutput
+		if err := workflow.ExecuteActivity(workflow.WithActivityOptions(sessionCtx, defaultActivityOptions),
+			internal.ActivityNameBackupRequest, activities.BackupRequestActivityInput{Job: getJobOut.Job},
+		).Get(sessionCtx, &backupOut); err != nil {
+			return BackupStageRequest, err
+		}
+		backupId := backupOut.ID.String()
+
+		var verification *activities.Verification
+		var state json.RawMessage
+		stage, err := func() (string, error) {
+			if getJobOut.Job.Streaming {
+				if activities.SupportsStreaming(getJobOut.Job) {
+					return streamBackup(sessionCtx, getJobOut.Job, input.JobId, backupId)
+				}
+				logger.Info("Provider does not support streaming, using temp files", "provider", provider)
+			}
+
+			setStage(sessionCtx, BackupStagePreflight)
+			if err := workflow.ExecuteActivity(sessionCtx, internal.ActivityNameTempSpaceReserve,
+				activities.TempSpaceReserveActivityInput{Job: getJobOut.Job, BackupId: backupId},
+			).Get(sessionCtx, nil); err != nil {
+				return BackupStagePreflight, err
+			}
+			defer releaseTempSpace(sessionCtx, backupId)
+
+			setStage(sessionCtx, BackupStageDownload)
+			var dlOut activities.DownloadActivityOutput
+			if err := workflow.ExecuteActivity(sessionCtx, spec.ActivityName,
+				spec.NewInput(getJobOut.Job)).Get(sessionCtx, &dlOut); err != nil {
+				return BackupStageDownload, err
+			}
+
+			verification = verifyDump(sessionCtx, spec, getJobOut.Job, dlOut.FilePath)
+			state = dlOut.State
+
+			return processAndUpload(sessionCtx, getJobOut.Job, input.JobId, backupId,
+				dlOut.FilePath, dlOut.Size, dlOut.Checksum, dlOut.Name, dlOut.MimeType)
+		}()
+		if err != nil {
+			return stage, FailBackup(transferCtx, input.JobId, backupId, stage, err)
+		}
+
+		if err := confirmBackup(transferCtx, input.JobId, backupId, verification); err != nil {
+			return BackupStageConfirm, err
+		}
+		saveBackupState(sessionCtx, getJobOut.Job.ID, state)
+		return "", nil
+	})
+	return err
+}
+
+// saveBackupState saves the state the next backup of an incremental job continues from, once
+// the backup taken with it is confirmed. ctx is the session context of the worker that took the
+// backup, the state is kept in its StateDir. A failure is only logged: the next backup then
+// continues from the previous state, which repeats data but loses none.
+func saveBackupState(ctx workflow.Context, jobId string, state json.RawMessage) {
+	if state == nil {
+		return
+	}
+	if err := workflow.ExecuteActivity(ctx, internal.ActivityNameBackupStateSave,
+		activities.BackupStateSaveActivityInput{JobId: jobId, State: state}).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to save backup state", "error", err)
+	}
+}
+
+// verifyDump restore drills the dump when the provider supports it and the job config enables
+// it. The drill runs once: a drill that fails or cannot run marks the backup as unverified, and
+// never fails it, so retrying would only delay the upload.
+func verifyDump(ctx workflow.Context, spec ProviderSpec, j *job.Job, filePath string) *activities.Verification {
+	if spec.VerifyActivityName == "" || job.VerifyConfigOf(j) == nil {
+		return nil
+	}
+	setStage(ctx, BackupStageVerify)
+
+	opts := workflow.GetActivityOptions(ctx)
+	opts.RetryPolicy = &temporal.RetryPolicy{MaximumAttempts: 1}
+	ctx = workflow.WithActivityOptions(ctx, opts)
+
+	var out activities.Verification
+	if err := workflow.ExecuteActivity(ctx, spec.VerifyActivityName,
+		activities.VerifyActivityInput{Job: j, FilePath: filePath}).Get(ctx, &out); err != nil {
+		workflow.GetLogger(ctx).Warn("Restore drill failed, the backup is unverified", "error", err)
+		return &activities.Verification{Error: failureMessage(err)}
+	}
+	return &out
+}
+
+// releaseTempSpace frees the temp space reserved for a backup. It runs on a disconnected copy
+// of the session context so the reservation is dropped on the same worker even when the
+// workflow was cancelled.
+func releaseTempSpace(ctx workflow.Context, backupId string) {
+	dCtx, cancel := workflow.NewDisconnectedContext(ctx)
+	defer cancel()
+	if err := workflow.ExecuteActivity(dCtx, internal.ActivityNameTempSpaceRelease,
+		activities.TempSpaceReleaseActivityInput{BackupId: backupId}).Get(dCtx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to release temp space", "error", err)
+	}
+}
+
+// transferActivityOptions returns the activity options for the data handling steps of a job.
+// The StartToCloseTimeout comes from the job's timeout, then the provider default.
+func transferActivityOptions(spec ProviderSpec, j *job.Job) workflow.ActivityOptions {
+	opts := defaultActivityOptions
+	if spec.StartToCloseTimeout > 0 {
+		opts.StartToCloseTimeout = spec.StartToCloseTimeout
+	}
+	if j != nil && j.Timeout > 0 {
+		opts.StartToCloseTimeout = j.Timeout
+	}
+	return opts
+}
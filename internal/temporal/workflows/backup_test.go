@@ -0,0 +1,512 @@
+package workflows
+
+import (
+	"agent/internal"
+	"agent/internal/job"
+	"agent/internal/temporal/activities"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+func newBackupTestEnv(t *testing.T, j *job.Job) *testsuite.TestWorkflowEnvironment {
+	t.Helper()
+	return newBackupTestEnvWithReserve(t, j, nil)
+}
+
+// newBackupTestEnvWithReserve returns a test environment in which TempSpaceReserveActivity fails
+// with reserveErr, or succeeds when it is nil
+func newBackupTestEnvWithReserve(t *testing.T, j *job.Job, reserveErr error) *testsuite.TestWorkflowEnvironment {
+	t.Helper()
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{EnableSessionWorker: true})
+
+	env.RegisterWorkflowWithOptions(BackupWorkflow, workflow.RegisterOptions{Name: internal.WorkflowNameBackup})
+	for _, spec := range Providers() {
+		env.RegisterWorkflowWithOptions(BackupWorkflow, workflow.RegisterOptions{Name: spec.WorkflowName})
+	}
+
+	acts := &activities.Activities{}
+	register := map[string]any{
+		internal.ActivityNameGetJob:               acts.GetJobActivity,
+		internal.ActivityNameBackupRequest:        acts.BackupRequestActivity,
+		internal.ActivityNameBackupUpload:         acts.BackupUploadActivity,
+		internal.ActivityNameBackupUploadComplete: acts.BackupUploadCompleteActivity,
+		internal.ActivityNameBackupStream:         acts.BackupStreamActivity,
+		internal.ActivityNameTempSpaceReserve:     acts.TempSpaceReserveActivity,
+		internal.ActivityNameTempSpaceRelease:     acts.TempSpaceReleaseActivity,
+		internal.ActivityNameBackupConfirm:        acts.BackupConfirmActivity,
+		internal.ActivityNameBackupStateSave:      acts.BackupStateSaveActivity,
+		internal.ActivityNameFileUploadS3:         acts.FileUploadS3Activity,
+		internal.ActivityNameFileCleanup:          acts.FileCleanupActivity,
+	}
+	for name, fn := range register {
+		env.RegisterActivityWithOptions(fn, activity.RegisterOptions{Name: name})
+	}
+	for _, spec := range Providers() {
+		env.RegisterActivityWithOptions(spec.Activity(acts), activity.RegisterOptions{Name: spec.ActivityName})
+		if spec.VerifyActivityName != "" {
+			env.RegisterActivityWithOptions(spec.VerifyActivity(acts), activity.RegisterOptions{Name: spec.VerifyActivityName})
+		}
+	}
+
+	env.OnActivity(internal.ActivityNameGetJob, mock.Anything, mock.Anything).
+		Return(&activities.GetJobActivityOutput{Job: j}, nil)
+	env.OnActivity(internal.ActivityNameBackupRequest, mock.Anything, mock.Anything).
+		Return(&activities.BackupRequestActivityOutput{ID: uuid.New()}, nil)
+	env.OnActivity(internal.ActivityNameFileCleanup, mock.Anything, mock.Anything).Return(nil)
+	if reserveErr != nil {
+		env.OnActivity(internal.ActivityNameTempSpaceReserve, mock.Anything, mock.Anything).Return(nil, reserveErr)
+	} else {
+		env.OnActivity(internal.ActivityNameTempSpaceReserve, mock.Anything, mock.Anything).
+			Return(&activities.TempSpaceReserveActivityOutput{}, nil).Maybe()
+	}
+	env.OnActivity(internal.ActivityNameTempSpaceRelease, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	return env
+}
+
+func TestBackupWorkflow_DispatchesOnProvider(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderHTTP, Config: &job.HTTPConfig{Endpoint: "https://example.com/db.sql"}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameDownload, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/db.sql", Name: "db.sql"}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.Anything).
+		Return(&activities.BackupUploadActivityOutput{UploadURL: "https://s3.example.com/upload"}, nil)
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.Anything).
+		Return(&activities.FileUploadS3ActivityOutput{Status: true}, nil)
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.MatchedBy(func(in activities.BackupConfirmActivityInput) bool {
+		return in.Status
+	})).Return(&activities.BackupConfirmActivityOutput{Status: true}, nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderHTTP)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	val, err := env.QueryWorkflow(internal.QueryNameProgress)
+	require.NoError(t, err)
+	var progress BackupProgress
+	require.NoError(t, val.Get(&progress))
+	assert.Equal(t, BackupStageCompleted, progress.Stage)
+}
+
+func TestBackupWorkflow_LegacyAlias(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderPostgreSQL, Config: &job.PostgreSQLConfig{ConnectionString: "postgres://localhost/db"}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNamePostgreSQLDump, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/job-1.sql", Name: "job-1.sql"}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.Anything).
+		Return(&activities.BackupUploadActivityOutput{UploadURL: "https://s3.example.com/upload"}, nil)
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.Anything).
+		Return(&activities.FileUploadS3ActivityOutput{Status: true}, nil)
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Return(&activities.BackupConfirmActivityOutput{Status: true}, nil)
+
+	// Started through the legacy workflow name without a provider in the input
+	env.ExecuteWorkflow(internal.WorkflowNamePostgreSQL, GeneralWorkflowInput{JobId: "job-1"})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func TestBackupWorkflow_ReportsFailure(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderHTTP, Config: &job.HTTPConfig{Endpoint: "https://example.com/db.sql"}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameDownload, mock.Anything, mock.Anything).
+		Return(nil, temporal.NewNonRetryableApplicationError("curl failed: exit status 22", "", nil))
+
+	var confirm activities.BackupConfirmActivityInput
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { confirm = args.Get(1).(activities.BackupConfirmActivityInput) }).
+		Return(&activities.BackupConfirmActivityOutput{}, nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderHTTP)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	assert.False(t, confirm.Status)
+	assert.Equal(t, BackupStageDownload, confirm.Stage)
+	assert.Equal(t, "curl failed: exit status 22", confirm.Error)
+}
+
+func TestBackupWorkflow_FailedConfirmKeepsError(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderHTTP, Config: &job.HTTPConfig{Endpoint: "https://example.com/db.sql"}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameDownload, mock.Anything, mock.Anything).
+		Return(nil, temporal.NewNonRetryableApplicationError("curl failed: exit status 22", "DownloadFailed", nil))
+	confirmed := false
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { confirmed = true }).
+		Return(nil, temporal.NewNonRetryableApplicationError("api unavailable", "ConfirmFailed", nil)).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderHTTP)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	assert.True(t, confirmed)
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(env.GetWorkflowError(), &appErr))
+	assert.Equal(t, "DownloadFailed", appErr.Type())
+	assert.Equal(t, "curl failed: exit status 22", appErr.Message())
+}
+
+func TestBackupWorkflow_UnknownProvider(t *testing.T) {
+	env := newBackupTestEnv(t, &job.Job{ID: "job-1"})
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: "imap"})
+
+	require.True(t, env.IsWorkflowCompleted())
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(env.GetWorkflowError(), &appErr))
+	assert.Equal(t, "UnsupportedProvider", appErr.Type())
+}
+
+func TestBackupWorkflow_MultipartUpload(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderHTTP, Config: &job.HTTPConfig{Endpoint: "https://example.com/db.sql"}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameDownload, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/db.sql", Name: "db.sql", Size: 1 << 30}, nil)
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.MatchedBy(func(in activities.BackupUploadActivityInput) bool {
+		return in.Multipart && in.PartSize == defaultPartSize && in.PartCount == 16
+	})).Return(&activities.BackupUploadActivityOutput{
+		UploadId: "upload-1",
+		Parts:    []activities.UploadPart{{PartNumber: 1, URL: "https://s3.example.com/1"}},
+	}, nil).Once()
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.MatchedBy(func(in activities.FileUploadS3ActivityInput) bool {
+		return in.UploadId == "upload-1" && in.PartSize == defaultPartSize && len(in.Parts) == 1
+	})).Return(&activities.FileUploadS3ActivityOutput{
+		Status: true,
+		Parts:  []activities.CompletedPart{{PartNumber: 1, ETag: "\"etag-1\""}},
+	}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupUploadComplete, mock.Anything, mock.Anything).
+		Return(&activities.BackupUploadCompleteActivityOutput{Status: true}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Return(&activities.BackupConfirmActivityOutput{Status: true}, nil)
+
+	var completed activities.BackupUploadCompleteActivityInput
+	env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		if info.ActivityType.Name == internal.ActivityNameBackupUploadComplete {
+			require.NoError(t, args.Get(&completed))
+		}
+	})
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderHTTP)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	assert.Equal(t, "upload-1", completed.UploadId)
+	assert.Equal(t, []activities.CompletedPart{{PartNumber: 1, ETag: "\"etag-1\""}}, completed.Parts)
+}
+
+func TestBackupWorkflow_RefreshesExpiredUploadURL(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderHTTP, Config: &job.HTTPConfig{Endpoint: "https://example.com/db.sql"}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameDownload, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/db.sql", Name: "db.sql", Size: 1 << 30}, nil)
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.MatchedBy(func(in activities.BackupUploadActivityInput) bool {
+		return in.UploadId == ""
+	})).Return(&activities.BackupUploadActivityOutput{
+		UploadId: "upload-1",
+		Parts:    []activities.UploadPart{{PartNumber: 1, URL: "https://s3.example.com/1"}, {PartNumber: 2, URL: "https://s3.example.com/2"}},
+	}, nil).Once()
+	// The refresh asks for new URLs of the same multipart upload
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.MatchedBy(func(in activities.BackupUploadActivityInput) bool {
+		return in.UploadId == "upload-1"
+	})).Return(&activities.BackupUploadActivityOutput{
+		UploadId: "upload-1",
+		Parts:    []activities.UploadPart{{PartNumber: 1, URL: "https://s3.example.com/1b"}, {PartNumber: 2, URL: "https://s3.example.com/2b"}},
+	}, nil).Once()
+
+	part1 := activities.CompletedPart{PartNumber: 1, ETag: "\"etag-1\""}
+	part2 := activities.CompletedPart{PartNumber: 2, ETag: "\"etag-2\""}
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.MatchedBy(func(in activities.FileUploadS3ActivityInput) bool {
+		return len(in.Completed) == 0
+	})).Return(nil, temporal.NewNonRetryableApplicationError("upload URL of part 2 was rejected", activities.ErrorTypeUploadURLExpired, nil,
+		[]activities.CompletedPart{part1})).Once()
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.MatchedBy(func(in activities.FileUploadS3ActivityInput) bool {
+		return len(in.Completed) == 1 && in.Completed[0] == part1 && in.Parts[1].URL == "https://s3.example.com/2b"
+	})).Return(&activities.FileUploadS3ActivityOutput{Status: true, Parts: []activities.CompletedPart{part1, part2}}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupUploadComplete, mock.Anything, mock.Anything).
+		Return(&activities.BackupUploadCompleteActivityOutput{Status: true}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.MatchedBy(func(in activities.BackupConfirmActivityInput) bool {
+		return in.Status
+	})).Return(&activities.BackupConfirmActivityOutput{Status: true}, nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderHTTP)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func TestBackupWorkflow_UploadURLRefreshLimit(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderHTTP, Config: &job.HTTPConfig{Endpoint: "https://example.com/db.sql"}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameDownload, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/db.sql", Name: "db.sql"}, nil)
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.Anything).
+		Return(&activities.BackupUploadActivityOutput{UploadURL: "https://s3.example.com/upload"}, nil).Times(maxUploadURLRefreshes + 1)
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.Anything).
+		Return(nil, temporal.NewNonRetryableApplicationError("upload URL has expired", activities.ErrorTypeUploadURLExpired, nil)).
+		Times(maxUploadURLRefreshes + 1)
+
+	var confirm activities.BackupConfirmActivityInput
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { confirm = args.Get(1).(activities.BackupConfirmActivityInput) }).
+		Return(&activities.BackupConfirmActivityOutput{}, nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderHTTP)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	assert.Equal(t, BackupStageUpload, confirm.Stage)
+	assert.Equal(t, "upload URL has expired", confirm.Error)
+}
+
+func TestBackupWorkflow_PipelineRunsInSession(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderHTTP, Config: &job.HTTPConfig{Endpoint: "https://example.com/db.sql"}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameDownload, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/db.sql", Name: "db.sql"}, nil)
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.Anything).
+		Return(&activities.BackupUploadActivityOutput{UploadURL: "https://s3.example.com/upload"}, nil)
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.Anything).
+		Return(&activities.FileUploadS3ActivityOutput{Status: true}, nil)
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Return(&activities.BackupConfirmActivityOutput{Status: true}, nil)
+
+	taskQueues := map[string]string{}
+	env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		taskQueues[info.ActivityType.Name] = info.TaskQueue
+	})
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderHTTP)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	// Activities touching the temp file share the session's worker specific task queue
+	sessionQueue := taskQueues[internal.ActivityNameDownload]
+	assert.NotEqual(t, taskQueues[internal.ActivityNameGetJob], sessionQueue)
+	assert.Equal(t, sessionQueue, taskQueues[internal.ActivityNameBackupRequest])
+	assert.Equal(t, sessionQueue, taskQueues[internal.ActivityNameFileUploadS3])
+	assert.Equal(t, sessionQueue, taskQueues[internal.ActivityNameFileCleanup])
+}
+
+func TestBackupWorkflow_Streaming(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderHTTP, Config: &job.HTTPConfig{Endpoint: "https://example.com/db.sql"}, Streaming: true}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameBackupStream, mock.Anything, mock.Anything).
+		Return(&activities.BackupStreamActivityOutput{
+			UploadId: "upload-1", Parts: []activities.CompletedPart{{PartNumber: 1, ETag: "\"etag-1\""}},
+			Size: 42, Checksum: "abc", Name: "db.sql.gz", MimeType: "application/gzip",
+		}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupUploadComplete, mock.Anything, mock.MatchedBy(func(in activities.BackupUploadCompleteActivityInput) bool {
+		return in.UploadId == "upload-1" && in.Size == 42 && in.Checksum == "abc" && in.Name == "db.sql.gz"
+	})).Return(&activities.BackupUploadCompleteActivityOutput{Status: true}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Return(&activities.BackupConfirmActivityOutput{Status: true}, nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderHTTP)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertActivityNumberOfCalls(t, internal.ActivityNameBackupStream, 1)
+	env.AssertActivityNumberOfCalls(t, internal.ActivityNameBackupUploadComplete, 1)
+	// Nothing was written to disk
+	env.AssertActivityNumberOfCalls(t, internal.ActivityNameDownload, 0)
+	env.AssertActivityNumberOfCalls(t, internal.ActivityNameFileCleanup, 0)
+}
+
+func TestBackupWorkflow_StreamingFallsBackToFiles(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderRedis, Config: &job.RedisConfig{ConnectionString: "redis://localhost"}, Streaming: true}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameRedisDump, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/job-1.json", Name: "job-1.json"}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.Anything).
+		Return(&activities.BackupUploadActivityOutput{UploadURL: "https://s3.example.com/upload"}, nil)
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.Anything).
+		Return(&activities.FileUploadS3ActivityOutput{Status: true}, nil)
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Return(&activities.BackupConfirmActivityOutput{Status: true}, nil)
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderRedis)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func TestBackupWorkflow_InsufficientDiskSpace(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderPostgreSQL, Config: &job.PostgreSQLConfig{ConnectionString: "postgres://localhost/db"}}
+	env := newBackupTestEnvWithReserve(t, j,
+		temporal.NewNonRetryableApplicationError("backup needs about 2000 bytes", "InsufficientDiskSpace", nil))
+
+	var confirm activities.BackupConfirmActivityInput
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { confirm = args.Get(1).(activities.BackupConfirmActivityInput) }).
+		Return(&activities.BackupConfirmActivityOutput{}, nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderPostgreSQL)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	assert.Equal(t, BackupStagePreflight, confirm.Stage)
+	assert.Equal(t, "backup needs about 2000 bytes", confirm.Error)
+	env.AssertActivityNumberOfCalls(t, internal.ActivityNamePostgreSQLDump, 0)
+}
+
+func TestBackupWorkflow_VerifiesDump(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderPostgreSQL, Config: &job.PostgreSQLConfig{
+		ConnectionString: "postgres://localhost/db",
+		Verify:           &job.VerifyConfig{Enabled: true, Database: "db_drill"},
+	}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNamePostgreSQLDump, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/job-1.sql", Name: "job-1.sql"}, nil).Once()
+	verification := &activities.Verification{Verified: true, Checks: []activities.VerifyCheckResult{{Name: "tables", Value: 12, Passed: true}}}
+	env.OnActivity(internal.ActivityNamePostgreSQLVerify, mock.Anything,
+		activities.VerifyActivityInput{Job: j, FilePath: "/tmp/agent/job-1.sql"}).Return(verification, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.Anything).
+		Return(&activities.BackupUploadActivityOutput{UploadURL: "https://s3.example.com/upload"}, nil)
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.Anything).
+		Return(&activities.FileUploadS3ActivityOutput{Status: true}, nil)
+
+	var confirm activities.BackupConfirmActivityInput
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { confirm = args.Get(1).(activities.BackupConfirmActivityInput) }).
+		Return(&activities.BackupConfirmActivityOutput{Status: true}, nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderPostgreSQL)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	assert.True(t, confirm.Status)
+	assert.Equal(t, verification, confirm.Verification)
+}
+
+func TestBackupWorkflow_FailedDrillMarksBackupUnverified(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderRedis, Config: &job.RedisConfig{
+		ConnectionString: "redis://localhost:6379/0",
+		Verify:           &job.VerifyConfig{Enabled: true, Database: "15"},
+	}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameRedisDump, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/job-1.json", Name: "job-1.json"}, nil).Once()
+	env.OnActivity(internal.ActivityNameRedisVerify, mock.Anything, mock.Anything).
+		Return(nil, errors.New("failed to flush scratch DB: connection refused")).Once()
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.Anything).
+		Return(&activities.BackupUploadActivityOutput{UploadURL: "https://s3.example.com/upload"}, nil)
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.Anything).
+		Return(&activities.FileUploadS3ActivityOutput{Status: true}, nil).Once()
+
+	var confirm activities.BackupConfirmActivityInput
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { confirm = args.Get(1).(activities.BackupConfirmActivityInput) }).
+		Return(&activities.BackupConfirmActivityOutput{Status: true}, nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderRedis)})
+
+	// The backup is still uploaded and completed, only without verification
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	assert.True(t, confirm.Status)
+	require.NotNil(t, confirm.Verification)
+	assert.False(t, confirm.Verification.Verified)
+	assert.Contains(t, confirm.Verification.Error, "connection refused")
+}
+
+func TestBackupWorkflow_SavesStateAfterConfirm(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderMySQL, Config: &job.MySQLConfig{
+		ConnectionString: "app@tcp(db)/shop",
+		Method:           job.MySQLMethodBinlog,
+	}}
+	env := newBackupTestEnv(t, j)
+
+	state := json.RawMessage(`{"file":"binlog.000007","position":4}`)
+	env.OnActivity(internal.ActivityNameMySQLDump, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/job-1.binlog.tar", Name: "job-1.binlog.tar", State: state}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.Anything).
+		Return(&activities.BackupUploadActivityOutput{UploadURL: "https://s3.example.com/upload"}, nil)
+	env.OnActivity(internal.ActivityNameFileUploadS3, mock.Anything, mock.Anything).
+		Return(&activities.FileUploadS3ActivityOutput{Status: true}, nil)
+
+	var order []string
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { order = append(order, internal.ActivityNameBackupConfirm) }).
+		Return(&activities.BackupConfirmActivityOutput{Status: true}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupStateSave, mock.Anything,
+		activities.BackupStateSaveActivityInput{JobId: "job-1", State: state}).
+		Run(func(mock.Arguments) { order = append(order, internal.ActivityNameBackupStateSave) }).
+		Return(nil).Once()
+
+	taskQueues := map[string]string{}
+	env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		taskQueues[info.ActivityType.Name] = info.TaskQueue
+	})
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderMySQL)})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	assert.Equal(t, []string{internal.ActivityNameBackupConfirm, internal.ActivityNameBackupStateSave}, order)
+
+	// The state is read and written in the StateDir of the worker that took the dump
+	dumpQueue := taskQueues[internal.ActivityNameMySQLDump]
+	assert.Equal(t, dumpQueue, taskQueues[internal.ActivityNameBackupRequest])
+	assert.Equal(t, dumpQueue, taskQueues[internal.ActivityNameBackupStateSave])
+}
+
+func TestBackupWorkflow_FailedUploadKeepsState(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderMySQL, Config: &job.MySQLConfig{
+		ConnectionString: "app@tcp(db)/shop",
+		Method:           job.MySQLMethodBinlog,
+	}}
+	env := newBackupTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameMySQLDump, mock.Anything, mock.Anything).
+		Return(&activities.DownloadActivityOutput{FilePath: "/tmp/agent/job-1.binlog.tar", Name: "job-1.binlog.tar",
+			State: json.RawMessage(`{"file":"binlog.000007","position":4}`)}, nil).Once()
+	env.OnActivity(internal.ActivityNameBackupUpload, mock.Anything, mock.Anything).
+		Return(nil, temporal.NewNonRetryableApplicationError("API returned status 500", "", nil))
+	env.OnActivity(internal.ActivityNameBackupConfirm, mock.Anything, mock.Anything).
+		Return(&activities.BackupConfirmActivityOutput{}, nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameBackup, GeneralWorkflowInput{JobId: "job-1", Provider: string(job.JobProviderMySQL)})
+
+	// The binary logs of this run are archived again by the next one
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	env.AssertNotCalled(t, internal.ActivityNameBackupStateSave, mock.Anything, mock.Anything)
+}
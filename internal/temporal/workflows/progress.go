@@ -0,0 +1,49 @@
+package workflows
+
+import (
+	"agent/internal"
+	"agent/internal/temporal/activities"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// BackupProgress is returned by the progress query of BackupWorkflow
+type BackupProgress struct {
+	Stage    string               `json:"stage"`
+	Activity *activities.Progress `json:"activity,omitempty"`
+}
+
+type progressKey struct{}
+
+// trackProgress registers the progress query and starts receiving progress signals sent by
+// heartbeating activities. The returned context carries the tracker used by setStage.
+func trackProgress(ctx workflow.Context) (workflow.Context, error) {
+	progress := &BackupProgress{Stage: BackupStageRequest}
+
+	if err := workflow.SetQueryHandler(ctx, internal.QueryNameProgress, func() (BackupProgress, error) {
+		return *progress, nil
+	}); err != nil {
+		return ctx, err
+	}
+
+	ch := workflow.GetSignalChannel(ctx, internal.SignalNameProgress)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for {
+			var p activities.Progress
+			if !ch.Receive(ctx, &p) {
+				return
+			}
+			progress.Activity = &p
+		}
+	})
+
+	return workflow.WithValue(ctx, progressKey{}, progress), nil
+}
+
+// setStage records the pipeline stage reported by the progress query
+func setStage(ctx workflow.Context, stage string) {
+	if progress, ok := ctx.Value(progressKey{}).(*BackupProgress); ok {
+		progress.Stage = stage
+		progress.Activity = nil
+	}
+}
@@ -0,0 +1,162 @@
+package workflows
+
+import (
+	"agent/internal"
+	"agent/internal/job"
+	"agent/internal/temporal/activities"
+	"sort"
+	"time"
+)
+
+// ProviderSpec describes how BackupWorkflow acquires the data for one provider.
+// Every activity named here must return a DownloadActivityOutput.
+type ProviderSpec struct {
+	// WorkflowName is the legacy per-provider workflow type, registered as an alias of BackupWorkflow
+	WorkflowName string
+	// ActivityName is the provider's download/dump activity
+	ActivityName string
+	// Activity returns the implementation registered under ActivityName
+	Activity func(a *activities.Activities) any
+	// NewInput builds the download activity input for a job
+	NewInput func(j *job.Job) any
+	// StartToCloseTimeout overrides the default timeout of the download activity when set
+	StartToCloseTimeout time.Duration
+	// RestoreActivityName is the provider's restore activity, taking a RestoreActivityInput.
+	// It is empty for providers whose backups cannot be restored by the agent.
+	RestoreActivityName string
+	// RestoreActivity returns the implementation registered under RestoreActivityName
+	RestoreActivity func(a *activities.Activities) any
+	// VerifyActivityName is the provider's restore drill activity, taking a VerifyActivityInput
+	// and returning a Verification. It runs after the dump when the job config enables it.
+	VerifyActivityName string
+	// VerifyActivity returns the implementation registered under VerifyActivityName
+	VerifyActivity func(a *activities.Activities) any
+}
+
+// providerSpecs is the provider registry. Adding a provider only requires an entry here;
+// the worker registers the activity and the workflow alias from it.
+var providerSpecs = map[job.Provider]ProviderSpec{
+	job.JobProviderHTTP: {
+		WorkflowName: internal.WorkflowNameHTTP,
+		ActivityName: internal.ActivityNameDownload,
+		Activity:     func(a *activities.Activities) any { return a.DownloadActivity },
+		NewInput:     func(j *job.Job) any { return activities.DownloadActivityInput{Job: j} },
+	},
+	job.JobProviderFTP: {
+		WorkflowName: internal.WorkflowNameFTP,
+		ActivityName: internal.ActivityNameFileTransferDownload,
+		Activity:     func(a *activities.Activities) any { return a.FTPDownloadActivity },
+		NewInput:     func(j *job.Job) any { return activities.FTPDownloadActivityInput{Job: j} },
+	},
+	job.JobProviderSFTP: {
+		WorkflowName: internal.WorkflowNameSFTP,
+		ActivityName: internal.ActivityNameSFTPDownload,
+		Activity:     func(a *activities.Activities) any { return a.SFTPDownloadActivity },
+		NewInput:     func(j *job.Job) any { return activities.SFTPDownloadActivityInput{Job: j} },
+	},
+	job.JobProviderWebDAV: {
+		WorkflowName: internal.WorkflowNameWebDAV,
+		ActivityName: internal.ActivityNameWebDAVDownload,
+		Activity:     func(a *activities.Activities) any { return a.WebDAVDownloadActivity },
+		NewInput:     func(j *job.Job) any { return activities.WebDAVDownloadActivityInput{Job: j} },
+	},
+	job.JobProviderGit: {
+		WorkflowName:        internal.WorkflowNameGit,
+		ActivityName:        internal.ActivityNameGitDownload,
+		Activity:            func(a *activities.Activities) any { return a.GitDownloadActivity },
+		NewInput:            func(j *job.Job) any { return activities.GitDownloadActivityInput{Job: j} },
+		StartToCloseTimeout: time.Hour,
+	},
+	job.JobProviderMySQL: {
+		WorkflowName:        internal.WorkflowNameMySQL,
+		ActivityName:        internal.ActivityNameMySQLDump,
+		Activity:            func(a *activities.Activities) any { return a.MySQLDumpActivity },
+		NewInput:            func(j *job.Job) any { return activities.MySQLDumpActivityInput{Job: j} },
+		StartToCloseTimeout: 4 * time.Hour,
+		RestoreActivityName: internal.ActivityNameMySQLRestore,
+		RestoreActivity:     func(a *activities.Activities) any { return a.MySQLRestoreActivity },
+		VerifyActivityName:  internal.ActivityNameMySQLVerify,
+		VerifyActivity:      func(a *activities.Activities) any { return a.MySQLVerifyActivity },
+	},
+	job.JobProviderPostgreSQL: {
+		WorkflowName:        internal.WorkflowNamePostgreSQL,
+		ActivityName:        internal.ActivityNamePostgreSQLDump,
+		Activity:            func(a *activities.Activities) any { return a.PostgreSQLDumpActivity },
+		NewInput:            func(j *job.Job) any { return activities.PostgreSQLDumpActivityInput{Job: j} },
+		StartToCloseTimeout: 4 * time.Hour,
+		RestoreActivityName: internal.ActivityNamePostgreSQLRestore,
+		RestoreActivity:     func(a *activities.Activities) any { return a.PostgreSQLRestoreActivity },
+		VerifyActivityName:  internal.ActivityNamePostgreSQLVerify,
+		VerifyActivity:      func(a *activities.Activities) any { return a.PostgreSQLVerifyActivity },
+	},
+	job.JobProviderMSSQL: {
+		WorkflowName:        internal.WorkflowNameMSSQL,
+		ActivityName:        internal.ActivityNameMSSQLDump,
+		Activity:            func(a *activities.Activities) any { return a.MSSQLDumpActivity },
+		NewInput:            func(j *job.Job) any { return activities.MSSQLDumpActivityInput{Job: j} },
+		StartToCloseTimeout: 4 * time.Hour,
+		RestoreActivityName: internal.ActivityNameMSSQLRestore,
+		RestoreActivity:     func(a *activities.Activities) any { return a.MSSQLRestoreActivity },
+	},
+	job.JobProviderRedis: {
+		WorkflowName:        internal.WorkflowNameRedis,
+		ActivityName:        internal.ActivityNameRedisDump,
+		Activity:            func(a *activities.Activities) any { return a.RedisDumpActivity },
+		NewInput:            func(j *job.Job) any { return activities.RedisDumpActivityInput{Job: j} },
+		StartToCloseTimeout: 2 * time.Hour,
+		RestoreActivityName: internal.ActivityNameRedisRestore,
+		RestoreActivity:     func(a *activities.Activities) any { return a.RedisRestoreActivity },
+		VerifyActivityName:  internal.ActivityNameRedisVerify,
+		VerifyActivity:      func(a *activities.Activities) any { return a.RedisVerifyActivity },
+	},
+	job.JobProviderAWSS3: {
+		WorkflowName:        internal.WorkflowNameAWSS3,
+		ActivityName:        internal.ActivityNameAWSS3Download,
+		Activity:            func(a *activities.Activities) any { return a.AWSS3DownloadActivity },
+		NewInput:            func(j *job.Job) any { return activities.AWSS3DownloadActivityInput{Job: j} },
+		StartToCloseTimeout: 4 * time.Hour,
+		RestoreActivityName: internal.ActivityNameAWSS3Restore,
+		RestoreActivity:     func(a *activities.Activities) any { return a.AWSS3RestoreActivity },
+	},
+	job.JobProviderAWSDynamoDB: {
+		WorkflowName:        internal.WorkflowNameAWSDynamoDB,
+		ActivityName:        internal.ActivityNameAWSDynamoDBDump,
+		Activity:            func(a *activities.Activities) any { return a.AWSDynamoDBDumpActivity },
+		NewInput:            func(j *job.Job) any { return activities.AWSDynamoDBDumpActivityInput{Job: j} },
+		StartToCloseTimeout: 4 * time.Hour,
+		RestoreActivityName: internal.ActivityNameAWSDynamoDBRestore,
+		RestoreActivity:     func(a *activities.Activities) any { return a.AWSDynamoDBRestoreActivity },
+	},
+	job.JobProviderScript: {
+		WorkflowName: internal.WorkflowNameScript,
+		ActivityName: internal.ActivityNameScriptRun,
+		Activity:     func(a *activities.Activities) any { return a.ScriptRunActivity },
+		NewInput:     func(j *job.Job) any { return activities.ScriptRunActivityInput{Job: j} },
+	},
+}
+
+// LookupProvider returns the registered spec for a provider
+func LookupProvider(provider job.Provider) (ProviderSpec, bool) {
+	spec, ok := providerSpecs[provider]
+	return spec, ok
+}
+
+// Providers returns all registered provider specs in a stable order
+func Providers() []ProviderSpec {
+	specs := make([]ProviderSpec, 0, len(providerSpecs))
+	for _, spec := range providerSpecs {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].WorkflowName < specs[j].WorkflowName })
+	return specs
+}
+
+// providerForWorkflow resolves a provider from a legacy workflow alias
+func providerForWorkflow(name string) (job.Provider, bool) {
+	for provider, spec := range providerSpecs {
+		if spec.WorkflowName == name {
+			return provider, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,96 @@
+package workflows
+
+import (
+	"agent/internal"
+	"agent/internal/temporal/activities"
+	"fmt"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// RestoreStageRestore is the stage in which the downloaded backup is written to the target
+const RestoreStageRestore = "restore"
+
+// RestoreWorkflowInput selects the backup to restore and where to restore it
+type RestoreWorkflowInput struct {
+	JobId    string                   `json:"job_id"`
+	BackupId string                   `json:"backup_id"`
+	Target   activities.RestoreTarget `json:"target"`
+}
+
+// RestoreWorkflow runs GetJob → RestoreDownload → provider restore → cleanup. The backup is
+// downloaded, decrypted and decompressed into TempDir and restored from there, so both steps run
+// on one worker through a session. The target defaults to the data described by the job config.
+func RestoreWorkflow(ctx workflow.Context, input RestoreWorkflowInput) (*activities.RestoreActivityOutput, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("RestoreWorkflow started", "jobId", input.JobId, "backupId", input.BackupId)
+
+	ctx, err := trackProgress(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = workflow.WithActivityOptions(ctx, defaultActivityOptions)
+
+	var getJobOut activities.GetJobActivityOutput
+	if err := workflow.ExecuteActivity(ctx, internal.ActivityNameGetJob,
+		activities.GetJobActivityInput{JobId: input.JobId}).Get(ctx, &getJobOut); err != nil {
+		return nil, err
+	}
+
+	spec, ok := LookupProvider(getJobOut.Job.Provider)
+	if !ok || spec.RestoreActivityName == "" {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("provider %q does not support restores", getJobOut.Job.Provider), "RestoreUnsupported", nil)
+	}
+
+	transferOptions := transferActivityOptions(spec, getJobOut.Job)
+	ctx = workflow.WithActivityOptions(ctx, transferOptions)
+
+	// Reservations are keyed by backup; a restore of a backup must not release the reservation
+	// of a backup that is still being taken
+	reservationId := "restore-" + input.BackupId
+
+	var out activities.RestoreActivityOutput
+	stage, err := runInSession(ctx, transferOptions, func(ctx workflow.Context) (string, error) {
+		setStage(ctx, BackupStagePreflight)
+		if err := workflow.ExecuteActivity(ctx, internal.ActivityNameTempSpaceReserve,
+			activities.TempSpaceReserveActivityInput{Job: getJobOut.Job, BackupId: reservationId},
+		).Get(ctx, nil); err != nil {
+			return BackupStagePreflight, err
+		}
+		defer releaseTempSpace(ctx, reservationId)
+
+		setStage(ctx, BackupStageDownload)
+		var dlOut activities.RestoreDownloadActivityOutput
+		if err := workflow.ExecuteActivity(ctx, internal.ActivityNameRestoreDownload,
+			activities.RestoreDownloadActivityInput{JobId: input.JobId, BackupId: input.BackupId},
+		).Get(ctx, &dlOut); err != nil {
+			return BackupStageDownload, err
+		}
+
+		// Restores are not idempotent: a retry replays a plain dump on top of a half applied one or
+		// fails on the keys and items the first attempt wrote. The restore runs once and retrying
+		// is left to the operator.
+		setStage(ctx, RestoreStageRestore)
+		restoreOptions := workflow.GetActivityOptions(ctx)
+		restoreOptions.RetryPolicy = &temporal.RetryPolicy{MaximumAttempts: 1}
+		err := workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, restoreOptions), spec.RestoreActivityName,
+			activities.RestoreActivityInput{Job: getJobOut.Job, Target: input.Target, FilePath: dlOut.FilePath, Name: dlOut.Name},
+		).Get(ctx, &out)
+		workflow.ExecuteActivity(ctx, internal.ActivityNameFileCleanup,
+			activities.FileCleanupActivityInput{FilePath: dlOut.FilePath}).Get(ctx, nil)
+		if err != nil {
+			return RestoreStageRestore, err
+		}
+		return "", nil
+	})
+	if err != nil {
+		logger.Error("RestoreWorkflow failed", "stage", stage, "error", err)
+		return nil, err
+	}
+
+	setStage(ctx, BackupStageCompleted)
+	logger.Info("RestoreWorkflow completed", "items", out.Items)
+	return &out, nil
+}
@@ -0,0 +1,137 @@
+package workflows
+
+import (
+	"agent/internal"
+	"agent/internal/job"
+	"agent/internal/temporal/activities"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+func newRestoreTestEnv(t *testing.T, j *job.Job) *testsuite.TestWorkflowEnvironment {
+	t.Helper()
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{EnableSessionWorker: true})
+	env.RegisterWorkflowWithOptions(RestoreWorkflow, workflow.RegisterOptions{Name: internal.WorkflowNameRestore})
+
+	acts := &activities.Activities{}
+	register := map[string]any{
+		internal.ActivityNameGetJob:           acts.GetJobActivity,
+		internal.ActivityNameTempSpaceReserve: acts.TempSpaceReserveActivity,
+		internal.ActivityNameTempSpaceRelease: acts.TempSpaceReleaseActivity,
+		internal.ActivityNameRestoreDownload:  acts.RestoreDownloadActivity,
+		internal.ActivityNameFileCleanup:      acts.FileCleanupActivity,
+	}
+	for name, fn := range register {
+		env.RegisterActivityWithOptions(fn, activity.RegisterOptions{Name: name})
+	}
+	for _, spec := range Providers() {
+		if spec.RestoreActivityName != "" {
+			env.RegisterActivityWithOptions(spec.RestoreActivity(acts), activity.RegisterOptions{Name: spec.RestoreActivityName})
+		}
+	}
+
+	env.OnActivity(internal.ActivityNameGetJob, mock.Anything, mock.Anything).
+		Return(&activities.GetJobActivityOutput{Job: j}, nil)
+	env.OnActivity(internal.ActivityNameTempSpaceReserve, mock.Anything, mock.Anything).
+		Return(&activities.TempSpaceReserveActivityOutput{}, nil).Maybe()
+	env.OnActivity(internal.ActivityNameTempSpaceRelease, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	return env
+}
+
+func TestRestoreWorkflow_RestoresIntoTarget(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderPostgreSQL, Config: &job.PostgreSQLConfig{ConnectionString: "postgres://localhost/app"}}
+	env := newRestoreTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameRestoreDownload, mock.Anything, activities.RestoreDownloadActivityInput{JobId: "job-1", BackupId: "backup-1"}).
+		Return(&activities.RestoreDownloadActivityOutput{FilePath: "/tmp/agent/job-1-restore-job-1.sql", Name: "job-1.sql"}, nil).Once()
+
+	var restore activities.RestoreActivityInput
+	env.OnActivity(internal.ActivityNamePostgreSQLRestore, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { restore = args.Get(1).(activities.RestoreActivityInput) }).
+		Return(&activities.RestoreActivityOutput{}, nil).Once()
+	env.OnActivity(internal.ActivityNameFileCleanup, mock.Anything,
+		activities.FileCleanupActivityInput{FilePath: "/tmp/agent/job-1-restore-job-1.sql"}).Return(nil).Once()
+
+	target := activities.RestoreTarget{Database: "app_restored"}
+	env.ExecuteWorkflow(internal.WorkflowNameRestore, RestoreWorkflowInput{JobId: "job-1", BackupId: "backup-1", Target: target})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	assert.Equal(t, target, restore.Target)
+	assert.Equal(t, "/tmp/agent/job-1-restore-job-1.sql", restore.FilePath)
+	assert.Equal(t, "job-1.sql", restore.Name)
+
+	val, err := env.QueryWorkflow(internal.QueryNameProgress)
+	require.NoError(t, err)
+	var progress BackupProgress
+	require.NoError(t, val.Get(&progress))
+	assert.Equal(t, BackupStageCompleted, progress.Stage)
+}
+
+func TestRestoreWorkflow_CleansUpAfterFailedRestore(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderRedis, Config: &job.RedisConfig{ConnectionString: "redis://localhost:6379/0"}}
+	env := newRestoreTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameRestoreDownload, mock.Anything, mock.Anything).
+		Return(&activities.RestoreDownloadActivityOutput{FilePath: "/tmp/agent/job-1-restore-job-1.json", Name: "job-1.json"}, nil)
+	env.OnActivity(internal.ActivityNameRedisRestore, mock.Anything, mock.Anything).
+		Return(nil, temporal.NewNonRetryableApplicationError("RESTORE failed: BUSYKEY", "", nil))
+	env.OnActivity(internal.ActivityNameFileCleanup, mock.Anything, mock.Anything).Return(nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameRestore, RestoreWorkflowInput{JobId: "job-1", BackupId: "backup-1"})
+
+	require.True(t, env.IsWorkflowCompleted())
+	err := env.GetWorkflowError()
+	require.Error(t, err)
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr))
+	assert.Contains(t, appErr.Message(), "BUSYKEY")
+	env.AssertExpectations(t)
+}
+
+func TestRestoreWorkflow_DoesNotRetryRestore(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderRedis, Config: &job.RedisConfig{ConnectionString: "redis://localhost:6379/0"}}
+	env := newRestoreTestEnv(t, j)
+
+	env.OnActivity(internal.ActivityNameRestoreDownload, mock.Anything, mock.Anything).
+		Return(&activities.RestoreDownloadActivityOutput{FilePath: "/tmp/agent/job-1-restore-job-1.ndjson", Name: "job-1.ndjson"}, nil).Once()
+	// A retryable failure must not run the restore a second time
+	attempts := 0
+	env.OnActivity(internal.ActivityNameRedisRestore, mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { attempts++ }).
+		Return(nil, errors.New("connection reset by peer"))
+	env.OnActivity(internal.ActivityNameFileCleanup, mock.Anything, mock.Anything).Return(nil).Once()
+
+	env.ExecuteWorkflow(internal.WorkflowNameRestore, RestoreWorkflowInput{JobId: "job-1", BackupId: "backup-1"})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+	assert.Equal(t, 1, attempts)
+	env.AssertExpectations(t)
+}
+
+func TestRestoreWorkflow_UnsupportedProvider(t *testing.T) {
+	j := &job.Job{ID: "job-1", Provider: job.JobProviderGit, Config: &job.GitConfig{}}
+	env := newRestoreTestEnv(t, j)
+
+	env.ExecuteWorkflow(internal.WorkflowNameRestore, RestoreWorkflowInput{JobId: "job-1", BackupId: "backup-1"})
+
+	require.True(t, env.IsWorkflowCompleted())
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(env.GetWorkflowError(), &appErr))
+	assert.Equal(t, "RestoreUnsupported", appErr.Type())
+}
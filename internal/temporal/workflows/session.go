@@ -0,0 +1,50 @@
+package workflows
+
+import (
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// maxSessionAttempts is how often the pipeline is started on a new session after the
+	// worker holding the previous one was lost
+	maxSessionAttempts = 3
+	// sessionCreationTimeout is how long to wait for a worker to accept a new session
+	sessionCreationTimeout = 10 * time.Minute
+	// pipelineSteps is the number of data handling activities in a session, each of which
+	// may take up to the transfer StartToCloseTimeout: download, verify, compress, encrypt
+	// and upload
+	pipelineSteps = 5
+)
+
+// runInSession runs fn on a session so that all of its activities execute on the worker that
+// holds the temp files they pass to each other. When the session fails because that worker
+// went away, the files are gone with it, so fn is started again from the beginning on a new
+// session. fn returns the stage it failed in, which is passed through with its error.
+func runInSession(ctx workflow.Context, opts workflow.ActivityOptions, fn func(ctx workflow.Context) (string, error)) (string, error) {
+	logger := workflow.GetLogger(ctx)
+
+	sessionOptions := &workflow.SessionOptions{
+		CreationTimeout:  sessionCreationTimeout,
+		ExecutionTimeout: pipelineSteps * opts.StartToCloseTimeout,
+		HeartbeatTimeout: opts.HeartbeatTimeout,
+	}
+
+	for attempt := 1; ; attempt++ {
+		sessionCtx, err := workflow.CreateSession(ctx, sessionOptions)
+		if err != nil {
+			return BackupStageDownload, err
+		}
+
+		stage, err := fn(sessionCtx)
+		failed := workflow.GetSessionInfo(sessionCtx).SessionState == workflow.SessionStateFailed
+		workflow.CompleteSession(sessionCtx)
+
+		if err == nil || !failed || attempt >= maxSessionAttempts {
+			return stage, err
+		}
+		logger.Warn("Session failed, restarting pipeline on a new session",
+			"attempt", attempt, "stage", stage, "error", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"agent/internal"
 	"agent/internal/job"
 	"agent/internal/temporal/activities"
+	"errors"
+	"fmt"
 	"time"
 
 	"go.temporal.io/sdk/temporal"
@@ -12,6 +14,9 @@ import (
 
 var defaultActivityOptions = workflow.ActivityOptions{
 	StartToCloseTimeout: 30 * time.Minute,
+	// Long running activities heartbeat their progress; a hung transfer or dump
+	// is detected after this long instead of at the end of StartToCloseTimeout
+	HeartbeatTimeout: 2 * time.Minute,
 	RetryPolicy: &temporal.RetryPolicy{
 		InitialInterval:    time.Second,
 		BackoffCoefficient: 2.0,
@@ -20,10 +25,75 @@ var defaultActivityOptions = workflow.ActivityOptions{
 	},
 }
 
-// ProcessAndUpload handles compress → encrypt → upload → confirm steps shared by all providers.
-func ProcessAndUpload(ctx workflow.Context, j *job.Job, jobId, backupId, filePath string, size int64, checksum, name, mimeType string) error {
+const (
+	// multipartThreshold is the file size above which backups are uploaded in parts
+	multipartThreshold = 512 << 20
+	// defaultPartSize is the size of each part of a multipart upload
+	defaultPartSize = 64 << 20
+	// maxUploadParts is the S3 limit on the number of parts of a multipart upload
+	maxUploadParts = 10000
+	// maxUploadURLRefreshes is how often expired upload URLs are replaced before the upload fails
+	maxUploadURLRefreshes = 3
+)
+
+// Backup pipeline stages, reported by the progress query and to the API when a backup fails
+const (
+	BackupStageRequest   = "request"
+	BackupStagePreflight = "preflight"
+	BackupStageDownload  = "download"
+	BackupStageVerify    = "verify"
+	BackupStageCompress  = "compress"
+	BackupStageEncrypt   = "encrypt"
+	BackupStageStream    = "stream"
+	BackupStageUpload    = "upload"
+	BackupStageConfirm   = "confirm"
+	BackupStageCompleted = "completed"
+)
+
+// FailBackup confirms the backup with a failed status and the failure reason, then returns
+// the original error so the workflow still fails. It runs on a disconnected context so the
+// backend record is closed even when the workflow was cancelled.
+func FailBackup(ctx workflow.Context, jobId, backupId, stage string, err error) error {
 	logger := workflow.GetLogger(ctx)
 
+	dCtx, cancel := workflow.NewDisconnectedContext(ctx)
+	defer cancel()
+
+	confirmErr := workflow.ExecuteActivity(dCtx, internal.ActivityNameBackupConfirm,
+		activities.BackupConfirmActivityInput{
+			JobId: jobId, BackupId: backupId, Status: false,
+			Stage: stage, Error: failureMessage(err),
+		},
+	).Get(dCtx, nil)
+	if confirmErr != nil {
+		logger.Error("Failed to report backup failure", "stage", stage, "error", confirmErr)
+	}
+
+	return err
+}
+
+// failureMessage extracts the most specific message from an activity error chain,
+// skipping the Temporal wrapper text that is of no use on the dashboard.
+func failureMessage(err error) string {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		return appErr.Message()
+	}
+	var timeoutErr *temporal.TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return fmt.Sprintf("activity timed out (%s)", timeoutErr.TimeoutType())
+	}
+	var canceledErr *temporal.CanceledError
+	if errors.As(err, &canceledErr) {
+		return "backup was cancelled"
+	}
+	return err.Error()
+}
+
+// processAndUpload runs the compress → encrypt → upload → cleanup steps and returns the stage
+// that failed, leaving the backup record to the caller. All activities run on ctx, so they stay
+// on the worker holding the file when ctx is a session context.
+func processAndUpload(ctx workflow.Context, j *job.Job, jobId, backupId, filePath string, size int64, checksum, name, mimeType string) (string, error) {
 	// Compress
 	currentFile := filePath
 	currentSize := size
@@ -31,12 +101,16 @@ func ProcessAndUpload(ctx workflow.Context, j *job.Job, jobId, backupId, filePat
 	currentName := name
 	currentMimeType := mimeType
 	if j.Compression.Enabled {
+		setStage(ctx, BackupStageCompress)
 		var out activities.FileCompressionActivityOutput
 		err := workflow.ExecuteActivity(ctx, internal.ActivityNameCompressFile,
-			activities.FileCompressionActivityInput{FilePath: currentFile, Provider: j.Compression.Algorithm},
+			activities.FileCompressionActivityInput{
+				FilePath: currentFile, Name: currentName,
+				Provider: j.Compression.Algorithm, Level: j.Compression.Level,
+			},
 		).Get(ctx, &out)
 		if err != nil {
-			return err
+			return BackupStageCompress, err
 		}
 		workflow.ExecuteActivity(ctx, internal.ActivityNameFileCleanup,
 			activities.FileCleanupActivityInput{FilePath: currentFile}).Get(ctx, nil)
@@ -49,12 +123,16 @@ func ProcessAndUpload(ctx workflow.Context, j *job.Job, jobId, backupId, filePat
 
 	// Encrypt
 	if j.Encryption.Enabled {
+		setStage(ctx, BackupStageEncrypt)
 		var out activities.FileEncryptionActivityOutput
 		err := workflow.ExecuteActivity(ctx, internal.ActivityNameEncryptFile,
-			activities.FileEncryptionActivityInput{FilePath: currentFile, Provider: j.Encryption.Algorithm, Key: j.Encryption.PublicKey},
+			activities.FileEncryptionActivityInput{
+				FilePath: currentFile, Name: currentName, Provider: j.Encryption.Algorithm,
+				Key: j.Encryption.PublicKey, Keys: j.Encryption.Recipients,
+			},
 		).Get(ctx, &out)
 		if err != nil {
-			return err
+			return BackupStageEncrypt, err
 		}
 		workflow.ExecuteActivity(ctx, internal.ActivityNameFileCleanup,
 			activities.FileCleanupActivityInput{FilePath: currentFile}).Get(ctx, nil)
@@ -65,38 +143,132 @@ func ProcessAndUpload(ctx workflow.Context, j *job.Job, jobId, backupId, filePat
 		currentMimeType = out.MimeType
 	}
 
-	// Request upload URL
-	var uploadOut activities.BackupUploadActivityOutput
-	err := workflow.ExecuteActivity(ctx, internal.ActivityNameBackupUpload,
-		activities.BackupUploadActivityInput{
-			JobId: jobId, BackupId: backupId,
-			Size: currentSize, Checksum: currentChecksum, Name: currentName, MimeType: currentMimeType,
-		},
-	).Get(ctx, &uploadOut)
+	// Upload
+	setStage(ctx, BackupStageUpload)
+	err := uploadBackupFile(ctx, activities.BackupUploadActivityInput{
+		JobId: jobId, BackupId: backupId,
+		Size: currentSize, Checksum: currentChecksum, Name: currentName, MimeType: currentMimeType,
+	}, currentFile)
+	workflow.ExecuteActivity(ctx, internal.ActivityNameFileCleanup,
+		activities.FileCleanupActivityInput{FilePath: currentFile}).Get(ctx, nil)
 	if err != nil {
-		return err
+		return BackupStageUpload, err
 	}
 
-	// Upload to S3
-	err = workflow.ExecuteActivity(ctx, internal.ActivityNameFileUploadS3,
-		activities.FileUploadS3ActivityInput{
-			FilePath: currentFile, UploadURL: uploadOut.UploadURL, ExpiresAt: uploadOut.ExpiresAt,
+	return "", nil
+}
+
+// streamBackup runs the provider, compression, encryption and upload in a single activity that
+// never writes the backup to disk, then completes the multipart upload it created
+func streamBackup(ctx workflow.Context, j *job.Job, jobId, backupId string) (string, error) {
+	setStage(ctx, BackupStageStream)
+	var out activities.BackupStreamActivityOutput
+	if err := workflow.ExecuteActivity(ctx, internal.ActivityNameBackupStream,
+		activities.BackupStreamActivityInput{Job: j, JobId: jobId, BackupId: backupId},
+	).Get(ctx, &out); err != nil {
+		return BackupStageStream, err
+	}
+
+	setStage(ctx, BackupStageUpload)
+	if err := workflow.ExecuteActivity(ctx, internal.ActivityNameBackupUploadComplete,
+		activities.BackupUploadCompleteActivityInput{
+			JobId: jobId, BackupId: backupId, UploadId: out.UploadId, Parts: out.Parts,
+			Size: out.Size, Checksum: out.Checksum, Name: out.Name, MimeType: out.MimeType,
 		},
-	).Get(ctx, nil)
-	workflow.ExecuteActivity(ctx, internal.ActivityNameFileCleanup,
-		activities.FileCleanupActivityInput{FilePath: currentFile}).Get(ctx, nil)
-	if err != nil {
-		return err
+	).Get(ctx, nil); err != nil {
+		return BackupStageUpload, err
 	}
+	return "", nil
+}
 
-	// Confirm backup
-	err = workflow.ExecuteActivity(ctx, internal.ActivityNameBackupConfirm,
-		activities.BackupConfirmActivityInput{JobId: jobId, BackupId: backupId, Status: true},
+// confirmBackup marks the backup as completed on the API, with the result of its restore drill
+// when one ran
+func confirmBackup(ctx workflow.Context, jobId, backupId string, verification *activities.Verification) error {
+	logger := workflow.GetLogger(ctx)
+
+	setStage(ctx, BackupStageConfirm)
+	err := workflow.ExecuteActivity(ctx, internal.ActivityNameBackupConfirm,
+		activities.BackupConfirmActivityInput{JobId: jobId, BackupId: backupId, Status: true, Verification: verification},
 	).Get(ctx, nil)
 	if err != nil {
 		logger.Error("Failed to confirm backup", "error", err)
 		return err
 	}
+	setStage(ctx, BackupStageCompleted)
 
 	return nil
 }
+
+// uploadBackupFile requests upload URLs from the API and uploads the file to them. Files above
+// multipartThreshold are uploaded in parts when the API supports it, and the multipart upload is
+// completed with the ETags of the uploaded parts. When the URLs expire or are rejected before the
+// upload finishes, new URLs are requested up to maxUploadURLRefreshes times; parts uploaded with
+// the earlier URLs are kept.
+func uploadBackupFile(ctx workflow.Context, meta activities.BackupUploadActivityInput, filePath string) error {
+	logger := workflow.GetLogger(ctx)
+
+	if meta.Size > multipartThreshold {
+		meta.Multipart = true
+		meta.PartSize = multipartPartSize(meta.Size)
+		meta.PartCount = int((meta.Size + meta.PartSize - 1) / meta.PartSize)
+	}
+
+	var completed []activities.CompletedPart
+	for refresh := 0; ; refresh++ {
+		// Request upload URL
+		var uploadOut activities.BackupUploadActivityOutput
+		if err := workflow.ExecuteActivity(ctx, internal.ActivityNameBackupUpload, meta).Get(ctx, &uploadOut); err != nil {
+			return err
+		}
+
+		partSize := uploadOut.PartSize
+		if partSize == 0 {
+			partSize = meta.PartSize
+		}
+		// Parts of a different multipart upload cannot be reused
+		if uploadOut.UploadId == "" || uploadOut.UploadId != meta.UploadId {
+			completed = nil
+		}
+
+		// Upload to S3
+		var s3Out activities.FileUploadS3ActivityOutput
+		err := workflow.ExecuteActivity(ctx, internal.ActivityNameFileUploadS3,
+			activities.FileUploadS3ActivityInput{
+				FilePath: filePath, UploadURL: uploadOut.UploadURL, ExpiresAt: uploadOut.ExpiresAt,
+				UploadId: uploadOut.UploadId, PartSize: partSize, Parts: uploadOut.Parts, Completed: completed,
+			},
+		).Get(ctx, &s3Out)
+		if err != nil {
+			var appErr *temporal.ApplicationError
+			if !errors.As(err, &appErr) || appErr.Type() != activities.ErrorTypeUploadURLExpired || refresh >= maxUploadURLRefreshes {
+				return err
+			}
+			if appErr.HasDetails() {
+				_ = appErr.Details(&completed)
+			}
+			meta.UploadId = uploadOut.UploadId
+			logger.Warn("Upload URL expired, requesting a new one",
+				"refresh", refresh+1, "uploadId", uploadOut.UploadId, "completedParts", len(completed))
+			continue
+		}
+
+		if uploadOut.UploadId == "" {
+			return nil
+		}
+		return workflow.ExecuteActivity(ctx, internal.ActivityNameBackupUploadComplete,
+			activities.BackupUploadCompleteActivityInput{
+				JobId: meta.JobId, BackupId: meta.BackupId, UploadId: uploadOut.UploadId, Parts: s3Out.Parts,
+			},
+		).Get(ctx, nil)
+	}
+}
+
+// multipartPartSize returns the part size for a multipart upload of size bytes, growing the
+// default part size when needed to stay within the S3 limit on the number of parts
+func multipartPartSize(size int64) int64 {
+	partSize := int64(defaultPartSize)
+	if minSize := (size + maxUploadParts - 1) / maxUploadParts; minSize > partSize {
+		partSize = minSize
+	}
+	return partSize
+}
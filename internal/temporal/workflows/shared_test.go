@@ -0,0 +1,34 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestFailureMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"application error", temporal.NewApplicationError("curl failed: exit status 22", "DownloadFailed"), "curl failed: exit status 22"},
+		{"wrapped application error", fmt.Errorf("activity error: %w",
+			temporal.NewNonRetryableApplicationError("disk full", "TempSpace", errors.New("no space left on device"))), "disk full"},
+		{"heartbeat timeout", temporal.NewTimeoutError(enumspb.TIMEOUT_TYPE_HEARTBEAT, nil), "activity timed out (Heartbeat)"},
+		{"start to close timeout", fmt.Errorf("activity error: %w",
+			temporal.NewTimeoutError(enumspb.TIMEOUT_TYPE_START_TO_CLOSE, nil)), "activity timed out (StartToClose)"},
+		{"canceled", temporal.NewCanceledError(), "backup was cancelled"},
+		{"wrapped canceled", fmt.Errorf("activity error: %w", temporal.NewCanceledError("details")), "backup was cancelled"},
+		{"plain error", errors.New("connection refused"), "connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, failureMessage(tt.err))
+		})
+	}
+}